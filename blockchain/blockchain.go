@@ -141,7 +141,11 @@ func (b *BlockChain) InitializeProducersState(interrupt <-chan struct{},
 				break
 			}
 			confirm, _ := b.db.GetConfirm(block.Hash())
-			DefaultLedger.Arbitrators.ProcessBlock(block, confirm)
+			if e := DefaultLedger.Arbitrators.ProcessBlock(block,
+				confirm); e != nil {
+				log.Warn("[InitializeProducersState] process block ",
+					block.Height, " error: ", e)
+			}
 
 			// Notify process increase.
 			if increase != nil {
@@ -763,7 +767,11 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 
 		// update state after connected block
 		if block.Height >= b.chainParams.VoteStartHeight {
-			DefaultLedger.Arbitrators.ProcessBlock(block, confirm)
+			if err := DefaultLedger.Arbitrators.ProcessBlock(block,
+				confirm); err != nil {
+				log.Warn("[reorganizeChain] process block ", block.Height,
+					" error: ", err)
+			}
 			DefaultLedger.Arbitrators.DumpInfo()
 		}
 
@@ -926,7 +934,11 @@ func (b *BlockChain) maybeAcceptBlock(block *Block, confirm *payload.Confirm) (b
 		// In case of VoteStartHeight larger than (CRCOnlyDPOSHeight-PreConnectOffset)
 		block.Height == b.chainParams.CRCOnlyDPOSHeight-b.chainParams.
 			PreConnectOffset) {
-		DefaultLedger.Arbitrators.ProcessBlock(block, confirm)
+		if err := DefaultLedger.Arbitrators.ProcessBlock(block,
+			confirm); err != nil {
+			log.Warn("[maybeAcceptBlock] process block ", block.Height,
+				" error: ", err)
+		}
 		DefaultLedger.Arbitrators.DumpInfo()
 	}
 
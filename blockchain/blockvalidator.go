@@ -4,6 +4,7 @@ import (
 	"errors"
 	"math"
 	"math/big"
+	"sort"
 	"time"
 
 	. "github.com/elastos/Elastos.ELA/auxpow"
@@ -48,8 +49,17 @@ func (b *BlockChain) CheckBlockSanity(block *Block) error {
 		return errors.New("[PowCheckBlockSanity]  block does not contain any transactions")
 	}
 
-	// A block must not have more transactions than the max block payload.
-	if numTx > pact.MaxTxPerBlock {
+	// A block must not have more transactions than the max block payload,
+	// limited DPOS special transactions are not counted so they always fit.
+	var countableTx, exemptTx int
+	for _, tx := range block.Transactions {
+		if ExemptFromTxLimit(b.chainParams, header.Height, tx, exemptTx) {
+			exemptTx++
+			continue
+		}
+		countableTx++
+	}
+	if countableTx > pact.MaxTxPerBlock {
 		return errors.New("[PowCheckBlockSanity]  block contains too many transactions")
 	}
 
@@ -72,6 +82,12 @@ func (b *BlockChain) CheckBlockSanity(block *Block) error {
 		}
 	}
 
+	// A block must not have more inactive arbitrators transactions than the
+	// limit on the height.
+	if err := b.checkInactiveArbitratorsCount(block); err != nil {
+		return err
+	}
+
 	txIDs := make([]Uint256, 0, len(transactions))
 	existingTxIDs := make(map[Uint256]struct{})
 	existingTxInputs := make(map[string]struct{})
@@ -168,6 +184,36 @@ func (b *BlockChain) CheckBlockSanity(block *Block) error {
 	return nil
 }
 
+// InactiveArbitratorsLimit returns the maximum inactive arbitrators
+// transactions allowed in the block on the given height, zero means no limit.
+func InactiveArbitratorsLimit(params *config.Params, height uint32) uint32 {
+	if height < params.InactiveTxLimitHeight {
+		return 0
+	}
+	return params.MaxInactivePayloadsPerHeight
+}
+
+// checkInactiveArbitratorsCount checks the inactive arbitrators transactions
+// in the block do not exceed MaxInactivePayloadsPerHeight.
+func (b *BlockChain) checkInactiveArbitratorsCount(block *Block) error {
+	limit := InactiveArbitratorsLimit(b.chainParams, block.Height)
+	if limit == 0 {
+		return nil
+	}
+
+	var count uint32
+	for _, tx := range block.Transactions {
+		if tx.IsInactiveArbitrators() {
+			count++
+		}
+	}
+	if count > limit {
+		return errors.New("[PowCheckBlockSanity] block contains too many" +
+			" inactive arbitrators transactions")
+	}
+	return nil
+}
+
 func (b *BlockChain) checkTxsContext(block *Block) error {
 	var totalTxFee = Fixed64(0)
 
@@ -264,6 +310,23 @@ func IsFinalizedTransaction(msgTx *Transaction, blockHeight uint32) bool {
 	return true
 }
 
+// CountableTxForLimit returns if the transaction is counted against the max
+// transactions per block, DPOS special transactions like illegal evidences
+// and inactive arbitrators are not counted.
+func CountableTxForLimit(tx *Transaction) bool {
+	return !tx.IsIllegalTypeTx() && !tx.IsInactiveArbitrators()
+}
+
+// ExemptFromTxLimit returns if the transaction of a block on the given height
+// is not counted against the max transactions per block, with the count of
+// transactions exempted before it in the same block.  From TxLimitExemptHeight
+// up to MaxExemptTxPerBlock DPOS special transactions are exempted.
+func ExemptFromTxLimit(params *config.Params, height uint32, tx *Transaction,
+	exempted int) bool {
+	return height >= params.TxLimitExemptHeight &&
+		exempted < pact.MaxExemptTxPerBlock && !CountableTxForLimit(tx)
+}
+
 func GetTxFee(tx *Transaction, assetId Uint256) Fixed64 {
 	feeMap, err := GetTxFeeMap(tx)
 	if err != nil {
@@ -333,14 +396,144 @@ func (b *BlockChain) checkCoinbaseTransactionContext(blockHeight uint32, coinbas
 		return errors.New("Reward amount in coinbase not correct")
 	}
 
-	if err := checkCoinbaseArbitratorsReward(blockHeight, coinbase, rewardInCoinbase); err != nil {
+	var minBlockConfirmReward Fixed64
+	if blockHeight >= b.chainParams.MinConfirmRewardHeight {
+		minBlockConfirmReward = b.chainParams.MinBlockConfirmReward
+	}
+	var crcRecipients []config.CRCRewardRecipient
+	if blockHeight >= b.chainParams.CRCRewardHeight {
+		crcRecipients = b.chainParams.CRCRewardRecipients
+	}
+	roundingMode := config.RoundFloorWithChange
+	if blockHeight >= b.chainParams.RewardRoundingHeight {
+		roundingMode = b.chainParams.RewardRoundingMode
+	}
+	if err := checkCoinbaseArbitratorsReward(blockHeight, coinbase,
+		rewardInCoinbase, minBlockConfirmReward, crcRecipients,
+		roundingMode); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func checkCoinbaseArbitratorsReward(height uint32, coinbase *Transaction, rewardInCoinbase Fixed64) error {
+// SplitDPOSReward splits the DPOS reward into the block confirm reward of each
+// arbiter and the total reward to top producers by votes. The block confirm
+// reward is at least minBlockConfirmReward if the DPOS reward is enough,
+// otherwise the DPOS reward is equally distributed to arbiters.
+func SplitDPOSReward(dposReward float64, arbitersCount int,
+	minBlockConfirmReward Fixed64) (individualBlockConfirmReward Fixed64,
+	totalTopProducersReward float64) {
+	totalBlockConfirmReward := dposReward * 0.25
+	totalTopProducersReward = dposReward - totalBlockConfirmReward
+	individualBlockConfirmReward = Fixed64(math.Floor(totalBlockConfirmReward /
+		float64(arbitersCount)))
+	if individualBlockConfirmReward >= minBlockConfirmReward {
+		return individualBlockConfirmReward, totalTopProducersReward
+	}
+
+	floorReward := float64(minBlockConfirmReward) * float64(arbitersCount)
+	if floorReward <= dposReward {
+		return minBlockConfirmReward, dposReward - floorReward
+	}
+	return Fixed64(math.Floor(dposReward / float64(arbitersCount))), 0
+}
+
+// SplitCRCReward splits the CRC reward among the recipients by weight, the
+// remainder of rounding goes to the first recipient so the split amounts sum
+// exactly to the CRC reward.
+func SplitCRCReward(crcReward Fixed64,
+	recipients []config.CRCRewardRecipient) []Fixed64 {
+	amounts := make([]Fixed64, len(recipients))
+	if len(recipients) == 0 {
+		return amounts
+	}
+
+	totalWeight := new(big.Int)
+	for _, r := range recipients {
+		totalWeight.Add(totalWeight, big.NewInt(int64(r.Weight)))
+	}
+	if totalWeight.Sign() <= 0 {
+		amounts[0] = crcReward
+		return amounts
+	}
+
+	var split Fixed64
+	for i, r := range recipients {
+		amount := new(big.Int).Mul(big.NewInt(int64(crcReward)),
+			big.NewInt(int64(r.Weight)))
+		amount.Quo(amount, totalWeight)
+		amounts[i] = Fixed64(amount.Int64())
+		split += amounts[i]
+	}
+	amounts[0] += crcReward - split
+	return amounts
+}
+
+// CheckCRCRewardRecipients returns error if any of the CRC reward recipients
+// is also an owner address of the given arbiters, which would mix up the
+// rewards paid to the same address in coinbase.
+func CheckCRCRewardRecipients(recipients []config.CRCRewardRecipient,
+	ownerHashes ...[]*Uint168) error {
+	for _, r := range recipients {
+		for _, hashes := range ownerHashes {
+			for _, hash := range hashes {
+				if r.Address.IsEqual(*hash) {
+					return errors.New("crc reward recipient is an owner" +
+						" address")
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// SplitRewardChange splits the change of DPOS reward distribution among the
+// voted producers by the rounding mode, rewards are the unrounded vote rewards
+// of the producers.  In RoundLargestRemainder mode the change is assigned one
+// sela at a time to the producers in descending order of the fractional part
+// of their rewards, ties are broken by the given order, until the change is
+// exhausted.  In RoundFloorWithChange mode nothing is assigned and the change
+// is kept.
+func SplitRewardChange(change Fixed64, rewards []float64,
+	mode config.RewardRoundingMode) ([]Fixed64, error) {
+	extras := make([]Fixed64, len(rewards))
+	switch mode {
+	case config.RoundFloorWithChange:
+		return extras, nil
+	case config.RoundLargestRemainder:
+	default:
+		return nil, errors.New("unknown reward rounding mode " + mode.String())
+	}
+	if change <= 0 || len(rewards) == 0 {
+		return extras, nil
+	}
+
+	order := make([]int, len(rewards))
+	fractions := make([]float64, len(rewards))
+	for i, reward := range rewards {
+		order[i] = i
+		fractions[i] = reward - math.Floor(reward)
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return fractions[order[i]] > fractions[order[j]]
+	})
+
+	share := change / Fixed64(len(rewards))
+	remainder := int(change % Fixed64(len(rewards)))
+	for i, index := range order {
+		extras[index] = share
+		if i < remainder {
+			extras[index]++
+		}
+	}
+	return extras, nil
+}
+
+func checkCoinbaseArbitratorsReward(height uint32, coinbase *Transaction,
+	rewardInCoinbase Fixed64, minBlockConfirmReward Fixed64,
+	crcRecipients []config.CRCRewardRecipient,
+	roundingMode config.RewardRoundingMode) error {
 	// main version >= H2
 	if height >= config.DefaultParams.PublicDPOSHeight {
 		outputAddressMap := make(map[Uint168]Fixed64)
@@ -350,49 +543,102 @@ func checkCoinbaseArbitratorsReward(height uint32, coinbase *Transaction, reward
 
 		currentOwnerHashes := DefaultLedger.Arbitrators.GetCurrentOwnerProgramHashes()
 		candidateOwnerHashes := DefaultLedger.Arbitrators.GetCandidateOwnerProgramHashes()
-		if len(currentOwnerHashes)+len(candidateOwnerHashes) != len(coinbase.Outputs)-2 {
+		if err := CheckCRCRewardRecipients(crcRecipients, currentOwnerHashes,
+			candidateOwnerHashes); err != nil {
+			return err
+		}
+		outputCount := len(currentOwnerHashes) + len(candidateOwnerHashes)
+		if len(crcRecipients) > 0 {
+			// CRC arbiters rewards are paid to the recipients instead.
+			for _, hash := range currentOwnerHashes {
+				if DefaultLedger.Arbitrators.IsCRCArbitratorProgramHash(hash) {
+					outputCount--
+				}
+			}
+			outputCount += len(crcRecipients)
+		}
+		if outputCount != len(coinbase.Outputs)-2 {
 			return errors.New("coinbase output count not match")
 		}
 
 		dposTotalReward := float64(rewardInCoinbase) * 0.35
-		totalBlockConfirmReward := dposTotalReward * 0.25
-		totalTopProducersReward := dposTotalReward - totalBlockConfirmReward
-		individualBlockConfirmReward := Fixed64(math.Floor(totalBlockConfirmReward / float64(len(currentOwnerHashes))))
+		individualBlockConfirmReward, totalTopProducersReward := SplitDPOSReward(
+			dposTotalReward, len(currentOwnerHashes), minBlockConfirmReward)
 		totalVotesInRound := DefaultLedger.Arbitrators.GetTotalVotesInRound()
 		rewardPerVote := totalTopProducersReward / float64(totalVotesInRound)
 
+		var crcReward Fixed64
+		var realDPOSReward Fixed64
+		var votedHashes []*Uint168
+		var votedExactRewards []float64
+		var votedRewards []Fixed64
 		for _, hash := range currentOwnerHashes {
-			amount, ok := outputAddressMap[*hash]
-			if !ok {
-				return errors.New("unknown dpos reward address")
+			isCRC := DefaultLedger.Arbitrators.IsCRCArbitratorProgramHash(hash)
+			if isCRC && len(crcRecipients) > 0 {
+				crcReward += individualBlockConfirmReward
+				realDPOSReward += individualBlockConfirmReward
+				continue
 			}
 
-			if DefaultLedger.Arbitrators.IsCRCArbitratorProgramHash(hash) {
-				if amount != individualBlockConfirmReward {
-					return errors.New("incorrect dpos reward amount")
+			if isCRC {
+				amount, ok := outputAddressMap[*hash]
+				if !ok {
+					return errors.New("unknown dpos reward address")
 				}
-			} else {
-				votes := DefaultLedger.Arbitrators.GetOwnerVotes(hash)
-				individualProducerReward := Fixed64(float64(votes) * rewardPerVote)
-				if amount != individualProducerReward+individualBlockConfirmReward {
+				if amount != individualBlockConfirmReward {
 					return errors.New("incorrect dpos reward amount")
 				}
+				realDPOSReward += individualBlockConfirmReward
+				continue
 			}
+
+			votes := DefaultLedger.Arbitrators.GetOwnerVotes(hash)
+			exactReward := float64(votes) * rewardPerVote
+			individualProducerReward := Fixed64(exactReward)
+			votedHashes = append(votedHashes, hash)
+			votedExactRewards = append(votedExactRewards, exactReward)
+			votedRewards = append(votedRewards,
+				individualProducerReward+individualBlockConfirmReward)
 		}
 
 		for _, hash := range candidateOwnerHashes {
+			votes := DefaultLedger.Arbitrators.GetOwnerVotes(hash)
+			exactReward := float64(votes) * rewardPerVote
+			individualProducerReward := Fixed64(exactReward)
+			votedHashes = append(votedHashes, hash)
+			votedExactRewards = append(votedExactRewards, exactReward)
+			votedRewards = append(votedRewards, individualProducerReward)
+		}
+
+		for _, reward := range votedRewards {
+			realDPOSReward += reward
+		}
+		extras, err := SplitRewardChange(Fixed64(dposTotalReward)-realDPOSReward,
+			votedExactRewards, roundingMode)
+		if err != nil {
+			return err
+		}
+		for i, hash := range votedHashes {
 			amount, ok := outputAddressMap[*hash]
 			if !ok {
 				return errors.New("unknown dpos reward address")
 			}
-
-			votes := DefaultLedger.Arbitrators.GetOwnerVotes(hash)
-			individualProducerReward := Fixed64(float64(votes) * rewardPerVote)
-			if amount != individualProducerReward {
+			if amount != votedRewards[i]+extras[i] {
 				return errors.New("incorrect dpos reward amount")
 			}
 		}
 
+		amounts := SplitCRCReward(crcReward, crcRecipients)
+		for i, r := range crcRecipients {
+			amount, ok := outputAddressMap[r.Address]
+			if !ok {
+				return errors.New("unknown crc reward address")
+			}
+			if amount != amounts[i] {
+				return errors.New("incorrect crc reward amount")
+			}
+		}
+
 		return nil
 	}
 
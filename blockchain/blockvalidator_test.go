@@ -13,6 +13,7 @@ import (
 	"github.com/elastos/Elastos.ELA/core/contract"
 	"github.com/elastos/Elastos.ELA/core/types"
 	"github.com/elastos/Elastos.ELA/dpos/state"
+	"github.com/elastos/Elastos.ELA/elanet/pact"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -159,21 +160,209 @@ func TestCheckCoinbaseArbitratorsReward(t *testing.T) {
 		{ProgramHash: common.Uint168{}, Value: common.Fixed64(float64(rewardInCoinbase) * 0.35)},
 	}
 
-	assert.Error(t, checkCoinbaseArbitratorsReward(config.Parameters.PublicDPOSHeight, tx, rewardInCoinbase))
+	assert.Error(t, checkCoinbaseArbitratorsReward(config.Parameters.PublicDPOSHeight, tx, rewardInCoinbase, 0, nil, config.RoundFloorWithChange))
 
 	for _, v := range arbitratorHashes {
 		vote := ownerVotes[*v]
 		individualProducerReward := common.Fixed64(rewardPerVote * float64(vote))
 		tx.Outputs = append(tx.Outputs, &types.Output{ProgramHash: *v, Value: individualBlockConfirmReward + individualProducerReward})
 	}
-	assert.Error(t, checkCoinbaseArbitratorsReward(config.Parameters.PublicDPOSHeight, tx, rewardInCoinbase))
+	assert.Error(t, checkCoinbaseArbitratorsReward(config.Parameters.PublicDPOSHeight, tx, rewardInCoinbase, 0, nil, config.RoundFloorWithChange))
 
 	for _, v := range candidateHashes {
 		vote := ownerVotes[*v]
 		individualProducerReward := common.Fixed64(rewardPerVote * float64(vote))
 		tx.Outputs = append(tx.Outputs, &types.Output{ProgramHash: *v, Value: individualProducerReward})
 	}
-	assert.NoError(t, checkCoinbaseArbitratorsReward(config.Parameters.PublicDPOSHeight, tx, rewardInCoinbase))
+	assert.NoError(t, checkCoinbaseArbitratorsReward(config.Parameters.PublicDPOSHeight, tx, rewardInCoinbase, 0, nil, config.RoundFloorWithChange))
+
+	// CRC arbiters rewards are split among the recipients by weight.
+	arbitratorsMock.CRCOwnerProgramHashes = arbitratorHashes[:2]
+	recipients := []config.CRCRewardRecipient{
+		{Address: common.Uint168{1}, Weight: 1},
+		{Address: common.Uint168{2}, Weight: 2},
+	}
+	tx.Outputs = tx.Outputs[:2]
+	for _, v := range arbitratorHashes[2:] {
+		vote := ownerVotes[*v]
+		individualProducerReward := common.Fixed64(rewardPerVote * float64(vote))
+		tx.Outputs = append(tx.Outputs, &types.Output{ProgramHash: *v, Value: individualBlockConfirmReward + individualProducerReward})
+	}
+	for _, v := range candidateHashes {
+		vote := ownerVotes[*v]
+		individualProducerReward := common.Fixed64(rewardPerVote * float64(vote))
+		tx.Outputs = append(tx.Outputs, &types.Output{ProgramHash: *v, Value: individualProducerReward})
+	}
+	assert.Error(t, checkCoinbaseArbitratorsReward(config.Parameters.PublicDPOSHeight, tx, rewardInCoinbase, 0, recipients, config.RoundFloorWithChange))
+
+	amounts := SplitCRCReward(individualBlockConfirmReward*2, recipients)
+	for i, r := range recipients {
+		tx.Outputs = append(tx.Outputs, &types.Output{ProgramHash: r.Address, Value: amounts[i]})
+	}
+	assert.NoError(t, checkCoinbaseArbitratorsReward(config.Parameters.PublicDPOSHeight, tx, rewardInCoinbase, 0, recipients, config.RoundFloorWithChange))
+
+	tx.Outputs[len(tx.Outputs)-1].Value++
+	assert.Error(t, checkCoinbaseArbitratorsReward(config.Parameters.PublicDPOSHeight, tx, rewardInCoinbase, 0, recipients, config.RoundFloorWithChange))
+	tx.Outputs[len(tx.Outputs)-1].Value--
+
+	// Recipients should not be owner addresses of arbiters or candidates.
+	for _, hash := range []*common.Uint168{arbitratorHashes[2], candidateHashes[0]} {
+		recipients[1].Address = *hash
+		assert.Error(t, CheckCRCRewardRecipients(recipients, arbitratorHashes, candidateHashes))
+		assert.Error(t, checkCoinbaseArbitratorsReward(config.Parameters.PublicDPOSHeight, tx, rewardInCoinbase, 0, recipients, config.RoundFloorWithChange))
+	}
 
 	DefaultLedger = originLedger
 }
+
+func TestSplitRewardChange(t *testing.T) {
+	rewards := []float64{10.25, 30.75, 20.5, 30.75}
+
+	// The change is kept in floor with change mode.
+	extras, err := SplitRewardChange(7, rewards, config.RoundFloorWithChange)
+	assert.NoError(t, err)
+	assert.Equal(t, []common.Fixed64{0, 0, 0, 0}, extras)
+
+	// The remainder goes to the producers with the largest fractional parts,
+	// ties are broken by the given order.
+	extras, err = SplitRewardChange(7, rewards, config.RoundLargestRemainder)
+	assert.NoError(t, err)
+	assert.Equal(t, []common.Fixed64{1, 2, 2, 2}, extras)
+
+	extras, err = SplitRewardChange(2, rewards, config.RoundLargestRemainder)
+	assert.NoError(t, err)
+	assert.Equal(t, []common.Fixed64{0, 1, 0, 1}, extras)
+
+	extras, err = SplitRewardChange(1, []float64{30.25, 10.5, 20.5},
+		config.RoundLargestRemainder)
+	assert.NoError(t, err)
+	assert.Equal(t, []common.Fixed64{0, 1, 0}, extras)
+
+	// Nothing to assign without change.
+	extras, err = SplitRewardChange(0, rewards, config.RoundLargestRemainder)
+	assert.NoError(t, err)
+	assert.Equal(t, []common.Fixed64{0, 0, 0, 0}, extras)
+
+	// Unknown rounding mode is rejected.
+	_, err = SplitRewardChange(7, rewards, config.RewardRoundingMode(2))
+	assert.Error(t, err)
+}
+
+func TestSplitCRCReward(t *testing.T) {
+	recipients := []config.CRCRewardRecipient{
+		{Address: common.Uint168{1}, Weight: 1},
+		{Address: common.Uint168{2}, Weight: 3},
+	}
+
+	// The reward is split by weight.
+	assert.Equal(t, []common.Fixed64{250, 750},
+		SplitCRCReward(1000, recipients))
+
+	// The remainder goes to the first recipient.
+	assert.Equal(t, []common.Fixed64{251, 750},
+		SplitCRCReward(1001, recipients))
+	assert.Equal(t, []common.Fixed64{251, 752},
+		SplitCRCReward(1003, recipients))
+
+	// No recipients, nothing split.
+	assert.Equal(t, []common.Fixed64{}, SplitCRCReward(1000, nil))
+}
+
+func TestSplitDPOSReward(t *testing.T) {
+	// No minimum block confirm reward.
+	confirmReward, topProducersReward := SplitDPOSReward(1000, 5, 0)
+	assert.Equal(t, common.Fixed64(50), confirmReward)
+	assert.Equal(t, float64(750), topProducersReward)
+
+	// Block confirm reward is already above the minimum.
+	confirmReward, topProducersReward = SplitDPOSReward(1000, 5, 40)
+	assert.Equal(t, common.Fixed64(50), confirmReward)
+	assert.Equal(t, float64(750), topProducersReward)
+
+	// The minimum is drawn from the DPOS reward.
+	confirmReward, topProducersReward = SplitDPOSReward(35, 5, 3)
+	assert.Equal(t, common.Fixed64(3), confirmReward)
+	assert.Equal(t, float64(20), topProducersReward)
+
+	// The DPOS reward is not enough for the minimum.
+	confirmReward, topProducersReward = SplitDPOSReward(35, 5, 10)
+	assert.Equal(t, common.Fixed64(7), confirmReward)
+	assert.Equal(t, float64(0), topProducersReward)
+	confirmReward, topProducersReward = SplitDPOSReward(3, 5, 10)
+	assert.Equal(t, common.Fixed64(0), confirmReward)
+	assert.Equal(t, float64(0), topProducersReward)
+}
+
+func TestCountableTxForLimit(t *testing.T) {
+	tests := []struct {
+		txType    types.TxType
+		countable bool
+	}{
+		{types.CoinBase, true},
+		{types.TransferAsset, true},
+		{types.RegisterProducer, true},
+		{types.CancelProducer, true},
+		{types.IllegalProposalEvidence, false},
+		{types.IllegalVoteEvidence, false},
+		{types.IllegalBlockEvidence, false},
+		{types.IllegalSidechainEvidence, false},
+		{types.InactiveArbitrators, false},
+	}
+	for _, test := range tests {
+		tx := &types.Transaction{TxType: test.txType}
+		assert.Equal(t, test.countable, CountableTxForLimit(tx),
+			"tx type %s", test.txType.Name())
+	}
+}
+
+func TestExemptFromTxLimit(t *testing.T) {
+	params := config.DefaultParams
+	params.TxLimitExemptHeight = 100
+	inactiveTx := &types.Transaction{TxType: types.InactiveArbitrators}
+	transferTx := &types.Transaction{TxType: types.TransferAsset}
+
+	// Special transactions are counted before the exempt height.
+	assert.False(t, ExemptFromTxLimit(&params, 99, inactiveTx, 0))
+	assert.True(t, ExemptFromTxLimit(&params, 100, inactiveTx, 0))
+	assert.False(t, ExemptFromTxLimit(&params, 100, transferTx, 0))
+
+	// The exempted transactions are bounded per block.
+	assert.True(t, ExemptFromTxLimit(&params, 100, inactiveTx,
+		pact.MaxExemptTxPerBlock-1))
+	assert.False(t, ExemptFromTxLimit(&params, 100, inactiveTx,
+		pact.MaxExemptTxPerBlock))
+}
+
+func TestCheckInactiveArbitratorsCount(t *testing.T) {
+	params := config.DefaultParams
+	params.InactiveTxLimitHeight = 100
+	params.MaxInactivePayloadsPerHeight = 2
+	chain := &BlockChain{chainParams: &params}
+
+	block := &types.Block{
+		Header: types.Header{Height: 99},
+		Transactions: []*types.Transaction{
+			{TxType: types.CoinBase},
+			{TxType: types.InactiveArbitrators},
+			{TxType: types.InactiveArbitrators},
+			{TxType: types.InactiveArbitrators},
+		},
+	}
+
+	// The limit does not apply before the height.
+	assert.Equal(t, uint32(0), InactiveArbitratorsLimit(&params, 99))
+	assert.NoError(t, chain.checkInactiveArbitratorsCount(block))
+
+	assert.Equal(t, uint32(2), InactiveArbitratorsLimit(&params, 100))
+	block.Height = 100
+	assert.Error(t, chain.checkInactiveArbitratorsCount(block))
+
+	block.Transactions = block.Transactions[:3]
+	assert.NoError(t, chain.checkInactiveArbitratorsCount(block))
+
+	// No limit if MaxInactivePayloadsPerHeight is zero.
+	params.MaxInactivePayloadsPerHeight = 0
+	block.Transactions = append(block.Transactions,
+		&types.Transaction{TxType: types.InactiveArbitrators})
+	assert.NoError(t, chain.checkInactiveArbitratorsCount(block))
+}
@@ -28,10 +28,6 @@ const (
 	// MinDepositAmount is the minimum deposit as a producer.
 	MinDepositAmount = 5000 * 100000000
 
-	// DepositLockupBlocks indicates how many blocks need to wait when cancel
-	// producer was triggered, and can submit return deposit coin request.
-	DepositLockupBlocks = 2160
-
 	// MaxStringLength is the maximum length of a string field.
 	MaxStringLength = 100
 
@@ -57,6 +53,11 @@ func (b *BlockChain) CheckTransactionSanity(blockHeight uint32, txn *Transaction
 		return ErrInvalidOutput
 	}
 
+	if err := b.checkVoteOutputTypes(blockHeight, txn); err != nil {
+		log.Warn("[CheckVoteOutputTypes],", err)
+		return ErrInvalidOutput
+	}
+
 	if err := checkAssetPrecision(txn); err != nil {
 		log.Warn("[CheckAssetPrecesion],", err)
 		return ErrAssetPrecision
@@ -114,7 +115,8 @@ func (b *BlockChain) CheckTransactionContext(blockHeight uint32, txn *Transactio
 		}
 
 	case IllegalBlockEvidence:
-		if err := b.checkIllegalBlocksTransaction(txn); err != nil {
+		if err := b.checkIllegalBlocksTransaction(txn,
+			blockHeight); err != nil {
 			log.Warn("[CheckIllegalBlocksTransaction],", err)
 			return ErrTransactionPayload
 		}
@@ -139,10 +141,19 @@ func (b *BlockChain) CheckTransactionContext(blockHeight uint32, txn *Transactio
 		}
 
 	case RegisterProducer:
+		if err := b.checkProducerBlocklist(txn, blockHeight); err != nil {
+			log.Warn("[CheckProducerBlocklist],", err)
+			return ErrTransactionPayload
+		}
 		if err := b.checkRegisterProducerTransaction(txn); err != nil {
 			log.Warn("[CheckRegisterProducerTransaction],", err)
 			return ErrTransactionPayload
 		}
+		if err := state.CheckProducerInfo(b.chainParams,
+			txn.Payload.(*payload.ProducerInfo), blockHeight); err != nil {
+			log.Warn("[CheckProducerInfo],", err)
+			return ErrTransactionPayload
+		}
 
 	case CancelProducer:
 		if err := b.checkCancelProducerTransaction(txn); err != nil {
@@ -151,12 +162,25 @@ func (b *BlockChain) CheckTransactionContext(blockHeight uint32, txn *Transactio
 		}
 
 	case UpdateProducer:
+		if err := b.checkProducerBlocklist(txn, blockHeight); err != nil {
+			log.Warn("[CheckProducerBlocklist],", err)
+			return ErrTransactionPayload
+		}
 		if err := b.checkUpdateProducerTransaction(txn); err != nil {
 			log.Warn("[CheckUpdateProducerTransaction],", err)
 			return ErrTransactionPayload
 		}
+		if err := state.CheckProducerInfo(b.chainParams,
+			txn.Payload.(*payload.ProducerInfo), blockHeight); err != nil {
+			log.Warn("[CheckProducerInfo],", err)
+			return ErrTransactionPayload
+		}
 
 	case ActivateProducer:
+		if err := b.checkProducerBlocklist(txn, blockHeight); err != nil {
+			log.Warn("[CheckProducerBlocklist],", err)
+			return ErrTransactionPayload
+		}
 		if err := b.checkActivateProducerTransaction(txn, blockHeight); err != nil {
 			log.Warn("[CheckActivateProducerTransaction],", err)
 			return ErrTransactionPayload
@@ -257,7 +281,8 @@ func checkVoteProducerOutputs(outputs []*Output, references map[*Input]*Output,
 				return errors.New("invalid vote output payload")
 			}
 			for _, content := range payload.Contents {
-				if content.VoteType == outputpayload.Delegate {
+				if content.VoteType == outputpayload.Delegate ||
+					content.VoteType == outputpayload.Candidate {
 					for _, candidate := range content.Candidates {
 						if _, ok := pds[common.BytesToHexString(candidate)]; !ok {
 							return fmt.Errorf("invalid vote output payload candidate: %s", common.BytesToHexString(candidate))
@@ -369,6 +394,32 @@ func checkTransactionInput(txn *Transaction) error {
 	return nil
 }
 
+// checkVoteOutputTypes checks the vote types used by vote outputs have been
+// activated at the given height.
+func (b *BlockChain) checkVoteOutputTypes(blockHeight uint32,
+	txn *Transaction) error {
+	for _, output := range txn.Outputs {
+		if output.Type != OTVote {
+			continue
+		}
+		payload, ok := output.Payload.(*outputpayload.VoteOutput)
+		if !ok {
+			return errors.New("invalid vote output payload")
+		}
+		for _, content := range payload.Contents {
+			if content.VoteType == outputpayload.Abstain &&
+				blockHeight < b.chainParams.AbstainVoteHeight {
+				return errors.New("abstain vote is not activated")
+			}
+			if content.VoteType == outputpayload.Candidate &&
+				blockHeight < b.chainParams.CandidateVoteHeight {
+				return errors.New("candidate vote is not activated")
+			}
+		}
+	}
+	return nil
+}
+
 func checkTransactionOutput(blockHeight uint32, txn *Transaction) error {
 	if len(txn.Outputs) > math.MaxUint16 {
 		return errors.New("output count should not be greater than 65535(MaxUint16)")
@@ -852,6 +903,30 @@ func (b *BlockChain) checkTransferCrossChainAssetTransaction(txn *Transaction, r
 	return nil
 }
 
+// checkProducerBlocklist checks the owner of the producer transaction is not
+// in ProducerBlocklist on the given height.
+func (b *BlockChain) checkProducerBlocklist(txn *Transaction,
+	blockHeight uint32) error {
+	var ownerPublicKey []byte
+	switch p := txn.Payload.(type) {
+	case *payload.ProducerInfo:
+		ownerPublicKey = p.OwnerPublicKey
+	case *payload.ProcessProducer:
+		ownerPublicKey = p.OwnerPublicKey
+		if producer := b.state.GetProducer(p.OwnerPublicKey); producer != nil {
+			ownerPublicKey = producer.OwnerPublicKey()
+		}
+	default:
+		return errors.New("invalid payload")
+	}
+
+	if state.IsProducerBlocklisted(b.chainParams, ownerPublicKey,
+		blockHeight) {
+		return errors.New("producer owner is blocklisted")
+	}
+	return nil
+}
+
 func (b *BlockChain) checkRegisterProducerTransaction(txn *Transaction) error {
 	info, ok := txn.Payload.(*payload.ProducerInfo)
 	if !ok {
@@ -971,6 +1046,12 @@ func (b *BlockChain) checkActivateProducerTransaction(txn *Transaction,
 		return err
 	}
 
+	activatePayload := txn.Payload.(*payload.ProcessProducer)
+	if height >= b.chainParams.OwnerActivateHeight && !bytes.Equal(
+		producer.OwnerPublicKey(), activatePayload.OwnerPublicKey) {
+		return errors.New("activate producer should use owner public key")
+	}
+
 	if producer.State() != state.Inactivate {
 		return errors.New("can not activate this producer")
 	}
@@ -1069,10 +1150,14 @@ func (b *BlockChain) checkReturnDepositCoinTransaction(txn *Transaction,
 	var penalty common.Fixed64
 	for _, program := range txn.Programs {
 		p := b.state.GetProducer(program.Code[1 : len(program.Code)-1])
+		if p == nil {
+			return errors.New("signer must be producer")
+		}
 		if p.State() != state.Canceled {
 			return errors.New("producer must be canceled before return deposit coin")
 		}
-		if b.db.GetHeight()-p.CancelHeight() < DepositLockupBlocks {
+		if b.db.GetHeight() < p.CancelHeight()+
+			b.state.GetDepositLockupBlocks(p) {
 			return errors.New("return deposit does not meet the lockup limit")
 		}
 		penalty += p.Penalty()
@@ -1112,7 +1197,8 @@ func (b *BlockChain) checkIllegalVotesTransaction(txn *Transaction) error {
 	return CheckDPOSIllegalVotes(p)
 }
 
-func (b *BlockChain) checkIllegalBlocksTransaction(txn *Transaction) error {
+func (b *BlockChain) checkIllegalBlocksTransaction(txn *Transaction,
+	blockHeight uint32) error {
 	p, ok := txn.Payload.(*payload.DPOSIllegalBlocks)
 	if !ok {
 		return errors.New("invalid payload")
@@ -1122,7 +1208,14 @@ func (b *BlockChain) checkIllegalBlocksTransaction(txn *Transaction) error {
 		return errors.New("tx already exists")
 	}
 
-	return CheckDPOSIllegalBlocks(p)
+	if err := CheckDPOSIllegalBlocks(p); err != nil {
+		return err
+	}
+
+	if blockHeight >= b.chainParams.IllegalBlockCheckHeight {
+		return DefaultLedger.Arbitrators.ValidateIllegalBlockEvidence(p)
+	}
+	return nil
 }
 
 func (b *BlockChain) checkInactiveArbitratorsTransaction(
@@ -900,6 +900,80 @@ func (s *txValidatorTestSuite) TestCheckVoteProducerOutputs() {
 	s.Error(checkVoteProducerOutputs(outputs, references, producers))
 }
 
+func (s *txValidatorTestSuite) TestCheckVoteOutputTypes() {
+	abstainHeight := s.Chain.chainParams.AbstainVoteHeight
+	s.Chain.chainParams.AbstainVoteHeight = 100
+	defer func() {
+		s.Chain.chainParams.AbstainVoteHeight = abstainHeight
+	}()
+
+	tx := &types.Transaction{
+		Version: types.TxVersion09,
+		TxType:  types.TransferAsset,
+		Outputs: []*types.Output{{
+			Type: types.OTVote,
+			Payload: &outputpayload.VoteOutput{
+				Version: 0,
+				Contents: []outputpayload.VoteContent{
+					{VoteType: outputpayload.Abstain},
+				},
+			},
+		}},
+	}
+	s.EqualError(s.Chain.checkVoteOutputTypes(99, tx),
+		"abstain vote is not activated")
+	s.NoError(s.Chain.checkVoteOutputTypes(100, tx))
+
+	candidateHeight := s.Chain.chainParams.CandidateVoteHeight
+	s.Chain.chainParams.CandidateVoteHeight = 200
+	defer func() {
+		s.Chain.chainParams.CandidateVoteHeight = candidateHeight
+	}()
+
+	candidate, _ := common.HexStringToBytes(
+		"023a133480176214f88848c6eaa684a54b316849df2b8570b57f3a917f19bbc77a")
+	tx.Outputs[0].Payload = &outputpayload.VoteOutput{
+		Version: 0,
+		Contents: []outputpayload.VoteContent{
+			{VoteType: outputpayload.Candidate, Candidates: [][]byte{candidate}},
+		},
+	}
+	s.EqualError(s.Chain.checkVoteOutputTypes(199, tx),
+		"candidate vote is not activated")
+	s.NoError(s.Chain.checkVoteOutputTypes(200, tx))
+}
+
+func (s *txValidatorTestSuite) TestCheckProducerBlocklist() {
+	blocklistHeight := s.Chain.chainParams.ProducerBlocklistHeight
+	blocklist := s.Chain.chainParams.ProducerBlocklist
+	defer func() {
+		s.Chain.chainParams.ProducerBlocklistHeight = blocklistHeight
+		s.Chain.chainParams.ProducerBlocklist = blocklist
+	}()
+
+	publicKeyStr := "023a133480176214f88848c6eaa684a54b316849df2b8570b57f3a917f19bbc77a"
+	publicKey, _ := common.HexStringToBytes(publicKeyStr)
+	s.Chain.chainParams.ProducerBlocklistHeight = 100
+	s.Chain.chainParams.ProducerBlocklist = []string{publicKeyStr}
+
+	register := &types.Transaction{
+		TxType:  types.RegisterProducer,
+		Payload: &payload.ProducerInfo{OwnerPublicKey: publicKey},
+	}
+	activate := &types.Transaction{
+		TxType:  types.ActivateProducer,
+		Payload: &payload.ProcessProducer{OwnerPublicKey: publicKey},
+	}
+	for _, tx := range []*types.Transaction{register, activate} {
+		s.NoError(s.Chain.checkProducerBlocklist(tx, 99))
+		s.EqualError(s.Chain.checkProducerBlocklist(tx, 100),
+			"producer owner is blocklisted")
+	}
+
+	publicKey[1]++
+	s.NoError(s.Chain.checkProducerBlocklist(register, 100))
+}
+
 func TestTxValidatorSuite(t *testing.T) {
 	suite.Run(t, new(txValidatorTestSuite))
 }
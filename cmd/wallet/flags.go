@@ -13,6 +13,10 @@ var (
 		Usage: "wallet `<file>` path, ",
 		Value: account.KeystoreFileName,
 	}
+	AccountWalletsFlag = cli.StringFlag{
+		Name:  "wallets",
+		Usage: "wallet `<files>` path list to sign in sequence, separate paths with comma `,`",
+	}
 	AccountPasswordFlag = cli.StringFlag{
 		Name:  "password, p",
 		Usage: "wallet password",
@@ -55,4 +59,30 @@ var (
 		Name:  "file, f",
 		Usage: "the file path to specify a transaction file path with the hex string content to be sign",
 	}
+
+	// Producer flags
+	ProducerNickNameFlag = cli.StringFlag{
+		Name:  "nickname",
+		Usage: "the `<nickname>` of the producer",
+	}
+	ProducerNodePublicKeyFlag = cli.StringFlag{
+		Name:  "nodepubkey",
+		Usage: "the node `<public key>` of the producer in hex string format",
+	}
+	ProducerOwnerPublicKeyFlag = cli.StringFlag{
+		Name:  "ownerpubkey",
+		Usage: "the owner `<public key>` of the producer in hex string format",
+	}
+	ProducerUrlFlag = cli.StringFlag{
+		Name:  "url",
+		Usage: "the `<url>` of the producer",
+	}
+	ProducerLocationFlag = cli.Uint64Flag{
+		Name:  "location",
+		Usage: "the `<location>` code of the producer",
+	}
+	ProducerDepositFlag = cli.StringFlag{
+		Name:  "deposit",
+		Usage: "the deposit `<amount>` of the producer",
+	}
 )
@@ -35,15 +35,34 @@ var txCommand = []cli.Command{
 		},
 		Action: buildTx,
 	},
+	{
+		Category:    "Transaction",
+		Name:        "buildregtx",
+		Usage:       "Build a register producer transaction",
+		Description: "use --nickname --nodepubkey --ownerpubkey --url --location --deposit --fee to create a register producer transaction, the payload signature should be filled by the owner",
+		Flags: []cli.Flag{
+			TransactionFromFlag,
+			TransactionFeeFlag,
+			ProducerNickNameFlag,
+			ProducerNodePublicKeyFlag,
+			ProducerOwnerPublicKeyFlag,
+			ProducerUrlFlag,
+			ProducerLocationFlag,
+			ProducerDepositFlag,
+			AccountWalletFlag,
+		},
+		Action: buildRegisterProducerTx,
+	},
 	{
 		Category:    "Transaction",
 		Name:        "signtx",
 		Usage:       "Sign a transaction",
-		Description: "use --file or --hex to specify the transaction file path or content",
+		Description: "use --file or --hex to specify the transaction file path or content, use --wallets or comma separated --wallet to sign with multiple wallets in sequence",
 		Flags: []cli.Flag{
 			TransactionHexFlag,
 			TransactionFileFlag,
 			AccountWalletFlag,
+			AccountWalletsFlag,
 			AccountPasswordFlag,
 		},
 		Action: signTx,
@@ -59,6 +78,17 @@ var txCommand = []cli.Command{
 		},
 		Action: sendTx,
 	},
+	{
+		Category:    "Transaction",
+		Name:        "decodetx",
+		Usage:       "Decode a raw transaction and show it in readable form",
+		Description: "use --file or --hex to specify the transaction file path or content",
+		Flags: []cli.Flag{
+			TransactionHexFlag,
+			TransactionFileFlag,
+		},
+		Action: decodeTx,
+	},
 	{
 		Category: "Transaction",
 		Name:     "showtx",
@@ -116,66 +146,143 @@ func buildTx(c *cli.Context) error {
 	return nil
 }
 
+func buildRegisterProducerTx(c *cli.Context) error {
+	if c.NumFlags() == 0 {
+		cli.ShowSubcommandHelp(c)
+		return nil
+	}
+	if err := CreateRegisterProducerTransaction(c); err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+	return nil
+}
+
 func signTx(c *cli.Context) error {
 	if c.NumFlags() == 0 {
 		cli.ShowSubcommandHelp(c)
 		return nil
 	}
-	walletPath := c.String("wallet")
-	pwdHex := c.String("password")
-	pwd := []byte(pwdHex)
-	if pwdHex == "" {
-		var err error
-		pwd, err = cmdcom.GetPassword()
+
+	var clients []*account.Client
+	for _, walletPath := range getWalletPaths(c) {
+		pwdHex := c.String("password")
+		pwd := []byte(pwdHex)
+		if pwdHex == "" {
+			fmt.Println("Wallet:", walletPath)
+			var err error
+			pwd, err = cmdcom.GetPassword()
+			if err != nil {
+				return err
+			}
+		}
+
+		client, err := account.Open(walletPath, pwd)
 		if err != nil {
-			return err
+			return fmt.Errorf("open wallet %s failed, %s", walletPath, err)
 		}
+		clients = append(clients, client)
 	}
 
-	client, err := account.Open(walletPath, pwd)
+	txHex, err := getTransactionHex(c)
 	if err != nil {
 		return err
 	}
-
-	txHex, err := getTransactionHex(c)
+	txn, err := decodeTransaction(txHex)
 	if err != nil {
 		return err
 	}
-	rawData, err := common.HexStringToBytes(txHex)
+
+	haveSign, needSign, err := signTransaction(txn, clients)
 	if err != nil {
-		return errors.New("decode transaction content failed")
+		return err
 	}
+	fmt.Println("[", haveSign, "/", needSign, "] Transaction successfully signed")
 
-	var txn types.Transaction
-	err = txn.Deserialize(bytes.NewReader(rawData))
-	if err != nil {
-		return errors.New("deserialize transaction failed")
+	output(haveSign, needSign, txn)
+
+	return nil
+}
+
+// getWalletPaths returns the wallet paths specified by --wallets, or the comma
+// separated --wallet if --wallets not specified.
+func getWalletPaths(c *cli.Context) []string {
+	paths := c.String("wallets")
+	if paths == "" {
+		paths = c.String("wallet")
+	}
+
+	var walletPaths []string
+	for _, path := range strings.Split(paths, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			walletPaths = append(walletPaths, path)
+		}
 	}
+	return walletPaths
+}
 
+// signTransaction signs the transaction by the given wallets in sequence, each
+// wallet must contribute a distinct signature. Returns the collected and
+// required signatures count.
+func signTransaction(txn *types.Transaction, clients []*account.Client) (
+	haveSign, needSign int, err error) {
+	if len(txn.Programs) == 0 {
+		return 0, 0, errors.New("no program in transaction to sign")
+	}
 	program := txn.Programs[0]
 
-	haveSign, needSign, err := crypto.GetSignStatus(program.Code, program.Parameter)
+	haveSign, needSign, err = crypto.GetSignStatus(program.Code,
+		program.Parameter)
 	if err != nil {
-		return err
+		return 0, 0, err
+	}
+
+	for i, client := range clients {
+		if haveSign == needSign {
+			return 0, 0, fmt.Errorf("transaction was fully signed before"+
+				" wallet %d, no need more sign", i+1)
+		}
+
+		if _, err := client.Sign(txn); err != nil {
+			return 0, 0, fmt.Errorf("sign by wallet %d failed, %s", i+1, err)
+		}
+
+		signed, _, err := crypto.GetSignStatus(program.Code, program.Parameter)
+		if err != nil {
+			return 0, 0, err
+		}
+		if signed <= haveSign {
+			return 0, 0, fmt.Errorf("wallet %d contributed no signature",
+				i+1)
+		}
+		haveSign = signed
+		fmt.Println("[", haveSign, "/", needSign, "] Signed by wallet", i+1)
 	}
-	if haveSign == needSign {
-		return errors.New("transaction was fully signed, no need more sign")
+
+	return haveSign, needSign, nil
+}
+
+func sendTx(c *cli.Context) error {
+	if c.NumFlags() == 0 {
+		cli.ShowSubcommandHelp(c)
+		return nil
 	}
 
-	txnSigned, err := client.Sign(&txn)
+	txHex, err := getTransactionHex(c)
 	if err != nil {
 		return err
 	}
 
-	haveSign, needSign, _ = crypto.GetSignStatus(program.Code, program.Parameter)
-	fmt.Println("[", haveSign, "/", needSign, "] Transaction successfully signed")
-
-	output(haveSign, needSign, txnSigned)
+	result, err := jsonrpc.CallParams(cmdcom.LocalServer(), "sendrawtransaction", http.Params{"data": txHex})
+	if err != nil {
+		return err
+	}
+	fmt.Println(result.(string))
 
 	return nil
 }
 
-func sendTx(c *cli.Context) error {
+func decodeTx(c *cli.Context) error {
 	if c.NumFlags() == 0 {
 		cli.ShowSubcommandHelp(c)
 		return nil
@@ -186,11 +293,12 @@ func sendTx(c *cli.Context) error {
 		return err
 	}
 
-	result, err := jsonrpc.CallParams(cmdcom.LocalServer(), "sendrawtransaction", http.Params{"data": txHex})
+	txn, err := decodeTransaction(txHex)
 	if err != nil {
 		return err
 	}
-	fmt.Println(result.(string))
+
+	writeTransaction(os.Stdout, txn, getReferenceAmount)
 
 	return nil
 }
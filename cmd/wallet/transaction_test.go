@@ -0,0 +1,92 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/account"
+	"github.com/elastos/Elastos.ELA/core/contract"
+	"github.com/elastos/Elastos.ELA/core/contract/program"
+	"github.com/elastos/Elastos.ELA/core/types"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/crypto"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetWalletPaths(t *testing.T) {
+	c := newWalletContext(t, "--wallet", "a.dat, b.dat")
+	assert.Equal(t, []string{"a.dat", "b.dat"}, getWalletPaths(c))
+
+	c = newWalletContext(t, "--wallet", "a.dat", "--wallets", "b.dat,c.dat")
+	assert.Equal(t, []string{"b.dat", "c.dat"}, getWalletPaths(c))
+
+	c = newWalletContext(t)
+	assert.Equal(t, []string{account.KeystoreFileName}, getWalletPaths(c))
+}
+
+func TestSignTransaction_MultiSig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallet")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+
+	pwd := []byte("password")
+	var clients []*account.Client
+	var publicKeys []*crypto.PublicKey
+	for _, name := range []string{"wallet1.dat", "wallet2.dat"} {
+		client, err := account.Create(filepath.Join(dir, name), pwd)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		clients = append(clients, client)
+		publicKeys = append(publicKeys, client.GetMainAccount().PubKey())
+	}
+	_, publicKey, _ := crypto.GenerateKeyPair()
+	publicKeys = append(publicKeys, publicKey)
+
+	code, err := contract.CreateMultiSigRedeemScript(2, publicKeys)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	newTx := func() *types.Transaction {
+		return &types.Transaction{
+			Version:  types.TxVersion09,
+			TxType:   types.TransferAsset,
+			Payload:  &payload.TransferAsset{},
+			Programs: []*program.Program{{Code: code}},
+		}
+	}
+
+	// Sign a 2 of 3 transaction across two wallets.
+	txn := newTx()
+	haveSign, needSign, err := signTransaction(txn, clients)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, haveSign)
+	assert.Equal(t, 2, needSign)
+
+	// Sign in two steps by each wallet.
+	txn = newTx()
+	haveSign, needSign, err = signTransaction(txn, clients[:1])
+	assert.NoError(t, err)
+	assert.Equal(t, 1, haveSign)
+	assert.Equal(t, 2, needSign)
+	haveSign, needSign, err = signTransaction(txn, clients[1:])
+	assert.NoError(t, err)
+	assert.Equal(t, 2, haveSign)
+
+	// The same wallet can not contribute the signature twice.
+	txn = newTx()
+	_, _, err = signTransaction(txn, []*account.Client{clients[0],
+		clients[0]})
+	assert.Error(t, err)
+
+	// No more signature needed for a fully signed transaction.
+	txn = newTx()
+	_, _, err = signTransaction(txn, []*account.Client{clients[0],
+		clients[1], clients[0]})
+	assert.Error(t, err)
+}
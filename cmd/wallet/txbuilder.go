@@ -5,11 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"github.com/elastos/Elastos.ELA/account"
+	"github.com/elastos/Elastos.ELA/blockchain"
 	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/contract"
 	pg "github.com/elastos/Elastos.ELA/core/contract/program"
 	"github.com/elastos/Elastos.ELA/core/types"
 	"github.com/elastos/Elastos.ELA/core/types/outputpayload"
 	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/crypto"
 	"math/rand"
 	"strconv"
 
@@ -83,6 +86,105 @@ func CreateTransaction(c *cli.Context) error {
 	return nil
 }
 
+func CreateRegisterProducerTransaction(c *cli.Context) error {
+	walletPath := c.String("wallet")
+
+	feeStr := c.String("fee")
+	if feeStr == "" {
+		return errors.New("use --fee to specify transfer fee")
+	}
+
+	fee, err := common.StringToFixed64(feeStr)
+	if err != nil {
+		return errors.New("invalid transaction fee")
+	}
+
+	info, deposit, err := getProducerInfo(c)
+	if err != nil {
+		return err
+	}
+
+	programHash, err := contract.PublicKeyToDepositProgramHash(
+		info.OwnerPublicKey)
+	if err != nil {
+		return errors.New("invalid owner public key: " + err.Error())
+	}
+	depositAddress, err := programHash.ToAddress()
+	if err != nil {
+		return err
+	}
+
+	txn, err := createTransaction(walletPath, c.String("from"), fee,
+		uint32(0), &Transfer{depositAddress, deposit})
+	if err != nil {
+		return errors.New("create transaction failed: " + err.Error())
+	}
+	txn.TxType = types.RegisterProducer
+	txn.Payload = info
+
+	output(0, 0, txn)
+
+	return nil
+}
+
+// getProducerInfo parses the producer flags into an unsigned producer info
+// payload and the deposit amount.
+func getProducerInfo(c *cli.Context) (*payload.ProducerInfo,
+	*common.Fixed64, error) {
+	nickName := c.String("nickname")
+	if nickName == "" {
+		return nil, nil, errors.New("use --nickname to specify producer nickname")
+	}
+
+	nodePublicKey, err := getPublicKey(c, "nodepubkey")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ownerPublicKey, err := getPublicKey(c, "ownerpubkey")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	depositStr := c.String("deposit")
+	if depositStr == "" {
+		return nil, nil, errors.New("use --deposit to specify deposit amount")
+	}
+	deposit, err := common.StringToFixed64(depositStr)
+	if err != nil {
+		return nil, nil, errors.New("invalid deposit amount")
+	}
+	if *deposit < blockchain.MinDepositAmount {
+		return nil, nil, fmt.Errorf("deposit amount should not be less "+
+			"than %s", common.Fixed64(blockchain.MinDepositAmount))
+	}
+
+	return &payload.ProducerInfo{
+		OwnerPublicKey: ownerPublicKey,
+		NodePublicKey:  nodePublicKey,
+		NickName:       nickName,
+		Url:            c.String("url"),
+		Location:       c.Uint64("location"),
+	}, deposit, nil
+}
+
+// getPublicKey decodes the hex string public key specified by the given flag.
+func getPublicKey(c *cli.Context, name string) ([]byte, error) {
+	publicKeyStr := c.String(name)
+	if publicKeyStr == "" {
+		return nil, fmt.Errorf("use --%s to specify public key", name)
+	}
+	publicKey, err := common.HexStringToBytes(publicKeyStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s, %s", name, err)
+	}
+	if len(publicKey) != crypto.COMPRESSEDLEN {
+		return nil, fmt.Errorf("invalid %s length %d, expect %d", name,
+			len(publicKey), crypto.COMPRESSEDLEN)
+	}
+	return publicKey, nil
+}
+
 func createTransaction(walletPath string, from string, fee *common.Fixed64, lockedUntil uint32, outputs ...*Transfer) (*types.Transaction, error) {
 	// Check output
 	if len(outputs) == 0 {
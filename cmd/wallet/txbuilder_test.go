@@ -0,0 +1,109 @@
+package wallet
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+const (
+	testNodePublicKey  = "0306e3deefee78e0e25f88e98f1f3290ccea98f08dd3a890616755f1a066c4b9b8"
+	testOwnerPublicKey = "02b611f07341d5ddce51b5c4366aca7b889cfe0993bd63fd47e944507292ea08dd"
+)
+
+func newProducerContext(t *testing.T, args ...string) *cli.Context {
+	set := flag.NewFlagSet("buildregtx", flag.ContinueOnError)
+	for _, f := range []cli.Flag{
+		ProducerNickNameFlag,
+		ProducerNodePublicKeyFlag,
+		ProducerOwnerPublicKeyFlag,
+		ProducerUrlFlag,
+		ProducerLocationFlag,
+		ProducerDepositFlag,
+	} {
+		f.Apply(set)
+	}
+	if !assert.NoError(t, set.Parse(args)) {
+		t.FailNow()
+	}
+	return cli.NewContext(nil, set, nil)
+}
+
+func newWalletContext(t *testing.T, args ...string) *cli.Context {
+	set := flag.NewFlagSet("signtx", flag.ContinueOnError)
+	AccountWalletFlag.Apply(set)
+	AccountWalletsFlag.Apply(set)
+	if !assert.NoError(t, set.Parse(args)) {
+		t.FailNow()
+	}
+	return cli.NewContext(nil, set, nil)
+}
+
+func TestGetProducerInfo(t *testing.T) {
+	c := newProducerContext(t,
+		"--nickname", "producer1",
+		"--nodepubkey", testNodePublicKey,
+		"--ownerpubkey", testOwnerPublicKey,
+		"--url", "http://www.elastos.org",
+		"--location", "86",
+		"--deposit", "5000",
+	)
+	info, deposit, err := getProducerInfo(c)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "producer1", info.NickName)
+	assert.Equal(t, testNodePublicKey,
+		common.BytesToHexString(info.NodePublicKey))
+	assert.Equal(t, testOwnerPublicKey,
+		common.BytesToHexString(info.OwnerPublicKey))
+	assert.Equal(t, "http://www.elastos.org", info.Url)
+	assert.Equal(t, uint64(86), info.Location)
+	assert.Equal(t, common.Fixed64(5000*100000000), *deposit)
+}
+
+func TestGetProducerInfo_RequiredFlags(t *testing.T) {
+	required := map[string]string{
+		"nickname":    "producer1",
+		"nodepubkey":  testNodePublicKey,
+		"ownerpubkey": testOwnerPublicKey,
+		"deposit":     "5000",
+	}
+	for missing := range required {
+		var args []string
+		for name, value := range required {
+			if name != missing {
+				args = append(args, "--"+name, value)
+			}
+		}
+		_, _, err := getProducerInfo(newProducerContext(t, args...))
+		assert.Error(t, err, "missing --%s should be rejected", missing)
+		assert.Contains(t, err.Error(), "--"+missing)
+	}
+}
+
+func TestGetProducerInfo_ShortPublicKey(t *testing.T) {
+	c := newProducerContext(t,
+		"--nickname", "producer1",
+		"--nodepubkey", testNodePublicKey[:64],
+		"--ownerpubkey", testOwnerPublicKey,
+		"--deposit", "5000",
+	)
+	_, _, err := getProducerInfo(c)
+	assert.EqualError(t, err, "invalid nodepubkey length 32, expect 33")
+}
+
+func TestGetProducerInfo_InsufficientDeposit(t *testing.T) {
+	c := newProducerContext(t,
+		"--nickname", "producer1",
+		"--nodepubkey", testNodePublicKey,
+		"--ownerpubkey", testOwnerPublicKey,
+		"--deposit", "4999.9",
+	)
+	_, _, err := getProducerInfo(c)
+	assert.Error(t, err)
+}
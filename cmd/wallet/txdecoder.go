@@ -0,0 +1,240 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	cmdcom "github.com/elastos/Elastos.ELA/cmd/common"
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types"
+	"github.com/elastos/Elastos.ELA/core/types/outputpayload"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/utils/http"
+	"github.com/elastos/Elastos.ELA/utils/http/jsonrpc"
+)
+
+// inputAmount returns the amount of the output referenced by the input.
+type inputAmount func(input *types.Input) (common.Fixed64, error)
+
+// decodeTransaction deserializes the hex string content into a transaction.
+func decodeTransaction(txHex string) (*types.Transaction, error) {
+	rawData, err := common.HexStringToBytes(txHex)
+	if err != nil {
+		return nil, errors.New("decode transaction content failed")
+	}
+
+	var txn types.Transaction
+	if err := txn.Deserialize(bytes.NewReader(rawData)); err != nil {
+		return nil, errors.New("deserialize transaction failed: " + err.Error())
+	}
+	return &txn, nil
+}
+
+// getReferenceAmount queries the referenced output amount of the input from
+// the local node.
+func getReferenceAmount(input *types.Input) (common.Fixed64, error) {
+	result, err := jsonrpc.CallParams(cmdcom.LocalServer(),
+		"getrawtransaction", http.Params{
+			"txid":    input.Previous.TxID.String(),
+			"verbose": true,
+		})
+	if err != nil {
+		return 0, err
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return 0, err
+	}
+	var txInfo struct {
+		Outputs []struct {
+			Value string `json:"value"`
+			Index uint16 `json:"n"`
+		} `json:"vout"`
+	}
+	if err := json.Unmarshal(data, &txInfo); err != nil {
+		return 0, err
+	}
+	for _, output := range txInfo.Outputs {
+		if output.Index == input.Previous.Index {
+			amount, err := common.StringToFixed64(output.Value)
+			if err != nil {
+				return 0, err
+			}
+			return *amount, nil
+		}
+	}
+	return 0, fmt.Errorf("reference output %s:%d not found",
+		input.Previous.TxID.String(), input.Previous.Index)
+}
+
+// getFee returns the fee of the transaction, the referenced output amounts of
+// inputs are given by the getAmount function.
+func getFee(txn *types.Transaction, getAmount inputAmount) (common.Fixed64,
+	error) {
+	if getAmount == nil {
+		return 0, errors.New("input amounts unavailable")
+	}
+	var inputTotal, outputTotal common.Fixed64
+	for _, input := range txn.Inputs {
+		amount, err := getAmount(input)
+		if err != nil {
+			return 0, err
+		}
+		inputTotal += amount
+	}
+	for _, output := range txn.Outputs {
+		outputTotal += output.Value
+	}
+	return inputTotal - outputTotal, nil
+}
+
+// writeTransaction writes the transaction content in readable form.
+func writeTransaction(w io.Writer, txn *types.Transaction,
+	getAmount inputAmount) {
+	fmt.Fprintln(w, "Hash:", txn.Hash().String())
+	fmt.Fprintln(w, "Version:", txn.Version)
+	fmt.Fprintln(w, "Type:", txn.TxType.Name())
+	fmt.Fprintln(w, "PayloadVersion:", txn.PayloadVersion)
+	fmt.Fprintln(w, "LockTime:", txn.LockTime)
+
+	fmt.Fprintf(w, "Inputs: %d\n", len(txn.Inputs))
+	for i, input := range txn.Inputs {
+		fmt.Fprintf(w, "  [%d] %s:%d\n", i, input.Previous.TxID.String(),
+			input.Previous.Index)
+	}
+
+	fmt.Fprintf(w, "Outputs: %d\n", len(txn.Outputs))
+	for i, output := range txn.Outputs {
+		address, err := output.ProgramHash.ToAddress()
+		if err != nil {
+			address = output.ProgramHash.String()
+		}
+		fmt.Fprintf(w, "  [%d] %s %s", i, address, output.Value.String())
+		if output.OutputLock > 0 {
+			fmt.Fprintf(w, " (locked until %d)", output.OutputLock)
+		}
+		fmt.Fprintln(w)
+		writeOutputPayload(w, output)
+	}
+
+	if fee, err := getFee(txn, getAmount); err != nil {
+		fmt.Fprintf(w, "Fee: unknown (%s)\n", err)
+	} else {
+		fmt.Fprintln(w, "Fee:", fee.String())
+	}
+
+	writePayload(w, txn.Payload)
+}
+
+func writeOutputPayload(w io.Writer, output *types.Output) {
+	vote, ok := output.Payload.(*outputpayload.VoteOutput)
+	if !ok {
+		return
+	}
+	fmt.Fprintf(w, "      Vote version %d\n", vote.Version)
+	for _, content := range vote.Contents {
+		fmt.Fprintf(w, "      %s: %d candidates\n",
+			voteTypeName(content.VoteType), len(content.Candidates))
+		for _, candidate := range content.Candidates {
+			fmt.Fprintln(w, "        "+common.BytesToHexString(candidate))
+		}
+	}
+}
+
+func writePayload(w io.Writer, p types.Payload) {
+	switch p := p.(type) {
+	case *payload.ProducerInfo:
+		fmt.Fprintln(w, "Producer:")
+		fmt.Fprintln(w, "  OwnerPublicKey:",
+			common.BytesToHexString(p.OwnerPublicKey))
+		fmt.Fprintln(w, "  NodePublicKey:",
+			common.BytesToHexString(p.NodePublicKey))
+		fmt.Fprintln(w, "  NickName:", p.NickName)
+		fmt.Fprintln(w, "  Url:", p.Url)
+		fmt.Fprintln(w, "  Location:", p.Location)
+		fmt.Fprintln(w, "  NetAddress:", p.NetAddress)
+		fmt.Fprintln(w, "  Signed:", len(p.Signature) > 0)
+
+	case *payload.ProcessProducer:
+		fmt.Fprintln(w, "Producer:")
+		fmt.Fprintln(w, "  OwnerPublicKey:",
+			common.BytesToHexString(p.OwnerPublicKey))
+		fmt.Fprintln(w, "  Signed:", len(p.Signature) > 0)
+
+	case *payload.DPOSIllegalProposals:
+		fmt.Fprintln(w, "Illegal proposals:")
+		writeProposalEvidence(w, &p.Evidence)
+		writeProposalEvidence(w, &p.CompareEvidence)
+
+	case *payload.DPOSIllegalVotes:
+		fmt.Fprintln(w, "Illegal votes:")
+		writeVoteEvidence(w, &p.Evidence)
+		writeVoteEvidence(w, &p.CompareEvidence)
+
+	case *payload.DPOSIllegalBlocks:
+		fmt.Fprintln(w, "Illegal blocks:")
+		fmt.Fprintln(w, "  Height:", p.BlockHeight)
+		writeBlockEvidence(w, &p.Evidence)
+		writeBlockEvidence(w, &p.CompareEvidence)
+
+	case *payload.SidechainIllegalData:
+		fmt.Fprintln(w, "Illegal sidechain data:")
+		fmt.Fprintln(w, "  Type:", p.IllegalType)
+		fmt.Fprintln(w, "  Height:", p.Height)
+		fmt.Fprintln(w, "  Signer:", common.BytesToHexString(p.IllegalSigner))
+		fmt.Fprintln(w, "  GenesisBlockAddress:", p.GenesisBlockAddress)
+		fmt.Fprintln(w, "  Evidence:", p.Evidence.DataHash.String())
+		fmt.Fprintln(w, "  CompareEvidence:",
+			p.CompareEvidence.DataHash.String())
+
+	case *payload.InactiveArbitrators:
+		fmt.Fprintln(w, "Inactive arbitrators:")
+		fmt.Fprintln(w, "  Height:", p.BlockHeight)
+		fmt.Fprintln(w, "  Sponsor:", common.BytesToHexString(p.Sponsor))
+		fmt.Fprintln(w, "  Arbitrators:")
+		for _, arbiter := range p.Arbitrators {
+			fmt.Fprintln(w, "    "+common.BytesToHexString(arbiter))
+		}
+	}
+}
+
+func writeProposalEvidence(w io.Writer, e *payload.ProposalEvidence) {
+	fmt.Fprintf(w, "  Height %d proposal %s\n", e.BlockHeight,
+		e.Proposal.Hash().String())
+	fmt.Fprintln(w, "    Sponsor:", common.BytesToHexString(e.Proposal.Sponsor))
+	fmt.Fprintln(w, "    BlockHash:", e.Proposal.BlockHash.String())
+	fmt.Fprintln(w, "    ViewOffset:", e.Proposal.ViewOffset)
+}
+
+func writeBlockEvidence(w io.Writer, e *payload.BlockEvidence) {
+	fmt.Fprintln(w, "  Signers:")
+	for _, signer := range e.Signers {
+		fmt.Fprintln(w, "    "+common.BytesToHexString(signer))
+	}
+}
+
+func writeVoteEvidence(w io.Writer, e *payload.VoteEvidence) {
+	fmt.Fprintf(w, "  Height %d vote %s\n", e.BlockHeight,
+		e.Vote.Hash().String())
+	fmt.Fprintln(w, "    Signer:", common.BytesToHexString(e.Vote.Signer))
+	fmt.Fprintln(w, "    ProposalHash:", e.Vote.ProposalHash.String())
+	fmt.Fprintln(w, "    Accept:", e.Vote.Accept)
+}
+
+func voteTypeName(voteType outputpayload.VoteType) string {
+	switch voteType {
+	case outputpayload.Delegate:
+		return "Delegate"
+	case outputpayload.CRC:
+		return "CRC"
+	case outputpayload.Abstain:
+		return "Abstain"
+	case outputpayload.Candidate:
+		return "Candidate"
+	default:
+		return fmt.Sprintf("Unknown(%d)", voteType)
+	}
+}
@@ -0,0 +1,124 @@
+package wallet
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/contract/program"
+	"github.com/elastos/Elastos.ELA/core/types"
+	"github.com/elastos/Elastos.ELA/core/types/outputpayload"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeTransaction_Vote(t *testing.T) {
+	candidate1, _ := common.HexStringToBytes(testNodePublicKey)
+	candidate2, _ := common.HexStringToBytes(testOwnerPublicKey)
+	txn := &types.Transaction{
+		Version: types.TxVersion09,
+		TxType:  types.TransferAsset,
+		Payload: &payload.TransferAsset{},
+		Inputs: []*types.Input{
+			{
+				Previous: types.OutPoint{
+					TxID:  common.Uint256{1},
+					Index: 1,
+				},
+				Sequence: 4294967295,
+			},
+		},
+		Outputs: []*types.Output{
+			{
+				AssetID: common.Uint256{},
+				Value:   common.Fixed64(100000000),
+				Type:    types.OTVote,
+				Payload: &outputpayload.VoteOutput{
+					Version: 0,
+					Contents: []outputpayload.VoteContent{
+						{
+							VoteType: outputpayload.Delegate,
+							Candidates: [][]byte{
+								candidate1,
+								candidate2,
+							},
+						},
+					},
+				},
+			},
+		},
+		Programs: []*program.Program{},
+	}
+	buf := new(bytes.Buffer)
+	if !assert.NoError(t, txn.Serialize(buf)) {
+		t.FailNow()
+	}
+	txHex := common.BytesToHexString(buf.Bytes())
+
+	decoded, err := decodeTransaction(txHex)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, txn.Hash(), decoded.Hash())
+
+	out := new(bytes.Buffer)
+	writeTransaction(out, decoded, func(input *types.Input) (
+		common.Fixed64, error) {
+		return common.Fixed64(100010000), nil
+	})
+	content := out.String()
+	assert.True(t, strings.Contains(content, "Type: TransferAsset"))
+	assert.True(t, strings.Contains(content, "Inputs: 1"))
+	assert.True(t, strings.Contains(content, "Outputs: 1"))
+	assert.True(t, strings.Contains(content, "Delegate: 2 candidates"))
+	assert.True(t, strings.Contains(content, testNodePublicKey))
+	assert.True(t, strings.Contains(content, testOwnerPublicKey))
+	assert.True(t, strings.Contains(content, "Fee: 0.0001"))
+
+	// Fee is unknown without input amounts.
+	out.Reset()
+	writeTransaction(out, decoded, nil)
+	assert.True(t, strings.Contains(out.String(), "Fee: unknown"))
+}
+
+func TestDecodeTransaction_RegisterProducer(t *testing.T) {
+	ownerPublicKey, _ := common.HexStringToBytes(testOwnerPublicKey)
+	nodePublicKey, _ := common.HexStringToBytes(testNodePublicKey)
+	txn := &types.Transaction{
+		Version: types.TxVersion09,
+		TxType:  types.RegisterProducer,
+		Payload: &payload.ProducerInfo{
+			OwnerPublicKey: ownerPublicKey,
+			NodePublicKey:  nodePublicKey,
+			NickName:       "producer1",
+			Url:            "http://www.elastos.org",
+			Location:       86,
+		},
+		Programs: []*program.Program{},
+	}
+	buf := new(bytes.Buffer)
+	if !assert.NoError(t, txn.Serialize(buf)) {
+		t.FailNow()
+	}
+
+	decoded, err := decodeTransaction(common.BytesToHexString(buf.Bytes()))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	out := new(bytes.Buffer)
+	writeTransaction(out, decoded, nil)
+	content := out.String()
+	assert.True(t, strings.Contains(content, "Type: RegisterProducer"))
+	assert.True(t, strings.Contains(content, "NickName: producer1"))
+	assert.True(t, strings.Contains(content, "Location: 86"))
+	assert.True(t, strings.Contains(content, "Signed: false"))
+}
+
+func TestDecodeTransaction_InvalidHex(t *testing.T) {
+	_, err := decodeTransaction("zz")
+	assert.Error(t, err)
+	_, err = decodeTransaction("0901")
+	assert.Error(t, err)
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"math"
 	"math/big"
 	"time"
 
@@ -99,10 +100,34 @@ var DefaultParams = Params{
 	MaxInactiveRounds:        720 * 2,
 	InactivePenalty:          100 * 100000000,
 	EmergencyInactivePenalty: 500 * 100000000,
+	IllegalPenalty:           5000 * 100000000,
 	InactiveEliminateCount:   12,
 	GeneralArbiters:          24,
 	CandidateArbiters:        72,
 	PreConnectOffset:         360,
+	CRDepositLockupBlocks:    2160,
+	StateHistoryCapacity:     10,
+	JailBlocks:               720 * 7,
+	AbstainVoteHeight:        math.MaxUint32,
+	CandidateVoteHeight:      math.MaxUint32,
+	InactiveTxLimitHeight:    math.MaxUint32,
+	ProducerBlocklistHeight:  math.MaxUint32,
+	MinConfirmRewardHeight:   math.MaxUint32,
+	ShuffleArbitersHeight:    math.MaxUint32,
+	OwnerActivateHeight:      math.MaxUint32,
+	ProducerInfoCheckHeight:  math.MaxUint32,
+	CRCRewardHeight:          math.MaxUint32,
+	VotesCapHeight:           math.MaxUint32,
+	TxLimitExemptHeight:      math.MaxUint32,
+	NicknameReserveHeight:    math.MaxUint32,
+	ActivateExpiryHeight:     math.MaxUint32,
+	RewardRoundingHeight:     math.MaxUint32,
+	InactivityPauseHeight:    math.MaxUint32,
+	IllegalBlockCheckHeight:  math.MaxUint32,
+	IllegalPenaltyHeight:     math.MaxUint32,
+	SelfVoteCapHeight:        math.MaxUint32,
+	JailHeight:               math.MaxUint32,
+	CRCDepositLockupHeight:   math.MaxUint32,
 }
 
 // TestNet returns the network parameters for the test network.
@@ -284,13 +309,58 @@ type Params struct {
 	// elected producers participate in DPOS consensus.
 	PublicDPOSHeight uint32
 
+	// AbstainVoteHeight indicates the height abstain votes are accepted from.
+	AbstainVoteHeight uint32
+
+	// CandidateVoteHeight indicates the height candidate votes are accepted
+	// from.
+	CandidateVoteHeight uint32
+
 	// CRCArbiters defines the fixed CRC arbiters producing the block.
 	CRCArbiters []CRCArbiter
 
+	// CRCArbiterSchedule defines the CRC arbiters replacing CRCArbiters on the
+	// first arbiters change from the given heights.
+	CRCArbiterSchedule map[uint32][]CRCArbiter
+
+	// CRCRewardRecipients defines the addresses the block confirm rewards of
+	// CRC arbiters are split among by weight, the rewards go to each CRC
+	// arbiter if not set.  Addresses of recipients should be distinct.
+	CRCRewardRecipients []CRCRewardRecipient
+
+	// CRCRewardHeight indicates the height from which the block confirm
+	// rewards of CRC arbiters are split among CRCRewardRecipients.
+	CRCRewardHeight uint32
+
+	// RewardRoundingMode defines how the change left by rounding down the
+	// DPOS rewards is handled, RoundFloorWithChange gives the change to the
+	// merge miner while RoundLargestRemainder assigns it to the voted
+	// producers.
+	RewardRoundingMode RewardRoundingMode
+
+	// RewardRoundingHeight indicates the height from which the change of DPOS
+	// rewards is handled by RewardRoundingMode, the change goes to the merge
+	// miner before it.
+	RewardRoundingHeight uint32
+
 	// PreConnectOffset defines the offset blocks to pre-connect to the block
 	// producers.
 	PreConnectOffset uint32
 
+	// ExtraPreConnectOffset defines the additional offset blocks beyond
+	// PreConnectOffset to begin connecting to arbiters, for high-latency
+	// networks.
+	ExtraPreConnectOffset uint32
+
+	// InactiveTxLimitHeight indicates the height from which the inactive
+	// arbitrators transactions a block contains are limited by
+	// MaxInactivePayloadsPerHeight.
+	InactiveTxLimitHeight uint32
+
+	// MaxInactivePayloadsPerHeight defines the maximum inactive arbitrators
+	// transactions a block can contain, 0 means no limit.
+	MaxInactivePayloadsPerHeight uint32
+
 	// GeneralArbiters defines the number of general(no-CRC) arbiters.
 	GeneralArbiters int
 
@@ -301,6 +371,20 @@ type Params struct {
 	// takes penalty.
 	MaxInactiveRounds uint32
 
+	// InactivityPauseHeight indicates the height from which missed signings
+	// are not counted as inactivity while the current arbiters are fewer than
+	// GeneralArbiters plus the CRC arbiters.
+	InactivityPauseHeight uint32
+
+	// IllegalBlockCheckHeight indicates the height from which signers of the
+	// illegal blocks evidence should be arbiters on the evidence height.
+	IllegalBlockCheckHeight uint32
+
+	// NearInactiveRatio defines the ratio of MaxInactiveRounds an arbiter's
+	// consecutive missed signings reach to warn it's near inactive, zero
+	// means no warning.
+	NearInactiveRatio float64
+
 	// InactivePenalty defines the penalty amount the producer takes.
 	InactivePenalty common.Fixed64
 
@@ -310,6 +394,167 @@ type Params struct {
 	// EmergencyInactivePenalty defines the penalty amount the emergency
 	// producer takes.
 	EmergencyInactivePenalty common.Fixed64
+
+	// IllegalPenalty defines the penalty amount the producer takes when it's
+	// found doing illegal behaviors.
+	IllegalPenalty common.Fixed64
+
+	// IllegalPenaltyHeight indicates the height from which producers found
+	// doing illegal behaviors take IllegalPenalty.
+	IllegalPenaltyHeight uint32
+
+	// MinProducerDeposit defines the minimum deposit a producer should keep
+	// after deducting penalties, producers below it are undercollateralized.
+	MinProducerDeposit common.Fixed64
+
+	// MaxProducerNickNameLength defines the maximum length of a producer's
+	// nickname, the nickname should also be printable, zero means no check.
+	MaxProducerNickNameLength uint32
+
+	// MaxProducerUrlLength defines the maximum length of a producer's url, the
+	// url should also be a well-formed http(s) url, zero means no check.
+	MaxProducerUrlLength uint32
+
+	// ProducerInfoCheckHeight indicates the height from which the nickname
+	// and url of producers are checked by MaxProducerNickNameLength and
+	// MaxProducerUrlLength.
+	ProducerInfoCheckHeight uint32
+
+	// NicknameReservationBlocks defines the blocks a canceled producer's
+	// nickname keeps reserved, nicknames of illegal producers are reserved
+	// permanently, zero means nicknames are freed immediately.
+	NicknameReservationBlocks uint32
+
+	// NicknameReserveHeight indicates the height from which the nicknames of
+	// canceled and illegal producers are reserved.
+	NicknameReserveHeight uint32
+
+	// MaxSelfVoteNumerator and MaxSelfVoteDenominator define the maximum ratio
+	// of self votes in a producer's votes, votes funded by the owner address
+	// are self votes.  No limit if the ratio is not in (0, 1).
+	MaxSelfVoteNumerator   uint32
+	MaxSelfVoteDenominator uint32
+
+	// SelfVoteCapHeight indicates the height from which self votes are limited
+	// by the max self vote ratio.
+	SelfVoteCapHeight uint32
+
+	// MaxVotesPerProducer defines the maximum votes of a producer counted in
+	// the round votes for rewards, zero means no cap.
+	MaxVotesPerProducer common.Fixed64
+
+	// VotesCapHeight indicates the height from which MaxVotesPerProducer
+	// takes effect.
+	VotesCapHeight uint32
+
+	// TxLimitExemptHeight indicates the height from which DPOS special
+	// transactions are exempted from the max transactions per block.
+	TxLimitExemptHeight uint32
+
+	// CRDepositLockupBlocks defines the blocks a canceled producer's deposit
+	// keeps locked before it can be returned.
+	CRDepositLockupBlocks uint32
+
+	// CRCDepositLockupBlocks defines the blocks a canceled producer's deposit
+	// keeps locked if the producer was a CRC arbiter when it was canceled,
+	// zero means the same as CRDepositLockupBlocks.
+	CRCDepositLockupBlocks uint32
+
+	// CRCDepositLockupHeight indicates the height from which producers
+	// canceled as CRC arbiters take CRCDepositLockupBlocks.
+	CRCDepositLockupHeight uint32
+
+	// JailInactiveCount defines the times a producer has been inactive before
+	// it will be jailed, zero means producers will never be jailed.
+	JailInactiveCount uint32
+
+	// JailBlocks defines the blocks a jailed producer keeps excluded from
+	// arbiters selection before it's released.
+	JailBlocks uint32
+
+	// JailHeight indicates the height from which producers are jailed by
+	// JailInactiveCount.
+	JailHeight uint32
+
+	// ActivateRequestExpiry defines the blocks an activate producer request
+	// keeps valid if not fulfilled, zero means never expire.  Values below the
+	// confirmations an activation needs are raised to it.
+	ActivateRequestExpiry uint32
+
+	// ActivateExpiryHeight indicates the height from which
+	// ActivateRequestExpiry takes effect.
+	ActivateExpiryHeight uint32
+
+	// ShuffleArbitersHeight indicates the height from which the arbiters
+	// order of each round will be shuffled by the hash of the block on the
+	// change height instead of sorted by public key.
+	ShuffleArbitersHeight uint32
+
+	// StateHistoryCapacity defines the maximum block changes kept by the DPOS
+	// state history for rollback and history query.
+	StateHistoryCapacity int
+
+	// FirstViewTimeoutFactor defines the view change timeout factor of the
+	// first inactive arbiters elimination in one consensus, the timeout view
+	// offset is the factor multiplied by arbiters count, zero means 1.
+	FirstViewTimeoutFactor uint32
+
+	// SubsequentViewTimeoutFactor defines the view change timeout factor
+	// added by each inactive arbiters elimination after the first one in one
+	// consensus, zero means 240.
+	SubsequentViewTimeoutFactor uint32
+
+	// MinConfirmRewardHeight indicates the height from which
+	// MinBlockConfirmReward takes effect.
+	MinConfirmRewardHeight uint32
+
+	// OwnerActivateHeight indicates the height from which producers can only
+	// be activated by the owner public key.
+	OwnerActivateHeight uint32
+
+	// MinBlockConfirmReward defines the minimum block confirm reward of each
+	// arbiter, it's drawn from the DPOS reward before rewards by votes.
+	MinBlockConfirmReward common.Fixed64
+
+	// ProducerBlocklistHeight indicates the height from which ProducerBlocklist
+	// takes effect.
+	ProducerBlocklistHeight uint32
+
+	// ProducerBlocklist defines the owner public keys in hex string format
+	// that are not allowed to register, update or activate a producer.
+	ProducerBlocklist []string
+}
+
+// CRCRewardRecipient is a recipient address of CRC rewards and it's weight.
+type CRCRewardRecipient struct {
+	Address common.Uint168
+	Weight  int
+}
+
+// RewardRoundingMode indicates how the change of DPOS reward distribution is
+// handled.
+type RewardRoundingMode byte
+
+const (
+	// RoundFloorWithChange rounds down each reward and the change goes to the
+	// merge miner.
+	RoundFloorWithChange RewardRoundingMode = iota
+
+	// RoundLargestRemainder rounds down each reward and assigns the change
+	// one sela at a time to the producers in descending order of the
+	// fractional part of their rewards until the change is exhausted.
+	RoundLargestRemainder
+)
+
+func (m RewardRoundingMode) String() string {
+	switch m {
+	case RoundFloorWithChange:
+		return "FloorWithChange"
+	case RoundLargestRemainder:
+		return "LargestRemainder"
+	default:
+		return "Unknown"
+	}
 }
 
 func rewardPerBlock(targetTimePerBlock time.Duration) common.Fixed64 {
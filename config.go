@@ -104,6 +104,10 @@ func loadConfigParams() *config.ConfigParams {
 		activeNetParams.PreConnectOffset =
 			cfg.ArbiterConfiguration.PreConnectOffset
 	}
+	if cfg.ArbiterConfiguration.ExtraPreConnectOffset > 0 {
+		activeNetParams.ExtraPreConnectOffset =
+			cfg.ArbiterConfiguration.ExtraPreConnectOffset
+	}
 	if cfg.ArbiterConfiguration.CandidatesCount > 0 {
 		activeNetParams.CandidateArbiters =
 			cfg.ArbiterConfiguration.CandidatesCount
@@ -112,10 +116,30 @@ func loadConfigParams() *config.ConfigParams {
 		activeNetParams.MaxInactiveRounds =
 			cfg.ArbiterConfiguration.MaxInactiveRounds
 	}
+	if cfg.ArbiterConfiguration.NearInactiveRatio > 0 {
+		activeNetParams.NearInactiveRatio =
+			cfg.ArbiterConfiguration.NearInactiveRatio
+	}
 	if cfg.ArbiterConfiguration.InactivePenalty > 0 {
 		activeNetParams.InactivePenalty =
 			cfg.ArbiterConfiguration.InactivePenalty
 	}
+	if cfg.ArbiterConfiguration.MinProducerDeposit > 0 {
+		activeNetParams.MinProducerDeposit =
+			cfg.ArbiterConfiguration.MinProducerDeposit
+	}
+	if cfg.ArbiterConfiguration.StateHistoryCapacity > 0 {
+		activeNetParams.StateHistoryCapacity =
+			cfg.ArbiterConfiguration.StateHistoryCapacity
+	}
+	if cfg.ArbiterConfiguration.FirstViewTimeoutFactor > 0 {
+		activeNetParams.FirstViewTimeoutFactor =
+			cfg.ArbiterConfiguration.FirstViewTimeoutFactor
+	}
+	if cfg.ArbiterConfiguration.SubsequentViewTimeoutFactor > 0 {
+		activeNetParams.SubsequentViewTimeoutFactor =
+			cfg.ArbiterConfiguration.SubsequentViewTimeoutFactor
+	}
 	if cfg.ArbiterConfiguration.EmergencyInactivePenalty > 0 {
 		activeNetParams.EmergencyInactivePenalty =
 			cfg.ArbiterConfiguration.EmergencyInactivePenalty
@@ -16,6 +16,14 @@ const (
 const (
 	Delegate VoteType = 0x00
 	CRC      VoteType = 0x01
+
+	// Abstain indicates the votes count toward turnout but not toward any
+	// producer, an abstain vote content has no candidates.
+	Abstain VoteType = 0x02
+
+	// Candidate indicates the votes back producers to be candidates, they are
+	// counted separately from Delegate votes.
+	Candidate VoteType = 0x03
 )
 
 type VoteType byte
@@ -23,6 +31,8 @@ type VoteType byte
 var VoteTypes = []VoteType{
 	Delegate,
 	CRC,
+	Abstain,
+	Candidate,
 }
 
 type VoteContent struct {
@@ -140,11 +150,19 @@ func (o *VoteOutput) Validate() error {
 		}
 		typeMap[content.VoteType] = struct{}{}
 
+		// abstain vote can not have candidates or combine with other votes
+		if content.VoteType == Abstain {
+			if len(content.Candidates) != 0 || len(o.Contents) != 1 {
+				return errors.New("invalid abstain vote")
+			}
+			continue
+		}
+
 		if len(content.Candidates) == 0 || len(content.Candidates) > MaxVoteProducersPerTransaction {
 			return errors.New("invalid public key count")
 		}
-		// only use Delegate as a vote type for now
-		if content.VoteType != Delegate {
+		// only use Delegate and Candidate as vote types for now
+		if content.VoteType != Delegate && content.VoteType != Candidate {
 			return errors.New("invalid vote type")
 		}
 
@@ -163,4 +163,44 @@ func TestVoteOutput_Validate(t *testing.T) {
 	}
 	err = vo4.Validate()
 	assert.EqualError(t, err, "invalid vote type")
+
+	// vo5
+	vo5 := VoteOutput{
+		Version: 0,
+		Contents: []VoteContent{
+			{VoteType: Abstain},
+		},
+	}
+	assert.NoError(t, vo5.Validate())
+
+	// vo6
+	vo6 := VoteOutput{
+		Version: 0,
+		Contents: []VoteContent{
+			{VoteType: Abstain, Candidates: [][]byte{candidate1}},
+		},
+	}
+	err = vo6.Validate()
+	assert.EqualError(t, err, "invalid abstain vote")
+
+	// vo7
+	vo7 := VoteOutput{
+		Version: 0,
+		Contents: []VoteContent{
+			content2,
+			{VoteType: Abstain},
+		},
+	}
+	err = vo7.Validate()
+	assert.EqualError(t, err, "invalid abstain vote")
+
+	// vo8
+	vo8 := VoteOutput{
+		Version: 0,
+		Contents: []VoteContent{
+			content2,
+			{VoteType: Candidate, Candidates: [][]byte{candidate1}},
+		},
+	}
+	assert.NoError(t, vo8.Validate())
 }
@@ -1,6 +1,7 @@
 package payload
 
 import (
+	"bytes"
 	"io"
 
 	"github.com/elastos/Elastos.ELA/common"
@@ -57,3 +58,11 @@ func (p *Confirm) Deserialize(r io.Reader) error {
 
 	return nil
 }
+
+// Hash returns the hash of the serialized confirm, it's not cached for the
+// proposal and votes may be changed directly.
+func (p *Confirm) Hash() common.Uint256 {
+	buf := new(bytes.Buffer)
+	p.Serialize(buf)
+	return common.Uint256(common.Sha256D(buf.Bytes()))
+}
@@ -0,0 +1,74 @@
+package payload
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/common"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfirm_SerializeDeserialize(t *testing.T) {
+	sponsor := make([]byte, 33)
+	rand.Read(sponsor)
+	var blockHash common.Uint256
+	rand.Read(blockHash[:])
+
+	confirm := &Confirm{
+		Proposal: DPOSProposal{
+			Sponsor:    sponsor,
+			BlockHash:  blockHash,
+			ViewOffset: 1,
+			Sign:       make([]byte, 64),
+		},
+	}
+	rand.Read(confirm.Proposal.Sign)
+	for i := 0; i < 3; i++ {
+		vote := DPOSProposalVote{
+			ProposalHash: confirm.Proposal.Hash(),
+			Signer:       make([]byte, 33),
+			Accept:       true,
+			Sign:         make([]byte, 64),
+		}
+		rand.Read(vote.Signer)
+		rand.Read(vote.Sign)
+		if !assert.True(t, confirm.TryAppend(vote)) {
+			t.FailNow()
+		}
+	}
+	hash := confirm.Hash()
+
+	buf := new(bytes.Buffer)
+	if !assert.NoError(t, confirm.Serialize(buf)) {
+		t.FailNow()
+	}
+	var c Confirm
+	if !assert.NoError(t, c.Deserialize(buf)) {
+		t.FailNow()
+	}
+	assert.Equal(t, hash, c.Hash())
+	assert.Equal(t, confirm.Proposal.Hash(), c.Proposal.Hash())
+	if !assert.Equal(t, len(confirm.Votes), len(c.Votes)) {
+		t.FailNow()
+	}
+	for i := range c.Votes {
+		assert.Equal(t, confirm.Votes[i].Hash(), c.Votes[i].Hash())
+	}
+
+	// Appending a vote changes the hash.
+	vote := DPOSProposalVote{
+		ProposalHash: c.Proposal.Hash(),
+		Signer:       make([]byte, 33),
+	}
+	rand.Read(vote.Signer)
+	assert.True(t, c.TryAppend(vote))
+	assert.NotEqual(t, hash, c.Hash())
+
+	// Changing the votes directly changes the hash.
+	c.Votes = c.Votes[:len(c.Votes)-1]
+	assert.Equal(t, hash, c.Hash())
+	c.Votes[0].Sign = make([]byte, 64)
+	assert.NotEqual(t, hash, c.Hash())
+}
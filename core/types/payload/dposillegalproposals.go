@@ -46,8 +46,8 @@ func (d *ProposalEvidence) Deserialize(r io.Reader) (err error) {
 		return err
 	}
 
-	if d.BlockHeader, err = common.ReadVarBytes(r, uint32(pact.MaxBlockSize),
-		"block header"); err != nil {
+	if d.BlockHeader, err = common.ReadVarBytes(r,
+		uint32(pact.MaxBlockHeaderSize), "block header"); err != nil {
 		return err
 	}
 
@@ -100,6 +100,25 @@ func (d *DPOSIllegalProposals) Hash() common.Uint256 {
 	return *d.hash
 }
 
+// Equals returns if the two illegal proposals payloads have the same hash.
+func (d *DPOSIllegalProposals) Equals(other *DPOSIllegalProposals) bool {
+	if other == nil {
+		return false
+	}
+	return d.Hash().IsEqual(other.Hash())
+}
+
+// Canonicalize puts the evidence with the lower proposal hash as Evidence and
+// the other one as CompareEvidence, so the same evidences produce the same hash
+// whatever the order they are constructed.
+func (d *DPOSIllegalProposals) Canonicalize() {
+	if d.Evidence.Proposal.Hash().String() >
+		d.CompareEvidence.Proposal.Hash().String() {
+		d.Evidence, d.CompareEvidence = d.CompareEvidence, d.Evidence
+		d.hash = nil
+	}
+}
+
 func (d *DPOSIllegalProposals) GetBlockHeight() uint32 {
 	return d.Evidence.BlockHeight
 }
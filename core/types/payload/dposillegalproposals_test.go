@@ -0,0 +1,94 @@
+package payload
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/elanet/pact"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProposalEvidence_Deserialize(t *testing.T) {
+	sponsor := make([]byte, 33)
+	rand.Read(sponsor)
+	sign := make([]byte, 64)
+	rand.Read(sign)
+
+	header := make([]byte, pact.MaxBlockHeaderSize)
+	rand.Read(header)
+	evidence := ProposalEvidence{
+		Proposal: DPOSProposal{
+			Sponsor: sponsor,
+			Sign:    sign,
+		},
+		BlockHeader: header,
+		BlockHeight: 100,
+	}
+
+	// Header within the bound can be deserialized.
+	buf := new(bytes.Buffer)
+	if !assert.NoError(t, evidence.Serialize(buf)) {
+		t.FailNow()
+	}
+	var e ProposalEvidence
+	if !assert.NoError(t, e.Deserialize(buf)) {
+		t.FailNow()
+	}
+	assert.Equal(t, evidence.BlockHeader, e.BlockHeader)
+	assert.Equal(t, evidence.BlockHeight, e.BlockHeight)
+
+	// Oversized header should fail with the size error.
+	evidence.BlockHeader = make([]byte, pact.MaxBlockHeaderSize+1)
+	buf = new(bytes.Buffer)
+	if !assert.NoError(t, evidence.Serialize(buf)) {
+		t.FailNow()
+	}
+	err := e.Deserialize(buf)
+	if !assert.Error(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, err.Error(), "block header is larger than the max"+
+		" allowed size")
+}
+
+func TestDPOSIllegalProposals_Canonicalize(t *testing.T) {
+	sponsor := make([]byte, 33)
+	rand.Read(sponsor)
+
+	evidences := make([]ProposalEvidence, 2)
+	for i := range evidences {
+		var hash common.Uint256
+		rand.Read(hash[:])
+		evidences[i] = ProposalEvidence{
+			Proposal: DPOSProposal{
+				Sponsor:   sponsor,
+				BlockHash: hash,
+			},
+			BlockHeader: make([]byte, 100),
+			BlockHeight: 100,
+		}
+	}
+
+	d1 := &DPOSIllegalProposals{
+		Evidence:        evidences[0],
+		CompareEvidence: evidences[1],
+	}
+	d2 := &DPOSIllegalProposals{
+		Evidence:        evidences[1],
+		CompareEvidence: evidences[0],
+	}
+	if !assert.False(t, d1.Equals(d2)) {
+		t.FailNow()
+	}
+
+	d1.Canonicalize()
+	d2.Canonicalize()
+	assert.True(t, d1.Equals(d2))
+	assert.Equal(t, d1.Hash(), d2.Hash())
+	assert.True(t, d1.Evidence.Proposal.Hash().String() <
+		d1.CompareEvidence.Proposal.Hash().String())
+	assert.False(t, d1.Equals(nil))
+}
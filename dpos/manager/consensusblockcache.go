@@ -9,24 +9,43 @@ type ConsensusBlockCacheListener interface {
 	OnBlockAdded(b *types.Block)
 }
 
+// ConsensusBlockCacheArrivalListener is an optional extension of
+// ConsensusBlockCacheListener, it's also notified with the arrival index of
+// the added block, 0 means the first arrived block.
+type ConsensusBlockCacheArrivalListener interface {
+	ConsensusBlockCacheListener
+	OnBlockAddedAt(b *types.Block, arrivalIndex int)
+}
+
 type ConsensusBlockCache struct {
 	ConsensusBlocks    map[common.Uint256]*types.Block
 	ConsensusBlockList []common.Uint256
 
+	// blocksByPrevHash indexes cached blocks by their previous block hash.
+	blocksByPrevHash map[common.Uint256][]*types.Block
+
 	Listener ConsensusBlockCacheListener
 }
 
 func (c *ConsensusBlockCache) Reset() {
 	c.ConsensusBlocks = make(map[common.Uint256]*types.Block)
 	c.ConsensusBlockList = make([]common.Uint256, 0)
+	c.blocksByPrevHash = make(map[common.Uint256][]*types.Block)
 }
 
 func (c *ConsensusBlockCache) AddValue(key common.Uint256, value *types.Block) {
+	if _, ok := c.ConsensusBlocks[key]; !ok {
+		prev := value.Header.Previous
+		c.blocksByPrevHash[prev] = append(c.blocksByPrevHash[prev], value)
+	}
 	c.ConsensusBlocks[key] = value
 	c.ConsensusBlockList = append(c.ConsensusBlockList, key)
 
 	if c.Listener != nil {
 		c.Listener.OnBlockAdded(value)
+		if l, ok := c.Listener.(ConsensusBlockCacheArrivalListener); ok {
+			l.OnBlockAddedAt(value, len(c.ConsensusBlockList)-1)
+		}
 	}
 }
 
@@ -36,9 +55,59 @@ func (c *ConsensusBlockCache) TryGetValue(key common.Uint256) (*types.Block, boo
 	return value, ok
 }
 
+// GetBlocksByPrevHash returns all cached blocks whose previous block hash is
+// the given hash, in the order they arrived.
+func (c *ConsensusBlockCache) GetBlocksByPrevHash(
+	prev common.Uint256) []*types.Block {
+	blocks := c.blocksByPrevHash[prev]
+	result := make([]*types.Block, len(blocks))
+	copy(result, blocks)
+	return result
+}
+
 func (c *ConsensusBlockCache) GetFirstArrivedBlockHash() (common.Uint256, bool) {
 	if len(c.ConsensusBlockList) == 0 {
 		return common.Uint256{}, false
 	}
 	return c.ConsensusBlockList[0], true
 }
+
+// IsFirstArrived returns if the given block hash is the first arrived block,
+// false if the cache is empty.
+func (c *ConsensusBlockCache) IsFirstArrived(key common.Uint256) bool {
+	first, ok := c.GetFirstArrivedBlockHash()
+	return ok && first.IsEqual(key)
+}
+
+// PruneBelow removes all cached blocks whose height is below the given height,
+// the arrival order of the remaining blocks is kept.
+func (c *ConsensusBlockCache) PruneBelow(height uint32) {
+	list := make([]common.Uint256, 0, len(c.ConsensusBlockList))
+	for _, key := range c.ConsensusBlockList {
+		if block, ok := c.ConsensusBlocks[key]; ok &&
+			block.Header.Height >= height {
+			list = append(list, key)
+		}
+	}
+	c.ConsensusBlockList = list
+
+	for key, block := range c.ConsensusBlocks {
+		if block.Header.Height < height {
+			delete(c.ConsensusBlocks, key)
+		}
+	}
+
+	for prev, blocks := range c.blocksByPrevHash {
+		remains := make([]*types.Block, 0, len(blocks))
+		for _, block := range blocks {
+			if block.Header.Height >= height {
+				remains = append(remains, block)
+			}
+		}
+		if len(remains) == 0 {
+			delete(c.blocksByPrevHash, prev)
+		} else {
+			c.blocksByPrevHash[prev] = remains
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsensusBlockCache_GetBlocksByPrevHash(t *testing.T) {
+	cache := &ConsensusBlockCache{}
+	cache.Reset()
+
+	prev := common.Uint256{1}
+	block1 := &types.Block{
+		Header: types.Header{Previous: prev, Height: 10, Nonce: 1},
+	}
+	block2 := &types.Block{
+		Header: types.Header{Previous: prev, Height: 10, Nonce: 2},
+	}
+	block3 := &types.Block{
+		Header: types.Header{Previous: common.Uint256{2}, Height: 11},
+	}
+	cache.AddValue(block1.Hash(), block1)
+	cache.AddValue(block2.Hash(), block2)
+	cache.AddValue(block3.Hash(), block3)
+
+	// Adding the same block again should not be indexed twice.
+	cache.AddValue(block1.Hash(), block1)
+
+	assert.Equal(t, []*types.Block{block1, block2},
+		cache.GetBlocksByPrevHash(prev))
+	assert.Equal(t, []*types.Block{block3},
+		cache.GetBlocksByPrevHash(common.Uint256{2}))
+	assert.Equal(t, 0, len(cache.GetBlocksByPrevHash(common.Uint256{3})))
+
+	cache.Reset()
+	assert.Equal(t, 0, len(cache.GetBlocksByPrevHash(prev)))
+}
+
+func TestConsensusBlockCache_IsFirstArrived(t *testing.T) {
+	cache := &ConsensusBlockCache{}
+	cache.Reset()
+
+	block1 := &types.Block{Header: types.Header{Height: 10, Nonce: 1}}
+	block2 := &types.Block{Header: types.Header{Height: 10, Nonce: 2}}
+	assert.False(t, cache.IsFirstArrived(block1.Hash()))
+
+	cache.AddValue(block1.Hash(), block1)
+	cache.AddValue(block2.Hash(), block2)
+	assert.True(t, cache.IsFirstArrived(block1.Hash()))
+	assert.False(t, cache.IsFirstArrived(block2.Hash()))
+	assert.False(t, cache.IsFirstArrived(common.Uint256{}))
+}
+
+type arrivalListener struct {
+	added   []*types.Block
+	indexes []int
+}
+
+func (l *arrivalListener) OnBlockAdded(b *types.Block) {
+	l.added = append(l.added, b)
+}
+
+func (l *arrivalListener) OnBlockAddedAt(b *types.Block, arrivalIndex int) {
+	l.indexes = append(l.indexes, arrivalIndex)
+}
+
+func TestConsensusBlockCache_ArrivalListener(t *testing.T) {
+	listener := &arrivalListener{}
+	cache := &ConsensusBlockCache{Listener: listener}
+	cache.Reset()
+
+	blocks := make([]*types.Block, 3)
+	for i := range blocks {
+		blocks[i] = &types.Block{
+			Header: types.Header{Height: 10, Nonce: uint32(i)},
+		}
+		cache.AddValue(blocks[i].Hash(), blocks[i])
+	}
+	assert.Equal(t, blocks, listener.added)
+	assert.Equal(t, []int{0, 1, 2}, listener.indexes)
+
+	// Arrival index restarts after reset.
+	cache.Reset()
+	cache.AddValue(blocks[2].Hash(), blocks[2])
+	assert.Equal(t, []int{0, 1, 2, 0}, listener.indexes)
+}
+
+func TestConsensusBlockCache_PruneBelow(t *testing.T) {
+	cache := &ConsensusBlockCache{}
+	cache.Reset()
+
+	blocks := make([]*types.Block, 0)
+	for _, height := range []uint32{12, 10, 11, 12, 10, 13} {
+		block := &types.Block{
+			Header: types.Header{
+				Previous: common.Uint256{byte(height)},
+				Height:   height,
+				Nonce:    uint32(len(blocks)),
+			},
+		}
+		blocks = append(blocks, block)
+		cache.AddValue(block.Hash(), block)
+	}
+
+	hash, ok := cache.GetFirstArrivedBlockHash()
+	assert.True(t, ok)
+	assert.Equal(t, blocks[0].Hash(), hash)
+
+	cache.PruneBelow(12)
+	assert.Equal(t, []common.Uint256{blocks[0].Hash(), blocks[3].Hash(),
+		blocks[5].Hash()}, cache.ConsensusBlockList)
+	assert.Equal(t, 3, len(cache.ConsensusBlocks))
+	for _, block := range blocks[1:3] {
+		_, ok := cache.TryGetValue(block.Hash())
+		assert.False(t, ok)
+	}
+	assert.Equal(t, 0, len(cache.GetBlocksByPrevHash(common.Uint256{10})))
+	assert.Equal(t, []*types.Block{blocks[0], blocks[3]},
+		cache.GetBlocksByPrevHash(common.Uint256{12}))
+
+	// The oldest surviving block becomes the first arrived one.
+	cache.PruneBelow(13)
+	hash, ok = cache.GetFirstArrivedBlockHash()
+	assert.True(t, ok)
+	assert.Equal(t, blocks[5].Hash(), hash)
+
+	cache.PruneBelow(14)
+	_, ok = cache.GetFirstArrivedBlockHash()
+	assert.False(t, ok)
+	assert.Equal(t, 0, len(cache.ConsensusBlocks))
+}
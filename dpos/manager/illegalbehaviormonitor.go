@@ -113,19 +113,11 @@ func (i *IllegalBehaviorMonitor) ProcessIllegalProposal(
 		log.Warn("[ProcessIllegalProposal] generate evidence error: ", err)
 	}
 
-	asc := true
-	if first.Hash().String() > second.Hash().String() {
-		asc = false
-	}
-
-	evidences := &payload.DPOSIllegalProposals{}
-	if asc {
-		evidences.Evidence = *firstEvidence
-		evidences.CompareEvidence = *secondEvidence
-	} else {
-		evidences.Evidence = *secondEvidence
-		evidences.CompareEvidence = *firstEvidence
+	evidences := &payload.DPOSIllegalProposals{
+		Evidence:        *firstEvidence,
+		CompareEvidence: *secondEvidence,
 	}
+	evidences.Canonicalize()
 
 	i.AddEvidence(evidences)
 	i.sendIllegalProposalTransaction(evidences)
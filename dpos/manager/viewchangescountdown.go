@@ -5,13 +5,13 @@ import (
 )
 
 const (
-	// firstTimeoutFactor specified the factor first dynamic change
+	// firstTimeoutFactor specified the default factor first dynamic change
 	// arbitrators in one consensus
 	// (timeout will occurred in about 180 seconds)
 	firstTimeoutFactor = uint32(1)
 
-	// othersTimeoutFactor specified the factor after first dynamic change
-	// arbitrators in one consensus
+	// othersTimeoutFactor specified the default factor after first dynamic
+	// change arbitrators in one consensus
 	// (timeout will occurred in about 12 hours)
 	othersTimeoutFactor = uint32(240)
 )
@@ -33,10 +33,19 @@ func (c *ViewChangesCountDown) Reset() {
 func (c *ViewChangesCountDown) SetEliminated() {
 	c.inactiveArbitratorsEliminated = true
 
+	params := c.dispatcher.cfg.ChainParams
 	if c.timeoutRefactor == 0 {
-		c.timeoutRefactor += firstTimeoutFactor
+		factor := params.FirstViewTimeoutFactor
+		if factor == 0 {
+			factor = firstTimeoutFactor
+		}
+		c.timeoutRefactor += factor
 	} else {
-		c.timeoutRefactor += othersTimeoutFactor
+		factor := params.SubsequentViewTimeoutFactor
+		if factor == 0 {
+			factor = othersTimeoutFactor
+		}
+		c.timeoutRefactor += factor
 	}
 }
 
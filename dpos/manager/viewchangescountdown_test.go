@@ -0,0 +1,59 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/common/config"
+	"github.com/elastos/Elastos.ELA/core/types"
+	"github.com/elastos/Elastos.ELA/dpos/state"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestViewChangesCountDown_TimeoutFactors(t *testing.T) {
+	params := config.DefaultParams
+	params.PublicDPOSHeight = 100
+	consensus := &Consensus{}
+	countDown := &ViewChangesCountDown{
+		dispatcher: &ProposalDispatcher{
+			cfg: ProposalDispatcherConfig{ChainParams: &params},
+			processingBlock: &types.Block{
+				Header: types.Header{Height: 101},
+			},
+		},
+		consensus: consensus,
+		arbitrators: &state.ArbitratorsMock{
+			CurrentArbitrators: make([][]byte, 5),
+		},
+	}
+
+	// timeoutAt returns the first view offset that is timed out.
+	timeoutAt := func() uint32 {
+		for offset := uint32(0); offset < 10000; offset++ {
+			consensus.viewOffset = offset
+			if countDown.IsTimeOut() {
+				return offset
+			}
+		}
+		return 0
+	}
+
+	// Default factors.
+	countDown.Reset()
+	assert.False(t, countDown.IsTimeOut())
+	countDown.SetEliminated()
+	assert.Equal(t, uint32(5), timeoutAt())
+	countDown.SetEliminated()
+	assert.Equal(t, uint32(5*241), timeoutAt())
+
+	// Overridden factors.
+	params.FirstViewTimeoutFactor = 3
+	params.SubsequentViewTimeoutFactor = 10
+	countDown.Reset()
+	countDown.SetEliminated()
+	assert.Equal(t, uint32(15), timeoutAt())
+	countDown.SetEliminated()
+	assert.Equal(t, uint32(65), timeoutAt())
+	countDown.SetEliminated()
+	assert.Equal(t, uint32(115), timeoutAt())
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/elastos/Elastos.ELA/dpos/p2p"
 	"github.com/elastos/Elastos.ELA/dpos/p2p/msg"
 	"github.com/elastos/Elastos.ELA/dpos/p2p/peer"
+	"github.com/elastos/Elastos.ELA/dpos/state"
 	"github.com/elastos/Elastos.ELA/dpos/store"
 	elap2p "github.com/elastos/Elastos.ELA/p2p"
 	elamsg "github.com/elastos/Elastos.ELA/p2p/msg"
@@ -131,15 +132,17 @@ func (n *network) UpdatePeers(peers map[string]*p2p.PeerAddr) error {
 		return errors.New("self not in direct peers list")
 	}
 
-	var peerList []p2p.PeerAddr
 	for k, v := range peers {
 		if v == nil {
 			log.Info("peer[", k, "] address empty")
-			continue
 		}
-		log.Info("peer[", k, "] addr:", v.Addr, " pid:",
-			common.BytesToHexString(v.PID[:]))
-		peerList = append(peerList, *v)
+	}
+
+	// Connect peers in a deterministic order.
+	peerList := state.SortPeerAddrs(peers)
+	for _, v := range peerList {
+		log.Info("peer[", common.BytesToHexString(v.PID[:]), "] addr:",
+			v.Addr)
 	}
 	n.p2pServer.ConnectPeers(peerList)
 
@@ -2,6 +2,8 @@ package state
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -20,6 +22,7 @@ import (
 	"github.com/elastos/Elastos.ELA/events"
 )
 
+// ChangeType represents the type of arbiters change on a height.
 type ChangeType byte
 
 const (
@@ -31,68 +34,638 @@ const (
 	// majority signatures.
 	majoritySignRatioDenominator = float64(3)
 
-	none         = ChangeType(0x00)
-	updateNext   = ChangeType(0x01)
-	normalChange = ChangeType(0x02)
+	// maxRewardHistory defines the maximum heights of arbiters rewards kept in
+	// memory.
+	maxRewardHistory = 720
+
+	// maxBlockCountHistory defines the maximum heights of arbiters produced
+	// block count changes kept in memory for rollback.
+	maxBlockCountHistory = 720
+
+	// maxPromotionRecords defines the maximum promotion records of arbiters
+	// kept in memory.
+	maxPromotionRecords = 720
+
+	// maxArbitersSnapshots defines the maximum arbiters snapshots kept in
+	// memory for rollback.
+	maxArbitersSnapshots = 72
+
+	// None indicates no arbiters change on the height, only the duty index
+	// moves forward.
+	None = ChangeType(0x00)
+
+	// UpdateNext indicates the next arbiters will be updated on the height so
+	// they can prepare to connect.
+	UpdateNext = ChangeType(0x01)
+
+	// NormalChange indicates the current arbiters will be changed to the next
+	// arbiters on the height.
+	NormalChange = ChangeType(0x02)
+)
+
+const (
+	// ChangeReasonInactivePayload indicates arbiters changed by an inactive
+	// arbitrators payload.
+	ChangeReasonInactivePayload = "inactive-payload"
+
+	// ChangeReasonIllegalPayload indicates arbiters changed by an illegal
+	// blocks payload.
+	ChangeReasonIllegalPayload = "illegal-payload"
+
+	// ChangeReasonForceChange indicates arbiters changed by calling
+	// ForceChange directly.
+	ChangeReasonForceChange = "forcechange"
+
+	// ChangeReasonScheduledNormal indicates arbiters changed on a scheduled
+	// change point.
+	ChangeReasonScheduledNormal = "scheduled-normal"
+
+	// ChangeReasonScheduledUpdateNext indicates next arbiters updated on a
+	// scheduled pre-connect point.
+	ChangeReasonScheduledUpdateNext = "scheduled-update-next"
+)
+
+// PromotionType represents the direction of a producer moving in or out of
+// the arbiters.
+type PromotionType byte
+
+const (
+	// Promoted indicates the producer moved from candidate to arbiter.
+	Promoted PromotionType = iota
+
+	// Dropped indicates the producer dropped out of the arbiters.
+	Dropped
 )
 
+// PromotionRecord records a producer moving in or out of the arbiters on an
+// arbiters change.
+type PromotionRecord struct {
+	Height        uint32
+	NodePublicKey []byte
+	Type          PromotionType
+}
+
+// CRCArbiterInfo pairs the keys of a CRC arbiter with its program hash.
+type CRCArbiterInfo struct {
+	NodePublicKey  []byte
+	OwnerPublicKey []byte
+	ProgramHash    common.Uint168
+}
+
+// NetworkMode represents the staffing mode of the DPOS network.
+type NetworkMode byte
+
+const (
+	// NetworkNormal indicates next arbiters are fully staffed.
+	NetworkNormal NetworkMode = iota
+
+	// NetworkUnderstaffed indicates there are not enough producers to fill
+	// next arbiters, so the network falls back to fewer or CRC only arbiters.
+	NetworkUnderstaffed
+)
+
+func (m NetworkMode) String() string {
+	switch m {
+	case NetworkNormal:
+		return "Normal"
+	case NetworkUnderstaffed:
+		return "Understaffed"
+	default:
+		return "Unknown"
+	}
+}
+
+// NetworkModeChange is the data of ETNetworkDegraded and ETNetworkRecovered
+// events.
+type NetworkModeChange struct {
+	Mode   NetworkMode
+	Height uint32
+}
+
+// networkModeRecord records the network mode changed to on a height.
+type networkModeRecord struct {
+	height uint32
+	mode   NetworkMode
+}
+
+// ArbitersChange records the metadata of an arbiters change.
+type ArbitersChange struct {
+	Height     uint32
+	Reason     string
+	ChangeType ChangeType
+}
+
+// arbitersSnapshot holds the arbiter sets before they are changed on a height
+// for rollback.
+type arbitersSnapshot struct {
+	height                      uint32
+	dutyIndex                   int
+	currentArbitrators          [][]byte
+	currentCandidates           [][]byte
+	nextArbitrators             [][]byte
+	nextCandidates              [][]byte
+	currentOwnerProgramHashes   []*common.Uint168
+	candidateOwnerProgramHashes []*common.Uint168
+	ownerVotesInRound           map[common.Uint168]common.Fixed64
+	totalVotesInRound           common.Fixed64
+	lastChange                  ArbitersChange
+}
+
+// crcArbitersSwap records the CRC arbiters replaced on a height for rollback.
+type crcArbitersSwap struct {
+	height         uint32
+	scheduleHeight uint32
+	nodePublicKey  map[string]*Producer
+	programHashes  map[common.Uint168]interface{}
+}
+
+// blockCountChange records the produced block count changes on a height for
+// rollback, and the block hash, expected on-duty arbiter, duty index and
+// arbiters of the height for uptime and auditing.
+type blockCountChange struct {
+	height    uint32
+	hash      common.Uint256
+	sponsor   string
+	expected  string
+	dutyIndex int
+	arbiters  [][]byte
+	removed   map[string]uint32
+}
+
+// roundOwner holds the cached owner information of an arbiter or candidate
+// in the current round.
+type roundOwner struct {
+	producer    *Producer
+	programHash *common.Uint168
+	votes       common.Fixed64
+}
+
+// heightRewards holds the DPOS rewards paid to each owner program hash on a
+// particular height.
+type heightRewards struct {
+	height  uint32
+	rewards map[common.Uint168]common.Fixed64
+}
+
 type arbitrators struct {
 	*State
 	chainParams   *config.Params
 	bestHeight    func() uint32
 	arbitersCount int
 
+	// producerLess orders producers to select arbiters and candidates.
+	producerLess ProducerComparator
+
 	mtx                sync.Mutex
 	dutyIndex          int
 	currentArbitrators [][]byte
 	currentCandidates  [][]byte
 
+	// originArbiters is the arbiters producing blocks before H1, it can be
+	// reloaded by ReloadOriginArbiters.
+	originArbiters [][]byte
+
 	currentOwnerProgramHashes   []*common.Uint168
 	candidateOwnerProgramHashes []*common.Uint168
 	ownerVotesInRound           map[common.Uint168]common.Fixed64
 	totalVotesInRound           common.Fixed64
 
+	// roundOwners caches owner information of current arbiters and candidates
+	// by node public key, nil means the cache is invalid.
+	roundOwners map[string]*roundOwner
+
 	nextArbitrators             [][]byte
 	nextCandidates              [][]byte
 	crcArbitratorsProgramHashes map[common.Uint168]interface{}
 	crcArbitratorsNodePublicKey map[string]*Producer
+
+	rewardHistory []heightRewards
+	lastChange    ArbitersChange
+	onBlockReward func(height uint32, reward common.Fixed64)
+
+	networkMode        NetworkMode
+	pendingModeChanges []*NetworkModeChange
+
+	// modeRecords records the network mode changes by the heights they are
+	// made on for rollback.
+	modeRecords []networkModeRecord
+
+	// crcScheduleHeight is the height of the CRCArbiterSchedule entry in use,
+	// 0 means CRCArbiters is in use.
+	crcScheduleHeight uint32
+	crcSwaps          []crcArbitersSwap
+
+	// snapshots records the arbiter sets before each arbiters change.
+	snapshots []arbitersSnapshot
+
+	// blockCounts records the blocks produced by each arbiter since it became
+	// an arbiter by node public key.
+	blockCounts       map[string]uint32
+	blockCountHistory []blockCountChange
+
+	// promotions records the recent producers moving in or out of the
+	// arbiters, the oldest first.
+	promotions []PromotionRecord
+
+	// previousRanks and currentRanks are the vote sorted ranks of producers
+	// starting from 1 by node public key, at the previous and the latest
+	// next arbiters update.
+	previousRanks map[string]int
+	currentRanks  map[string]int
 }
 
-func (a *arbitrators) ProcessBlock(block *types.Block, confirm *payload.Confirm) {
-	a.State.ProcessBlock(block, confirm)
+func (a *arbitrators) ProcessBlock(block *types.Block,
+	confirm *payload.Confirm) error {
+	// Skip the block processed already, so arbiters are not changed twice.
+	a.State.mtx.RLock()
+	err := a.checkProcessHeight(block.Height)
+	a.State.mtx.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	err = a.State.ProcessBlock(block, confirm)
+	a.recordRewards(block)
+	a.notifyBlockReward(block)
+
+	a.mtx.Lock()
+	a.countProducedBlock(block.Height, block.Hash(), confirm)
+	a.mtx.Unlock()
+
 	a.IncreaseChainHeight(block.Height)
+
+	a.mtx.Lock()
+	a.resetBlockCounts()
+	a.mtx.Unlock()
+	return err
+}
+
+// countProducedBlock increases the produced block count of the sponsor of the
+// given confirm, if the sponsor is a current arbiter.
+func (a *arbitrators) countProducedBlock(height uint32, hash common.Uint256,
+	confirm *payload.Confirm) {
+	change := blockCountChange{height: height, hash: hash,
+		arbiters: a.currentArbitrators}
+	if len(a.currentArbitrators) > 0 {
+		change.dutyIndex = a.getDutyIndexByHeight(height)
+	}
+	if height >= a.chainParams.CRCOnlyDPOSHeight {
+		if onDuty := a.GetNextOnDutyArbitratorV(height, 0); onDuty != nil {
+			change.expected = hex.EncodeToString(onDuty)
+		}
+	}
+	if confirm != nil {
+		for _, arbiter := range a.currentArbitrators {
+			if bytes.Equal(arbiter, confirm.Proposal.Sponsor) {
+				change.sponsor = hex.EncodeToString(arbiter)
+				a.blockCounts[change.sponsor]++
+				break
+			}
+		}
+	}
+
+	if len(a.blockCountHistory) >= maxBlockCountHistory {
+		a.blockCountHistory = a.blockCountHistory[1:]
+	}
+	a.blockCountHistory = append(a.blockCountHistory, change)
+}
+
+// resetBlockCounts removes the produced block counts of those who are no
+// longer arbiters, the removed counts are recorded in the last change.
+func (a *arbitrators) resetBlockCounts() {
+	if len(a.blockCounts) == 0 || len(a.blockCountHistory) == 0 {
+		return
+	}
+
+	arbiters := make(map[string]struct{}, len(a.currentArbitrators))
+	for _, arbiter := range a.currentArbitrators {
+		arbiters[hex.EncodeToString(arbiter)] = struct{}{}
+	}
+	change := &a.blockCountHistory[len(a.blockCountHistory)-1]
+	for key, count := range a.blockCounts {
+		if _, ok := arbiters[key]; ok {
+			continue
+		}
+		if change.removed == nil {
+			change.removed = make(map[string]uint32)
+		}
+		change.removed[key] = count
+		delete(a.blockCounts, key)
+	}
+}
+
+// GetArbiterBlockCount returns the blocks produced by the arbiter since it
+// became an arbiter.
+func (a *arbitrators) GetArbiterBlockCount(nodePublicKey []byte) uint32 {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	return a.blockCounts[hex.EncodeToString(nodePublicKey)]
+}
+
+// GetProducerUptime returns the fraction of the on-duty turns of the arbiter
+// in the recent window blocks that it actually sponsored the block, the
+// window is bounded by the kept block count history. Zero is returned if the
+// arbiter has no on-duty turn in the window.
+func (a *arbitrators) GetProducerUptime(nodePublicKey []byte,
+	windowBlocks uint32) float64 {
+	key := hex.EncodeToString(nodePublicKey)
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	start := 0
+	if int(windowBlocks) < len(a.blockCountHistory) {
+		start = len(a.blockCountHistory) - int(windowBlocks)
+	}
+	var expected, produced int
+	for _, change := range a.blockCountHistory[start:] {
+		if change.expected != key {
+			continue
+		}
+		expected++
+		if change.sponsor == key {
+			produced++
+		}
+	}
+	if expected == 0 {
+		return 0
+	}
+	return float64(produced) / float64(expected)
+}
+
+// RegisterOnBlockReward registers a callback to observe the DPOS reward of each
+// block before distribution, the callback will be invoked on heights above
+// PublicDPOSHeight.
+func (a *arbitrators) RegisterOnBlockReward(
+	onBlockReward func(height uint32, reward common.Fixed64)) {
+	a.mtx.Lock()
+	a.onBlockReward = onBlockReward
+	a.mtx.Unlock()
+}
+
+// notifyBlockReward invokes the registered block reward callback with the
+// DPOS reward of the given block.
+func (a *arbitrators) notifyBlockReward(block *types.Block) {
+	a.mtx.Lock()
+	onBlockReward := a.onBlockReward
+	a.mtx.Unlock()
+
+	if onBlockReward == nil || block.Height <= a.chainParams.PublicDPOSHeight {
+		return
+	}
+	onBlockReward(block.Height, getBlockDPOSReward(block))
+}
+
+// getBlockDPOSReward returns the DPOS reward of the given block before
+// distribution, which is 35% of the total reward in coinbase transaction.
+func getBlockDPOSReward(block *types.Block) common.Fixed64 {
+	if len(block.Transactions) == 0 || !block.Transactions[0].IsCoinBaseTx() {
+		return 0
+	}
+
+	var totalReward common.Fixed64
+	for _, output := range block.Transactions[0].Outputs {
+		totalReward += output.Value
+	}
+	return common.Fixed64(float64(totalReward) * 0.35)
+}
+
+// GetNextBlockDPOSReward returns the DPOS reward of the next block with only
+// the base reward per block, transaction fees are not included.
+func (a *arbitrators) GetNextBlockDPOSReward() common.Fixed64 {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	block := &types.Block{
+		Transactions: []*types.Transaction{{
+			TxType:  types.CoinBase,
+			Outputs: []*types.Output{{Value: a.chainParams.RewardPerBlock}},
+		}},
+	}
+	return getBlockDPOSReward(block)
+}
+
+// recordRewards records the DPOS rewards paid by the coinbase transaction of
+// the given block.
+func (a *arbitrators) recordRewards(block *types.Block) {
+	if block.Height < a.chainParams.PublicDPOSHeight ||
+		len(block.Transactions) == 0 ||
+		!block.Transactions[0].IsCoinBaseTx() {
+		return
+	}
+
+	// The first two outputs are rewards to CR and merge miner, others are
+	// rewards to arbiters and candidates.
+	rewards := make(map[common.Uint168]common.Fixed64)
+	outputs := block.Transactions[0].Outputs
+	for i := 2; i < len(outputs); i++ {
+		rewards[outputs[i].ProgramHash] += outputs[i].Value
+	}
+
+	a.mtx.Lock()
+	if len(a.rewardHistory) >= maxRewardHistory {
+		a.rewardHistory = a.rewardHistory[1:]
+	}
+	a.rewardHistory = append(a.rewardHistory,
+		heightRewards{height: block.Height, rewards: rewards})
+	a.mtx.Unlock()
+}
+
+// GetAccumulatedReward returns the sum of DPOS rewards paid to the given owner
+// program hash in range of [fromHeight, toHeight].
+func (a *arbitrators) GetAccumulatedReward(ownerHash common.Uint168,
+	fromHeight, toHeight uint32) (common.Fixed64, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if fromHeight > toHeight {
+		return 0, fmt.Errorf("invalid height range [%d, %d]",
+			fromHeight, toHeight)
+	}
+	if len(a.rewardHistory) == 0 {
+		return 0, errors.New("no rewards history")
+	}
+	first := a.rewardHistory[0].height
+	last := a.rewardHistory[len(a.rewardHistory)-1].height
+	if fromHeight < first || toHeight > last {
+		return 0, fmt.Errorf("height range [%d, %d] overflow rewards"+
+			" history [%d, %d]", fromHeight, toHeight, first, last)
+	}
+
+	var reward common.Fixed64
+	for _, r := range a.rewardHistory {
+		if r.height >= fromHeight && r.height <= toHeight {
+			reward += r.rewards[ownerHash]
+		}
+	}
+	return reward, nil
 }
 
 func (a *arbitrators) ProcessSpecialTxPayload(p types.Payload,
 	height uint32) error {
 	switch p.(type) {
-	case *payload.DPOSIllegalBlocks, *payload.InactiveArbitrators:
+	case *payload.DPOSIllegalBlocks:
+		a.State.ProcessSpecialTxPayload(p)
+		return a.forceChange(height, ChangeReasonIllegalPayload)
+	case *payload.InactiveArbitrators:
 		a.State.ProcessSpecialTxPayload(p)
-		return a.ForceChange(height)
+		return a.forceChange(height, ChangeReasonInactivePayload)
 	default:
 		return errors.New("[ProcessSpecialTxPayload] invalid payload type")
 	}
 }
 
 func (a *arbitrators) RollbackTo(height uint32) error {
-	if err := a.State.RollbackTo(height); err != nil {
+	a.State.processMtx.Lock()
+	notify, err := a.rollbackTo(height)
+	a.State.processMtx.Unlock()
+
+	notify()
+	return err
+}
+
+// RevertLastBlock restores arbitrators and state to the height before the last
+// processed block, if there is no history of the last block return error.
+func (a *arbitrators) RevertLastBlock() error {
+	a.State.processMtx.Lock()
+	a.State.mtx.RLock()
+	height, err := a.lastBlockHeight()
+	a.State.mtx.RUnlock()
+	if err != nil {
+		a.State.processMtx.Unlock()
 		return err
 	}
+	notify, err := a.rollbackTo(height - 1)
+	a.State.processMtx.Unlock()
+
+	notify()
+	return err
+}
+
+// rollbackTo restores arbitrators and state to the given height with the
+// processMtx of state held by the caller, the returned function notifies the
+// changes and should be called after processMtx released.
+func (a *arbitrators) rollbackTo(height uint32) (func(), error) {
+	notifyVotes, err := a.State.rollbackTo(height)
+	if err != nil {
+		return func() { notifyVotes(height) }, err
+	}
 	a.DecreaseChainHeight(height)
+
+	a.mtx.Lock()
+	for len(a.rewardHistory) > 0 &&
+		a.rewardHistory[len(a.rewardHistory)-1].height > height {
+		a.rewardHistory = a.rewardHistory[:len(a.rewardHistory)-1]
+	}
+	for len(a.blockCountHistory) > 0 &&
+		a.blockCountHistory[len(a.blockCountHistory)-1].height > height {
+		change := a.blockCountHistory[len(a.blockCountHistory)-1]
+		a.blockCountHistory = a.blockCountHistory[:len(a.blockCountHistory)-1]
+		for key, count := range change.removed {
+			a.blockCounts[key] = count
+		}
+		if change.sponsor == "" {
+			continue
+		}
+		a.blockCounts[change.sponsor]--
+		if a.blockCounts[change.sponsor] == 0 {
+			delete(a.blockCounts, change.sponsor)
+		}
+	}
+	for len(a.promotions) > 0 &&
+		a.promotions[len(a.promotions)-1].Height > height {
+		a.promotions = a.promotions[:len(a.promotions)-1]
+	}
+	for len(a.crcSwaps) > 0 && a.crcSwaps[len(a.crcSwaps)-1].height > height {
+		swap := a.crcSwaps[len(a.crcSwaps)-1]
+		a.crcSwaps = a.crcSwaps[:len(a.crcSwaps)-1]
+		a.setCRCArbiters(swap.scheduleHeight, swap.nodePublicKey,
+			swap.programHashes)
+	}
+	a.rollbackArbiters(height)
+	a.rollbackNetworkMode(height)
+	a.mtx.Unlock()
+
+	return func() {
+		notifyVotes(height)
+		a.notifyNetworkModeChanges()
+	}, nil
+}
+
+// ValidateIllegalBlockEvidence checks each signer of the illegal blocks
+// evidence was an arbiter when the block on the evidence height was produced,
+// the height should be in range of the processed blocks history.  A signer is
+// matched by node public key, or by the owner public key of the producer.
+func (a *arbitrators) ValidateIllegalBlockEvidence(
+	p *payload.DPOSIllegalBlocks) error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	a.State.mtx.RLock()
+	defer a.State.mtx.RUnlock()
+
+	height := p.GetBlockHeight()
+	var arbiters [][]byte
+	found := false
+	for _, change := range a.blockCountHistory {
+		if change.height == height {
+			arbiters, found = change.arbiters, true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("height %d not in arbiters history", height)
+	}
+
+	arbiterSet := make(map[string]struct{}, len(arbiters))
+	for _, arbiter := range arbiters {
+		arbiterSet[hex.EncodeToString(arbiter)] = struct{}{}
+	}
+	for _, signers := range [][][]byte{p.Evidence.Signers,
+		p.CompareEvidence.Signers} {
+		for _, signer := range signers {
+			nodePublicKey := signer
+			if producer := a.getProducer(signer); producer != nil {
+				nodePublicKey = producer.info.NodePublicKey
+			}
+			if _, ok := arbiterSet[hex.EncodeToString(nodePublicKey)]; !ok {
+				return fmt.Errorf("signer %s is not an arbiter on height %d",
+					hex.EncodeToString(signer), height)
+			}
+		}
+	}
 	return nil
 }
 
 func (a *arbitrators) GetDutyIndexByHeight(height uint32) (index int) {
 	a.mtx.Lock()
-	if height >= a.chainParams.CRCOnlyDPOSHeight-1 {
-		index = a.dutyIndex % len(a.currentArbitrators)
-	} else {
-		index = int(height) % len(a.currentArbitrators)
-	}
+	index = a.getDutyIndexByHeight(height)
 	a.mtx.Unlock()
 	return index
 }
 
+func (a *arbitrators) getDutyIndexByHeight(height uint32) int {
+	if height >= a.chainParams.CRCOnlyDPOSHeight-1 {
+		return a.dutyIndex % len(a.currentArbitrators)
+	}
+	return int(height) % len(a.currentArbitrators)
+}
+
+// GetHistoricalDutyIndex returns the duty index in effect when the block on
+// the given height was produced, the height should be in range of the
+// processed blocks history.
+func (a *arbitrators) GetHistoricalDutyIndex(height uint32) (int, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	for _, change := range a.blockCountHistory {
+		if change.height == height {
+			return change.dutyIndex, nil
+		}
+	}
+	return 0, fmt.Errorf("height %d not in duty index history", height)
+}
+
 func (a *arbitrators) GetDutyIndex() int {
 	a.mtx.Lock()
 	index := a.dutyIndex
@@ -101,37 +674,212 @@ func (a *arbitrators) GetDutyIndex() int {
 	return index
 }
 
+// ReloadOriginArbiters replaces the origin arbiters by the given arbiters in
+// hex string format, it's only allowed before any block processed.
+func (a *arbitrators) ReloadOriginArbiters(arbiters []string) error {
+	a.State.mtx.RLock()
+	started := a.bestHeight() > 0 || a.history.height > 0
+	a.State.mtx.RUnlock()
+	if started {
+		return errors.New("reload origin arbiters after blocks processed")
+	}
+
+	originArbiters, programHashes, err := newOriginArbiters(arbiters)
+	if err != nil {
+		return err
+	}
+
+	a.mtx.Lock()
+	a.originArbiters = originArbiters
+	a.currentArbitrators = originArbiters
+	a.currentOwnerProgramHashes = programHashes
+	a.nextArbitrators = originArbiters
+	a.mtx.Unlock()
+	return nil
+}
+
+// GetLastChange returns the metadata of the last arbiters change.
+func (a *arbitrators) GetLastChange() ArbitersChange {
+	a.mtx.Lock()
+	lastChange := a.lastChange
+	a.mtx.Unlock()
+
+	return lastChange
+}
+
 func (a *arbitrators) ForceChange(height uint32) error {
+	return a.forceChange(height, ChangeReasonForceChange)
+}
+
+func (a *arbitrators) forceChange(height uint32, reason string) error {
 	a.mtx.Lock()
+	a.snapshotArbiters(height)
+	// Fall back to full recompute of owner votes on force change.
+	a.roundOwners = nil
+	if _, err := a.updateCRCArbiters(height); err != nil {
+		a.mtx.Unlock()
+		return err
+	}
 	if err := a.updateNextArbitrators(height + 1); err != nil {
+		a.mtx.Unlock()
 		return err
 	}
 
-	if err := a.changeCurrentArbitrators(); err != nil {
+	previous := a.currentArbitrators
+	if err := a.changeCurrentArbitrators(height); err != nil {
+		a.mtx.Unlock()
 		return err
 	}
+	a.recordPromotions(height, previous)
 
+	a.lastChange = ArbitersChange{
+		Height:     height,
+		Reason:     reason,
+		ChangeType: NormalChange,
+	}
+	a.updateNetworkMode(height, height+1)
 	a.mtx.Unlock()
 
 	events.Notify(events.ETDirectPeersChanged, a.GetNeedConnectArbiters(height))
+	a.notifyNetworkModeChanges()
 
 	return nil
 }
 
 func (a *arbitrators) NormalChange(height uint32) error {
-	if err := a.changeCurrentArbitrators(); err != nil {
+	a.snapshotArbiters(height)
+	swapped, err := a.updateCRCArbiters(height)
+	if err != nil {
+		log.Warn("[NormalChange] update CRC arbiters error: ", err)
+		return err
+	}
+	if swapped {
+		// Recompute next arbiters so the new CRC arbiters take effect from
+		// this change.
+		if err := a.updateNextArbitrators(height + 1); err != nil {
+			log.Warn("[NormalChange] update next arbiters error: ", err)
+			return err
+		}
+	}
+
+	previous := a.currentArbitrators
+	if err := a.changeCurrentArbitrators(height); err != nil {
 		log.Warn("[NormalChange] change current arbiters error: ", err)
 		return err
 	}
+	a.recordPromotions(height, previous)
 
 	if err := a.updateNextArbitrators(height + 1); err != nil {
 		log.Warn("[NormalChange] update next arbiters error: ", err)
 		return err
 	}
 
+	a.lastChange = ArbitersChange{
+		Height:     height,
+		Reason:     ChangeReasonScheduledNormal,
+		ChangeType: NormalChange,
+	}
+	a.updateNetworkMode(height, height+1)
 	return nil
 }
 
+// snapshotArbiters records the arbiter sets before they are changed on the
+// given height, so they can be restored on rollback.
+func (a *arbitrators) snapshotArbiters(height uint32) {
+	if len(a.snapshots) >= maxArbitersSnapshots {
+		a.snapshots = a.snapshots[1:]
+	}
+	a.snapshots = append(a.snapshots, arbitersSnapshot{
+		height:                      height,
+		dutyIndex:                   a.dutyIndex,
+		currentArbitrators:          copyByteList(a.currentArbitrators),
+		currentCandidates:           copyByteList(a.currentCandidates),
+		nextArbitrators:             copyByteList(a.nextArbitrators),
+		nextCandidates:              copyByteList(a.nextCandidates),
+		currentOwnerProgramHashes:   a.currentOwnerProgramHashes,
+		candidateOwnerProgramHashes: a.candidateOwnerProgramHashes,
+		ownerVotesInRound:           a.ownerVotesInRound,
+		totalVotesInRound:           a.totalVotesInRound,
+		lastChange:                  a.lastChange,
+	})
+}
+
+// rollbackArbiters restores the arbiter sets changed after the given height.
+func (a *arbitrators) rollbackArbiters(height uint32) {
+	restored := false
+	for len(a.snapshots) > 0 &&
+		a.snapshots[len(a.snapshots)-1].height > height {
+		snapshot := a.snapshots[len(a.snapshots)-1]
+		a.snapshots = a.snapshots[:len(a.snapshots)-1]
+
+		a.dutyIndex = snapshot.dutyIndex
+		a.currentArbitrators = snapshot.currentArbitrators
+		a.currentCandidates = snapshot.currentCandidates
+		a.nextArbitrators = snapshot.nextArbitrators
+		a.nextCandidates = snapshot.nextCandidates
+		a.currentOwnerProgramHashes = snapshot.currentOwnerProgramHashes
+		a.candidateOwnerProgramHashes = snapshot.candidateOwnerProgramHashes
+		a.ownerVotesInRound = snapshot.ownerVotesInRound
+		a.totalVotesInRound = snapshot.totalVotesInRound
+		a.lastChange = snapshot.lastChange
+		restored = true
+	}
+	if restored {
+		// The cached owners belong to the rolled back round.
+		a.roundOwners = nil
+	}
+}
+
+// copyByteList returns a copy of the byte slices list.
+func copyByteList(list [][]byte) [][]byte {
+	if list == nil {
+		return nil
+	}
+	result := make([][]byte, len(list))
+	copy(result, list)
+	return result
+}
+
+// updateCRCArbiters replaces the CRC arbiters by the latest CRCArbiterSchedule
+// entry takes effect on the change after the given height, and returns if the
+// CRC arbiters have been replaced.
+func (a *arbitrators) updateCRCArbiters(height uint32) (bool, error) {
+	var scheduleHeight uint32
+	crcArbiters := a.chainParams.CRCArbiters
+	for h, v := range a.chainParams.CRCArbiterSchedule {
+		if h <= height+1 && h > scheduleHeight {
+			scheduleHeight = h
+			crcArbiters = v
+		}
+	}
+	if scheduleHeight == a.crcScheduleHeight {
+		return false, nil
+	}
+
+	nodePublicKey, programHashes, err := newCRCArbiters(crcArbiters)
+	if err != nil {
+		return false, err
+	}
+	a.crcSwaps = append(a.crcSwaps, crcArbitersSwap{
+		height:         height,
+		scheduleHeight: a.crcScheduleHeight,
+		nodePublicKey:  a.crcArbitratorsNodePublicKey,
+		programHashes:  a.crcArbitratorsProgramHashes,
+	})
+	a.setCRCArbiters(scheduleHeight, nodePublicKey, programHashes)
+	return true, nil
+}
+
+func (a *arbitrators) setCRCArbiters(scheduleHeight uint32,
+	nodePublicKey map[string]*Producer,
+	programHashes map[common.Uint168]interface{}) {
+	a.crcScheduleHeight = scheduleHeight
+	a.crcArbitratorsNodePublicKey = nodePublicKey
+	a.crcArbitratorsProgramHashes = programHashes
+	a.arbitersCount = a.chainParams.GeneralArbiters + len(nodePublicKey)
+	a.State.setCRCArbiters(nodePublicKey)
+}
+
 func (a *arbitrators) IncreaseChainHeight(height uint32) {
 	var notify = true
 
@@ -139,16 +887,30 @@ func (a *arbitrators) IncreaseChainHeight(height uint32) {
 
 	changeType, versionHeight := a.getChangeType(height + 1)
 	switch changeType {
-	case updateNext:
+	case UpdateNext:
+		a.snapshotArbiters(height)
 		if err := a.updateNextArbitrators(versionHeight); err != nil {
-			log.Error("[IncreaseChainHeight] update next arbiters error: ", err)
+			// Lack of producers degrades the network instead of failing it.
+			if e, ok := err.(*SelectionError); ok &&
+				e.Code == InsufficientProducers {
+				log.Warn("[IncreaseChainHeight] network degraded: ", err)
+			} else {
+				log.Error("[IncreaseChainHeight] update next arbiters"+
+					" error: ", err)
+			}
+		}
+		a.lastChange = ArbitersChange{
+			Height:     height,
+			Reason:     ChangeReasonScheduledUpdateNext,
+			ChangeType: UpdateNext,
 		}
-	case normalChange:
+		a.updateNetworkMode(height, versionHeight)
+	case NormalChange:
 		if err := a.NormalChange(height); err != nil {
 			panic(fmt.Sprintf("normal change failed, %s height: %d",
 				err, height))
 		}
-	case none:
+	case None:
 		a.dutyIndex++
 		notify = false
 	}
@@ -159,6 +921,80 @@ func (a *arbitrators) IncreaseChainHeight(height uint32) {
 		events.Notify(events.ETDirectPeersChanged,
 			a.GetNeedConnectArbiters(versionHeight))
 	}
+	a.notifyNetworkModeChanges()
+}
+
+// updateNetworkMode checks if next arbiters are understaffed on the given
+// mode height, and queues a mode change if it differs from the current mode,
+// the change is recorded on the given height for rollback.
+func (a *arbitrators) updateNetworkMode(height, modeHeight uint32) {
+	if !a.IsPublicDPOSPeriod(modeHeight) {
+		return
+	}
+
+	mode := NetworkNormal
+	if len(a.nextArbitrators) < a.arbitersCount {
+		mode = NetworkUnderstaffed
+	}
+	if mode == a.networkMode {
+		return
+	}
+
+	a.networkMode = mode
+	a.modeRecords = append(a.modeRecords,
+		networkModeRecord{height: height, mode: mode})
+	a.pendingModeChanges = append(a.pendingModeChanges,
+		&NetworkModeChange{Mode: mode, Height: modeHeight})
+}
+
+// rollbackNetworkMode reverts the network mode changes made after the given
+// height, and queues a mode change if the mode has been restored.
+func (a *arbitrators) rollbackNetworkMode(height uint32) {
+	changed := false
+	for len(a.modeRecords) > 0 &&
+		a.modeRecords[len(a.modeRecords)-1].height > height {
+		a.modeRecords = a.modeRecords[:len(a.modeRecords)-1]
+		changed = true
+	}
+	if !changed {
+		return
+	}
+
+	mode := NetworkNormal
+	if len(a.modeRecords) > 0 {
+		mode = a.modeRecords[len(a.modeRecords)-1].mode
+	}
+	if mode == a.networkMode {
+		return
+	}
+	a.networkMode = mode
+	a.pendingModeChanges = append(a.pendingModeChanges,
+		&NetworkModeChange{Mode: mode, Height: height + 1})
+}
+
+// notifyNetworkModeChanges fires the queued network mode changes, it should
+// be called after arbiters mutex released.
+func (a *arbitrators) notifyNetworkModeChanges() {
+	a.mtx.Lock()
+	changes := a.pendingModeChanges
+	a.pendingModeChanges = nil
+	a.mtx.Unlock()
+
+	for _, c := range changes {
+		switch c.Mode {
+		case NetworkUnderstaffed:
+			events.Notify(events.ETNetworkDegraded, c)
+		case NetworkNormal:
+			events.Notify(events.ETNetworkRecovered, c)
+		}
+	}
+}
+
+// GetNetworkMode returns the current staffing mode of the DPOS network.
+func (a *arbitrators) GetNetworkMode() NetworkMode {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	return a.networkMode
 }
 
 func (a *arbitrators) DecreaseChainHeight(height uint32) {
@@ -175,7 +1011,7 @@ func (a *arbitrators) DecreaseChainHeight(height uint32) {
 func (a *arbitrators) GetNeedConnectArbiters(height uint32) map[string]*p2p.PeerAddr {
 	arbiters := make(map[string]*p2p.PeerAddr)
 
-	if height >= a.chainParams.CRCOnlyDPOSHeight-a.chainParams.PreConnectOffset {
+	if height >= a.preConnectHeight() {
 		a.mtx.Lock()
 		for k, v := range a.crcArbitratorsNodePublicKey {
 			arbiters[k] = a.generatePeerAddr(v.info.NodePublicKey,
@@ -200,7 +1036,84 @@ func (a *arbitrators) GetNeedConnectArbiters(height uint32) map[string]*p2p.Peer
 		a.mtx.Unlock()
 	}
 
-	return arbiters
+	return arbiters
+}
+
+// SortPeerAddrs returns the peer addresses of the given map sorted by PID,
+// nil addresses are skipped. Since GetNeedConnectArbiters returns a map, it's
+// used to connect the peers in a deterministic order.
+func SortPeerAddrs(peers map[string]*p2p.PeerAddr) []p2p.PeerAddr {
+	addrs := make([]p2p.PeerAddr, 0, len(peers))
+	for _, v := range peers {
+		if v != nil {
+			addrs = append(addrs, *v)
+		}
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return bytes.Compare(addrs[i].PID[:], addrs[j].PID[:]) < 0
+	})
+	return addrs
+}
+
+// GetNeedConnectArbitersRanked returns the same peers as
+// GetNeedConnectArbiters on the next height, ordered by priority to dial:
+// current arbiters by the distance to their duty turn starting with the
+// on-duty arbiter, then CRC arbiters, then the rest of next arbiters.
+func (a *arbitrators) GetNeedConnectArbitersRanked() []peer.PID {
+	height := a.bestHeight() + 1
+	if height < a.preConnectHeight() {
+		return nil
+	}
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	ranked := make([]peer.PID, 0)
+	added := make(map[string]struct{})
+	addArbiter := func(pk []byte) {
+		key := common.BytesToHexString(pk)
+		if _, ok := added[key]; ok {
+			return
+		}
+		added[key] = struct{}{}
+		pid := peer.PID{}
+		copy(pid[:], pk)
+		ranked = append(ranked, pid)
+	}
+
+	for i := range a.currentArbitrators {
+		if arbiter := a.GetNextOnDutyArbitratorV(height,
+			uint32(i)); arbiter != nil {
+			addArbiter(arbiter)
+		}
+	}
+
+	crcArbiters := make([][]byte, 0, len(a.crcArbitratorsNodePublicKey))
+	for _, v := range a.crcArbitratorsNodePublicKey {
+		crcArbiters = append(crcArbiters, v.info.NodePublicKey)
+	}
+	sort.Slice(crcArbiters, func(i, j int) bool {
+		return bytes.Compare(crcArbiters[i], crcArbiters[j]) < 0
+	})
+	for _, v := range crcArbiters {
+		addArbiter(v)
+	}
+
+	for _, v := range a.nextArbitrators {
+		addArbiter(v)
+	}
+
+	return ranked
+}
+
+// preConnectHeight returns the height to begin connecting to arbiters.
+func (a *arbitrators) preConnectHeight() uint32 {
+	offset := a.chainParams.PreConnectOffset +
+		a.chainParams.ExtraPreConnectOffset
+	if offset >= a.chainParams.CRCOnlyDPOSHeight {
+		return 0
+	}
+	return a.chainParams.CRCOnlyDPOSHeight - offset
 }
 
 func (a *arbitrators) getArbiterPeerAddr(pk []byte) *p2p.PeerAddr {
@@ -265,6 +1178,32 @@ func (a *arbitrators) GetNextCandidates() [][]byte {
 	return result
 }
 
+// GetUpcomingArbiterChanges returns the arbiters joining and leaving on the
+// next arbiters change, by the difference between next and current arbiters.
+func (a *arbitrators) GetUpcomingArbiterChanges() (joining [][]byte,
+	leaving [][]byte) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	current := make(map[string]struct{}, len(a.currentArbitrators))
+	for _, arbiter := range a.currentArbitrators {
+		current[hex.EncodeToString(arbiter)] = struct{}{}
+	}
+	next := make(map[string]struct{}, len(a.nextArbitrators))
+	for _, arbiter := range a.nextArbitrators {
+		next[hex.EncodeToString(arbiter)] = struct{}{}
+		if _, ok := current[hex.EncodeToString(arbiter)]; !ok {
+			joining = append(joining, arbiter)
+		}
+	}
+	for _, arbiter := range a.currentArbitrators {
+		if _, ok := next[hex.EncodeToString(arbiter)]; !ok {
+			leaving = append(leaving, arbiter)
+		}
+	}
+	return joining, leaving
+}
+
 func (a *arbitrators) IsCRCArbitratorProgramHash(hash *common.Uint168) bool {
 	_, ok := a.crcArbitratorsProgramHashes[*hash]
 	return ok
@@ -295,6 +1234,33 @@ func (a *arbitrators) GetCRCArbitrators() map[string]*Producer {
 	return a.crcArbitratorsNodePublicKey
 }
 
+// GetCRCArbiterDetails returns node public key, owner public key and program
+// hash of each CRC arbiter, sorted by node public key.
+func (a *arbitrators) GetCRCArbiterDetails() []CRCArbiterInfo {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	result := make([]CRCArbiterInfo, 0, len(a.crcArbitratorsNodePublicKey))
+	for _, v := range a.crcArbitratorsNodePublicKey {
+		hash, err := contract.PublicKeyToStandardProgramHash(
+			v.info.OwnerPublicKey)
+		if err != nil {
+			log.Warn("[GetCRCArbiterDetails] invalid owner public key: ", err)
+			continue
+		}
+		result = append(result, CRCArbiterInfo{
+			NodePublicKey:  v.info.NodePublicKey,
+			OwnerPublicKey: v.info.OwnerPublicKey,
+			ProgramHash:    *hash,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return bytes.Compare(result[i].NodePublicKey,
+			result[j].NodePublicKey) < 0
+	})
+	return result
+}
+
 func (a *arbitrators) GetCurrentOwnerProgramHashes() []*common.Uint168 {
 	a.mtx.Lock()
 	result := a.currentOwnerProgramHashes
@@ -327,10 +1293,166 @@ func (a *arbitrators) GetTotalVotesInRound() common.Fixed64 {
 	return result
 }
 
+// GetOwnerVotesInRound returns the votes of the given owner program hash in
+// current round, CRC arbiters have no votes in round.
+func (a *arbitrators) GetOwnerVotesInRound(
+	programHash common.Uint168) common.Fixed64 {
+	return a.GetOwnerVotes(&programHash)
+}
+
+// GetVotesInRound returns a copy of owner votes and the total votes in current
+// round, which are captured under one lock so they are consistent.
+func (a *arbitrators) GetVotesInRound() (map[common.Uint168]common.Fixed64,
+	common.Fixed64) {
+	a.mtx.Lock()
+	ownerVotes := make(map[common.Uint168]common.Fixed64,
+		len(a.ownerVotesInRound))
+	for k, v := range a.ownerVotesInRound {
+		ownerVotes[k] = v
+	}
+	total := a.totalVotesInRound
+	a.mtx.Unlock()
+
+	return ownerVotes, total
+}
+
 func (a *arbitrators) GetOnDutyArbitrator() []byte {
 	return a.GetNextOnDutyArbitratorV(a.bestHeight()+1, 0)
 }
 
+// IsOnDuty returns if the given node public key is the current on-duty
+// arbiter.
+func (a *arbitrators) IsOnDuty(nodePublicKey []byte) bool {
+	a.mtx.Lock()
+	onDuty := a.GetNextOnDutyArbitratorV(a.bestHeight()+1, 0)
+	a.mtx.Unlock()
+	return onDuty != nil && bytes.Equal(onDuty, nodePublicKey)
+}
+
+// ValidateConfirm checks the confirm of the given block is sponsored by the
+// on-duty arbiter of the proposal's view and accepted by majority of current
+// arbiters. It should be called before the block is processed.
+func (a *arbitrators) ValidateConfirm(block *types.Block,
+	confirm *payload.Confirm) error {
+	if confirm == nil {
+		return errors.New("confirm is nil")
+	}
+	if !confirm.Proposal.BlockHash.IsEqual(block.Hash()) {
+		return errors.New("confirm does not match the block")
+	}
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	onDuty := a.GetNextOnDutyArbitratorV(block.Height,
+		confirm.Proposal.ViewOffset)
+	if !bytes.Equal(onDuty, confirm.Proposal.Sponsor) {
+		return fmt.Errorf("sponsor %s is not the on-duty arbiter %s",
+			hex.EncodeToString(confirm.Proposal.Sponsor),
+			hex.EncodeToString(onDuty))
+	}
+
+	arbiters := make(map[string]struct{}, len(a.currentArbitrators))
+	for _, arbiter := range a.currentArbitrators {
+		arbiters[hex.EncodeToString(arbiter)] = struct{}{}
+	}
+	signers := make(map[string]struct{})
+	for _, vote := range confirm.Votes {
+		signer := hex.EncodeToString(vote.Signer)
+		if _, ok := arbiters[signer]; ok && vote.Accept {
+			signers[signer] = struct{}{}
+		}
+	}
+	majorityCount := MajorityCount(len(a.currentArbitrators))
+	if len(signers) <= majorityCount {
+		return fmt.Errorf("accepted signers %d not reach majority count %d",
+			len(signers), majorityCount)
+	}
+
+	return nil
+}
+
+// GetNonSigners returns the current arbiters whose votes are absent from the
+// given confirm, in the order of current arbiters.
+func (a *arbitrators) GetNonSigners(confirm *payload.Confirm) [][]byte {
+	signers := make(map[string]struct{}, len(confirm.Votes))
+	for _, vote := range confirm.Votes {
+		signers[hex.EncodeToString(vote.Signer)] = struct{}{}
+	}
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	nonSigners := make([][]byte, 0)
+	for _, arbiter := range a.currentArbitrators {
+		if _, ok := signers[hex.EncodeToString(arbiter)]; !ok {
+			nonSigners = append(nonSigners, arbiter)
+		}
+	}
+	return nonSigners
+}
+
+// GetOnDutyCrossChainArbitrator returns the arbiter in charge of the cross
+// chain transactions of the next block.
+func (a *arbitrators) GetOnDutyCrossChainArbitrator() []byte {
+	return a.GetOnDutyCrossChainArbitratorAtHeight(a.bestHeight() + 1)
+}
+
+// IsCRCOnlyPeriod returns if the given height is in the period the blocks are
+// produced by CRC arbiters only, that is [CRCOnlyDPOSHeight, PublicDPOSHeight).
+func (a *arbitrators) IsCRCOnlyPeriod(height uint32) bool {
+	return height >= a.chainParams.CRCOnlyDPOSHeight &&
+		height < a.chainParams.PublicDPOSHeight
+}
+
+// IsPublicDPOSPeriod returns if the given height is in the period the voted
+// producers take part in producing blocks, from PublicDPOSHeight on.
+func (a *arbitrators) IsPublicDPOSPeriod(height uint32) bool {
+	return height >= a.chainParams.PublicDPOSHeight
+}
+
+// GetOnDutyCrossChainArbitratorAtHeight returns the arbiter in charge of the
+// cross chain transactions of the given height. Since H1 the CRC arbiters in
+// charge of the height sorted by node public key take turns by height, before
+// H1 it's the on-duty arbiter of the old version.
+func (a *arbitrators) GetOnDutyCrossChainArbitratorAtHeight(
+	height uint32) []byte {
+	// old version
+	if height < a.State.chainParams.CRCOnlyDPOSHeight {
+		return a.getNextOnDutyArbitratorV0(height, 0)
+	}
+
+	// main version is >= H1
+	a.mtx.Lock()
+	nodePublicKey := a.crcArbitersAtHeight(height)
+	crcArbiters := make([][]byte, 0, len(nodePublicKey))
+	for _, v := range nodePublicKey {
+		crcArbiters = append(crcArbiters, v.info.NodePublicKey)
+	}
+	a.mtx.Unlock()
+	if len(crcArbiters) == 0 {
+		return nil
+	}
+	sort.Slice(crcArbiters, func(i, j int) bool {
+		return bytes.Compare(crcArbiters[i], crcArbiters[j]) < 0
+	})
+	index := (height - a.State.chainParams.CRCOnlyDPOSHeight) %
+		uint32(len(crcArbiters))
+	return crcArbiters[index]
+}
+
+// crcArbitersAtHeight returns the CRC arbiters in charge of the given height,
+// a swap made on height h takes effect from h+1 so the CRC arbiters replaced
+// by the first swap not below the given height are the ones in charge.
+func (a *arbitrators) crcArbitersAtHeight(height uint32) map[string]*Producer {
+	for _, swap := range a.crcSwaps {
+		if height <= swap.height {
+			return swap.nodePublicKey
+		}
+	}
+	return a.crcArbitratorsNodePublicKey
+}
+
 func (a *arbitrators) GetNextOnDutyArbitrator(offset uint32) []byte {
 	return a.GetNextOnDutyArbitratorV(a.bestHeight()+1, offset)
 }
@@ -361,8 +1483,7 @@ func (a *arbitrators) GetArbitersCount() int {
 
 func (a *arbitrators) GetArbitersMajorityCount() int {
 	a.mtx.Lock()
-	minSignCount := int(float64(len(a.currentArbitrators)) *
-		majoritySignRatioNumerator / majoritySignRatioDenominator)
+	minSignCount := MajorityCount(len(a.currentArbitrators))
 	a.mtx.Unlock()
 	return minSignCount
 }
@@ -375,46 +1496,106 @@ func (a *arbitrators) HasArbitersMinorityCount(num int) bool {
 	a.mtx.Lock()
 	count := len(a.currentArbitrators)
 	a.mtx.Unlock()
-	return num >= count-a.GetArbitersMajorityCount()
+	return num >= MinorityCount(count)
+}
+
+// MajorityCount returns the sign count of the given arbiters count, signs more
+// than which achieve majority.
+func MajorityCount(n int) int {
+	return int(float64(n) *
+		majoritySignRatioNumerator / majoritySignRatioDenominator)
+}
+
+// MinorityCount returns the minimum sign count of the given arbiters count to
+// achieve minority, which is enough to prevent a majority of the rest.
+func MinorityCount(n int) int {
+	return n - MajorityCount(n)
+}
+
+// GetChangeTypeAt returns the arbiters change type on the given height and the
+// version height the change belongs to.
+func (a *arbitrators) GetChangeTypeAt(height uint32) (ChangeType, uint32) {
+	a.mtx.Lock()
+	changeType, versionHeight := a.getChangeType(height)
+	a.mtx.Unlock()
+	return changeType, versionHeight
 }
 
 func (a *arbitrators) getChangeType(height uint32) (ChangeType, uint32) {
 
 	// special change points:
-	//		H1 - PreConnectOffset -> 	[updateNext, H1]: update next arbiters and let CRC arbiters prepare to connect
-	//		H1 -> 						[normalChange, H1]: should change to new election (that only have CRC arbiters)
-	//		H2 - PreConnectOffset -> 	[updateNext, H2]: update next arbiters and let normal arbiters prepare to connect
-	//		H2 -> 						[normalChange, H2]: should change to new election (arbiters will have both CRC and normal arbiters)
+	//		H1 - PreConnectOffset -> 	[UpdateNext, H1]: update next arbiters and let CRC arbiters prepare to connect
+	//		H1 -> 						[NormalChange, H1]: should change to new election (that only have CRC arbiters)
+	//		H2 - PreConnectOffset -> 	[UpdateNext, H2]: update next arbiters and let normal arbiters prepare to connect
+	//		H2 -> 						[NormalChange, H2]: should change to new election (arbiters will have both CRC and normal arbiters)
 	if height == a.State.chainParams.CRCOnlyDPOSHeight-
 		a.State.chainParams.PreConnectOffset {
-		return updateNext, a.State.chainParams.CRCOnlyDPOSHeight
+		return UpdateNext, a.State.chainParams.CRCOnlyDPOSHeight
 	} else if height == a.State.chainParams.CRCOnlyDPOSHeight {
-		return normalChange, a.State.chainParams.CRCOnlyDPOSHeight
+		return NormalChange, a.State.chainParams.CRCOnlyDPOSHeight
 	} else if height == a.State.chainParams.PublicDPOSHeight-
 		a.State.chainParams.PreConnectOffset {
-		return updateNext, a.State.chainParams.PublicDPOSHeight
+		return UpdateNext, a.State.chainParams.PublicDPOSHeight
 	} else if height == a.State.chainParams.PublicDPOSHeight {
-		return normalChange, a.State.chainParams.PublicDPOSHeight
+		return NormalChange, a.State.chainParams.PublicDPOSHeight
 	}
 
 	// main version >= H2
 	if height > a.State.chainParams.PublicDPOSHeight &&
 		a.dutyIndex == a.arbitersCount-1 {
-		return normalChange, height
+		return NormalChange, height
+	}
+
+	return None, height
+}
+
+// EstimateNextChangeHeight returns the height from which the next normal
+// change of arbiters will take effect, estimated by the current duty index and
+// the special change points, 0 means no change can be estimated.
+func (a *arbitrators) EstimateNextChangeHeight() uint32 {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	// the change type of the next block is decided by the height after it.
+	height := a.bestHeight() + 2
+
+	var estimate uint32
+	for _, h := range []uint32{a.chainParams.CRCOnlyDPOSHeight,
+		a.chainParams.PublicDPOSHeight} {
+		if h >= height && (estimate == 0 || h < estimate) {
+			estimate = h
+		}
 	}
 
-	return none, height
+	// main version >= H2, arbiters change when duty index reaches the last
+	// arbiter.
+	if a.arbitersCount > 0 {
+		var remains uint32
+		if a.dutyIndex < a.arbitersCount-1 {
+			remains = uint32(a.arbitersCount - 1 - a.dutyIndex)
+		}
+		rotation := height + remains
+		if rotation > a.chainParams.PublicDPOSHeight &&
+			(estimate == 0 || rotation < estimate) {
+			estimate = rotation
+		}
+	}
+
+	return estimate
 }
 
-func (a *arbitrators) changeCurrentArbitrators() error {
+func (a *arbitrators) changeCurrentArbitrators(height uint32) error {
 	a.currentArbitrators = a.nextArbitrators
 	a.currentCandidates = a.nextCandidates
 
 	sort.Slice(a.currentArbitrators, func(i, j int) bool {
 		return bytes.Compare(a.currentArbitrators[i], a.currentArbitrators[j]) < 0
 	})
+	if height >= a.chainParams.ShuffleArbitersHeight {
+		shuffleArbiters(a.currentArbitrators, a.lastBlockHash())
+	}
 
-	if err := a.updateOwnerProgramHashes(); err != nil {
+	if err := a.updateOwnerProgramHashes(height); err != nil {
 		return err
 	}
 
@@ -422,44 +1603,218 @@ func (a *arbitrators) changeCurrentArbitrators() error {
 	return nil
 }
 
+// recordPromotions records the producers moving in or out of the arbiters by
+// diffing the current arbiters against the previous ones, origin and CRC
+// arbiters are not producers so they are not recorded.
+func (a *arbitrators) recordPromotions(height uint32, previous [][]byte) {
+	previousSet := make(map[string]struct{}, len(previous))
+	for _, arbiter := range previous {
+		previousSet[hex.EncodeToString(arbiter)] = struct{}{}
+	}
+	currentSet := make(map[string]struct{}, len(a.currentArbitrators))
+	for _, arbiter := range a.currentArbitrators {
+		currentSet[hex.EncodeToString(arbiter)] = struct{}{}
+	}
+
+	record := func(nodePublicKey []byte, t PromotionType) {
+		if a.getProducer(nodePublicKey) == nil {
+			return
+		}
+		if len(a.promotions) >= maxPromotionRecords {
+			a.promotions = a.promotions[1:]
+		}
+		a.promotions = append(a.promotions, PromotionRecord{
+			Height:        height,
+			NodePublicKey: nodePublicKey,
+			Type:          t,
+		})
+	}
+	for _, arbiter := range a.currentArbitrators {
+		if _, ok := previousSet[hex.EncodeToString(arbiter)]; !ok {
+			record(arbiter, Promoted)
+		}
+	}
+	for _, arbiter := range previous {
+		if _, ok := currentSet[hex.EncodeToString(arbiter)]; !ok {
+			record(arbiter, Dropped)
+		}
+	}
+}
+
+// GetRecentPromotions returns the last n records of producers moving in or
+// out of the arbiters, the oldest first.
+func (a *arbitrators) GetRecentPromotions(n int) []PromotionRecord {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if n <= 0 {
+		return nil
+	}
+	start := 0
+	if len(a.promotions) > n {
+		start = len(a.promotions) - n
+	}
+	return append([]PromotionRecord{}, a.promotions[start:]...)
+}
+
+// lastBlockHash returns the hash of the last processed block, which is the
+// block on the change height when arbiters are changing.
+func (a *arbitrators) lastBlockHash() common.Uint256 {
+	if len(a.blockCountHistory) == 0 {
+		return common.Uint256{}
+	}
+	return a.blockCountHistory[len(a.blockCountHistory)-1].hash
+}
+
+// shuffleArbiters permutes the arbiters deterministically by the given seed, so
+// all nodes with the same seed get the same order.
+func shuffleArbiters(arbiters [][]byte, seed common.Uint256) {
+	buf := make([]byte, len(seed)+4)
+	copy(buf, seed[:])
+	for i := len(arbiters) - 1; i > 0; i-- {
+		binary.LittleEndian.PutUint32(buf[len(seed):], uint32(i))
+		hash := sha256.Sum256(buf)
+		j := binary.LittleEndian.Uint64(hash[:8]) % uint64(i+1)
+		arbiters[i], arbiters[j] = arbiters[j], arbiters[i]
+	}
+}
+
 func (a *arbitrators) updateNextArbitrators(height uint32) error {
+	producers := a.State.getProducers()
+	a.updateProducerRanks(producers)
+	arbiters, candidates, err := a.selectNextArbitrators(height, producers)
+	a.nextArbitrators = arbiters
+	if err != nil {
+		return err
+	}
+	a.nextCandidates = candidates
+
+	return nil
+}
+
+// updateProducerRanks keeps the ranks of the last update as the previous ranks
+// and ranks the given producers by votes.
+func (a *arbitrators) updateProducerRanks(producers []*Producer) {
+	sorted := append([]*Producer{}, producers...)
+	sortProducers(sorted, a.producerLess)
+	ranks := make(map[string]int, len(sorted))
+	for i, p := range sorted {
+		ranks[hex.EncodeToString(p.info.NodePublicKey)] = i + 1
+	}
+	a.previousRanks = a.currentRanks
+	a.currentRanks = ranks
+}
+
+// GetProducerRankDelta returns how many places the producer climbed in the
+// vote sorted ranking between the last two next arbiters updates, negative if
+// it dropped.  A producer missing from one of the rankings is taken as ranked
+// right after the last producer of that ranking.  Zero is returned if there is
+// no previous ranking or the producer is in neither of them.
+func (a *arbitrators) GetProducerRankDelta(nodePublicKey []byte) int {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if a.previousRanks == nil {
+		return 0
+	}
+	key := hex.EncodeToString(nodePublicKey)
+	previous, inPrevious := a.previousRanks[key]
+	current, inCurrent := a.currentRanks[key]
+	if !inPrevious && !inCurrent {
+		return 0
+	}
+	if !inPrevious {
+		previous = len(a.previousRanks) + 1
+	}
+	if !inCurrent {
+		current = len(a.currentRanks) + 1
+	}
+	return previous - current
+}
+
+// selectNextArbitrators selects the next arbiters and candidates from the
+// given producers, the CRC arbiters not inactive come first in the arbiters.
+// The arbiters selected so far are also returned on error.
+func (a *arbitrators) selectNextArbitrators(height uint32,
+	producers []*Producer) ([][]byte, [][]byte, error) {
 	var crcCount int
-	a.nextArbitrators = make([][]byte, 0)
+	arbiters := make([][]byte, 0)
 	for _, v := range a.crcArbitratorsNodePublicKey {
 		if !a.isInactiveProducer(v.info.NodePublicKey) {
-			a.nextArbitrators = append(a.nextArbitrators, v.info.NodePublicKey)
+			arbiters = append(arbiters, v.info.NodePublicKey)
 		} else {
 			crcCount++
 		}
 	}
 	count := a.chainParams.GeneralArbiters + crcCount
-	producers, err := a.GetNormalArbitratorsDesc(height, count, a.State.getProducers())
+	normal, err := a.GetNormalArbitratorsDesc(height, count, producers)
 	if err != nil {
-		return err
-	}
-	for _, v := range producers {
-		a.nextArbitrators = append(a.nextArbitrators, v)
+		return arbiters, nil, err
 	}
+	arbiters = append(arbiters, normal...)
 
-	candidates, err := a.GetCandidatesDesc(height, count, a.State.getProducers())
+	candidates, err := a.GetCandidatesDesc(height, count, producers)
 	if err != nil {
-		return err
+		return arbiters, nil, err
 	}
-	a.nextCandidates = candidates
+	return arbiters, candidates, nil
+}
 
-	return nil
+// SimulateArbiterSet returns the next arbiters and candidates that would be
+// selected if votes of producers were adjusted by the given amounts, keyed by
+// the producer's node or owner public key in hex string format.  It's a read
+// only simulation, the state is not changed.  Votes above
+// "MaxVotesPerProducer" are not counted as in the round to be changed.
+func (a *arbitrators) SimulateArbiterSet(
+	adjustments map[string]common.Fixed64) ([][]byte, [][]byte) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	a.State.mtx.RLock()
+	defer a.State.mtx.RUnlock()
+
+	deltas := make(map[string]common.Fixed64, len(adjustments))
+	for key, delta := range adjustments {
+		publicKey, err := hex.DecodeString(key)
+		if err != nil {
+			continue
+		}
+		deltas[a.getProducerKey(publicKey)] += delta
+	}
+
+	height := a.bestHeight() + 1
+	producers := make([]*Producer, 0, len(a.activityProducers))
+	for key, p := range a.activityProducers {
+		producer := *p
+		producer.votes += deltas[key]
+		if producer.votes < 0 {
+			producer.votes = 0
+		}
+		producer.votes = a.roundVotes(producer.votes, height)
+		producers = append(producers, &producer)
+	}
+
+	arbiters, candidates, err := a.selectNextArbitrators(height, producers)
+	if err != nil {
+		log.Warn("[SimulateArbiterSet] select arbiters error: ", err)
+		return nil, nil
+	}
+	return arbiters, candidates
 }
 
 func (a *arbitrators) GetCandidatesDesc(height uint32, startIndex int,
 	producers []*Producer) ([][]byte, error) {
 	// main version >= H2
-	if height >= a.State.chainParams.PublicDPOSHeight {
+	if a.IsPublicDPOSPeriod(height) {
 		if len(producers) < startIndex {
 			return make([][]byte, 0), nil
 		}
 
-		sort.Slice(producers, func(i, j int) bool {
-			return producers[i].Votes() > producers[j].Votes()
+		// Producers before startIndex are selected as arbiters by votes, the
+		// rest are selected as candidates by candidate votes then by votes.
+		sortProducers(producers, a.producerLess)
+		candidates := producers[startIndex:]
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].candidateVotes > candidates[j].candidateVotes
 		})
 
 		result := make([][]byte, 0)
@@ -477,18 +1832,16 @@ func (a *arbitrators) GetCandidatesDesc(height uint32, startIndex int,
 func (a *arbitrators) GetNormalArbitratorsDesc(height uint32,
 	arbitratorsCount int, producers []*Producer) ([][]byte, error) {
 	// main version >= H2
-	if height >= a.State.chainParams.PublicDPOSHeight {
+	if a.IsPublicDPOSPeriod(height) {
 		if len(producers) < arbitratorsCount/2+1 {
-			return nil, errors.New("producers count less than min arbitrators count")
+			return nil, &SelectionError{
+				Code:      InsufficientProducers,
+				Available: len(producers),
+				Required:  arbitratorsCount/2 + 1,
+			}
 		}
 
-		sort.Slice(producers, func(i, j int) bool {
-			if producers[i].votes == producers[j].votes {
-				return bytes.Compare(producers[i].info.NodePublicKey,
-					producers[j].NodePublicKey()) < 0
-			}
-			return producers[i].Votes() > producers[j].Votes()
-		})
+		sortProducers(producers, a.producerLess)
 
 		result := make([][]byte, 0)
 		for i := 0; i < arbitratorsCount && i < len(producers); i++ {
@@ -506,30 +1859,149 @@ func (a *arbitrators) GetNormalArbitratorsDesc(height uint32,
 	return a.getNormalArbitratorsDescV0()
 }
 
-func (a *arbitrators) updateOwnerProgramHashes() error {
+// SelectionErrorCode identifies the reason selecting arbiters failed.
+type SelectionErrorCode byte
+
+const (
+	// InsufficientProducers indicates there are not enough active producers
+	// to select the minimum count of arbiters.
+	InsufficientProducers SelectionErrorCode = iota
+)
+
+func (c SelectionErrorCode) String() string {
+	switch c {
+	case InsufficientProducers:
+		return "InsufficientProducers"
+	default:
+		return "Unknown"
+	}
+}
+
+// SelectionError is the error of selecting arbiters, with the count of
+// producers available and the count required.
+type SelectionError struct {
+	Code      SelectionErrorCode
+	Available int
+	Required  int
+}
+
+func (e *SelectionError) Error() string {
+	return fmt.Sprintf("select arbiters failed, %s: %d producers available,"+
+		" %d required", e.Code, e.Available, e.Required)
+}
+
+// ProducerComparator reports whether producer x should be selected before
+// producer y as arbiter.  It must be a pure function of on-chain data of the
+// producers so all nodes select the same arbiters.
+type ProducerComparator func(x, y *Producer) bool
+
+// ArbitratorsOption configures the arbitrators on construction.
+type ArbitratorsOption func(a *arbitrators)
+
+// WithProducerComparator replaces the default vote based comparator used to
+// select arbiters and candidates from producers.
+func WithProducerComparator(less ProducerComparator) ArbitratorsOption {
+	return func(a *arbitrators) {
+		a.producerLess = less
+	}
+}
+
+// WithTxReference sets the function to get outputs referenced by inputs of a
+// transaction, self votes can not be found without it.
+func WithTxReference(getTxReference func(tx *types.Transaction) (
+	map[*types.Input]*types.Output, error)) ArbitratorsOption {
+	return func(a *arbitrators) {
+		a.State.getTxReference = getTxReference
+	}
+}
+
+// compareProducersByVotes orders producers by votes descending, it's the
+// default comparator of arbiters selection.
+func compareProducersByVotes(x, y *Producer) bool {
+	return x.votes > y.votes
+}
+
+// sortProducers sorts producers by the given comparator, producers the
+// comparator can not order are sorted by node public key.
+func sortProducers(producers []*Producer, less ProducerComparator) {
+	sort.Slice(producers, func(i, j int) bool {
+		if less(producers[i], producers[j]) {
+			return true
+		}
+		if less(producers[j], producers[i]) {
+			return false
+		}
+		return bytes.Compare(producers[i].info.NodePublicKey,
+			producers[j].info.NodePublicKey) < 0
+	})
+}
+
+// roundVotes returns the votes of a producer counted in the round changed on
+// the given height, from VotesCapHeight votes above "MaxVotesPerProducer" are
+// not counted.
+func (a *arbitrators) roundVotes(votes common.Fixed64,
+	height uint32) common.Fixed64 {
+	limit := a.chainParams.MaxVotesPerProducer
+	if height >= a.chainParams.VotesCapHeight && limit > 0 && votes > limit {
+		return limit
+	}
+	return votes
+}
+
+// updateOwnerProgramHashes updates the owner program hashes and votes of
+// current arbiters and candidates.  Owners of producers whose votes and info
+// have not been changed since last round are taken from the cache, others are
+// recomputed.
+func (a *arbitrators) updateOwnerProgramHashes(height uint32) error {
+	changed := a.State.takeChangedProducers()
+	cached := a.roundOwners
+	a.roundOwners = make(map[string]*roundOwner)
+
+	getRoundOwner := func(nodePublicKey []byte, isCRC bool) (*roundOwner,
+		error) {
+		key := common.BytesToHexString(nodePublicKey)
+		if owner, ok := cached[key]; ok {
+			if _, ok := changed[owner.producer]; !ok {
+				a.roundOwners[key] = owner
+				return owner, nil
+			}
+		}
+
+		var owner roundOwner
+		ownerPublicKey := nodePublicKey // crc node public key is its owner public key for now
+		if !isCRC {
+			owner.producer = a.GetProducer(nodePublicKey)
+			if owner.producer == nil {
+				return nil, errors.New("get producer by node public key failed")
+			}
+			ownerPublicKey = owner.producer.OwnerPublicKey()
+			owner.votes = owner.producer.Votes()
+		}
+		programHash, err := contract.PublicKeyToStandardProgramHash(ownerPublicKey)
+		if err != nil {
+			return nil, err
+		}
+		owner.programHash = programHash
+		a.roundOwners[key] = &owner
+		return &owner, nil
+	}
+
 	a.currentOwnerProgramHashes = make([]*common.Uint168, 0)
 	a.ownerVotesInRound = make(map[common.Uint168]common.Fixed64, 0)
 	for _, nodePublicKey := range a.currentArbitrators {
-		if a.IsCRCArbitratorNodePublicKey(common.BytesToHexString(nodePublicKey)) {
-			ownerPublicKey := nodePublicKey // crc node public key is its owner public key for now
-			programHash, err := contract.PublicKeyToStandardProgramHash(ownerPublicKey)
-			if err != nil {
-				return err
-			}
-			a.currentOwnerProgramHashes = append(a.currentOwnerProgramHashes, programHash)
-		} else {
-			producer := a.GetProducer(nodePublicKey)
-			if producer == nil {
-				return errors.New("get producer by node public key failed")
-			}
-			ownerPublicKey := producer.OwnerPublicKey()
-			programHash, err := contract.PublicKeyToStandardProgramHash(ownerPublicKey)
-			if err != nil {
-				return err
-			}
-			a.currentOwnerProgramHashes = append(a.currentOwnerProgramHashes, programHash)
-			a.ownerVotesInRound[*programHash] = producer.Votes()
-			a.totalVotesInRound += producer.Votes()
+		isCRC := a.IsCRCArbitratorNodePublicKey(
+			common.BytesToHexString(nodePublicKey))
+		owner, err := getRoundOwner(nodePublicKey, isCRC)
+		if err != nil {
+			a.roundOwners = nil
+			return err
+		}
+		a.currentOwnerProgramHashes = append(a.currentOwnerProgramHashes,
+			owner.programHash)
+		if !isCRC {
+			votes := a.roundVotes(owner.votes, height)
+			a.ownerVotesInRound[*owner.programHash] = votes
+			a.totalVotesInRound += votes
 		}
 	}
 
@@ -538,17 +2010,16 @@ func (a *arbitrators) updateOwnerProgramHashes() error {
 		if a.IsCRCArbitratorNodePublicKey(common.BytesToHexString(nodePublicKey)) {
 			continue
 		}
-		producer := a.GetProducer(nodePublicKey)
-		if producer == nil {
-			return errors.New("get producer by node public key failed")
-		}
-		programHash, err := contract.PublicKeyToStandardProgramHash(producer.OwnerPublicKey())
+		owner, err := getRoundOwner(nodePublicKey, false)
 		if err != nil {
+			a.roundOwners = nil
 			return err
 		}
-		a.candidateOwnerProgramHashes = append(a.candidateOwnerProgramHashes, programHash)
-		a.ownerVotesInRound[*programHash] = producer.Votes()
-		a.totalVotesInRound += producer.Votes()
+		a.candidateOwnerProgramHashes = append(a.candidateOwnerProgramHashes,
+			owner.programHash)
+		votes := a.roundVotes(owner.votes, height)
+		a.ownerVotesInRound[*owner.programHash] = votes
+		a.totalVotesInRound += votes
 	}
 
 	return nil
@@ -561,11 +2032,11 @@ func (a *arbitrators) DumpInfo() {
 	var printer func(string, ...interface{})
 	changeType, _ := a.getChangeType(a.State.history.height + 1)
 	switch changeType {
-	case updateNext:
+	case UpdateNext:
 		fallthrough
-	case normalChange:
+	case NormalChange:
 		printer = log.Debugf
-	case none:
+	case None:
 		printer = log.Infof
 	}
 
@@ -637,38 +2108,41 @@ func (a *arbitrators) getArbitersInfoWithoutOnduty(title string, arbiters [][]by
 	return info, params
 }
 
-func NewArbitrators(chainParams *config.Params, bestHeight func() uint32) (*arbitrators, error) {
-
-	originArbiters := make([][]byte, len(chainParams.OriginArbiters))
-	originArbitersProgramHashes := make([]*common.Uint168, len(chainParams.OriginArbiters))
-	for i, arbiter := range chainParams.OriginArbiters {
-		a, err := common.HexStringToBytes(arbiter)
-		if err != nil {
-			return nil, err
-		}
-		originArbiters[i] = a
-
+// newOriginArbiters decodes the origin arbiters in hex string format and
+// returns them with their program hashes.
+func newOriginArbiters(arbiters []string) ([][]byte, []*common.Uint168,
+	error) {
+	originArbiters := make([][]byte, len(arbiters))
+	programHashes := make([]*common.Uint168, len(arbiters))
+	for i, arbiter := range arbiters {
 		publicKey, err := common.HexStringToBytes(arbiter)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		hash, err := contract.PublicKeyToStandardProgramHash(publicKey)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		originArbitersProgramHashes[i] = hash
+		originArbiters[i] = publicKey
+		programHashes[i] = hash
 	}
+	return originArbiters, programHashes, nil
+}
 
+// newCRCArbiters creates the CRC arbiters by node public key and the program
+// hashes set from the CRC arbiters config.
+func newCRCArbiters(crcArbiters []config.CRCArbiter) (map[string]*Producer,
+	map[common.Uint168]interface{}, error) {
 	crcNodeMap := make(map[string]*Producer)
 	crcArbitratorsProgramHashes := make(map[common.Uint168]interface{})
-	for _, v := range chainParams.CRCArbiters {
+	for _, v := range crcArbiters {
 		pubKey, err := hex.DecodeString(v.PublicKey)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		hash, err := contract.PublicKeyToStandardProgramHash(pubKey)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		crcArbitratorsProgramHashes[*hash] = nil
 		crcNodeMap[v.PublicKey] = &Producer{ // here need crc NODE public key
@@ -680,6 +2154,23 @@ func NewArbitrators(chainParams *config.Params, bestHeight func() uint32) (*arbi
 			activateRequestHeight: math.MaxUint32,
 		}
 	}
+	return crcNodeMap, crcArbitratorsProgramHashes, nil
+}
+
+func NewArbitrators(chainParams *config.Params, bestHeight func() uint32,
+	opts ...ArbitratorsOption) (*arbitrators, error) {
+
+	originArbiters, originArbitersProgramHashes, err :=
+		newOriginArbiters(chainParams.OriginArbiters)
+	if err != nil {
+		return nil, err
+	}
+
+	crcNodeMap, crcArbitratorsProgramHashes, err :=
+		newCRCArbiters(chainParams.CRCArbiters)
+	if err != nil {
+		return nil, err
+	}
 
 	arbitersCount := chainParams.GeneralArbiters + len(chainParams.CRCArbiters)
 	a := &arbitrators{
@@ -687,13 +2178,20 @@ func NewArbitrators(chainParams *config.Params, bestHeight func() uint32) (*arbi
 		bestHeight:                  bestHeight,
 		arbitersCount:               arbitersCount,
 		currentArbitrators:          originArbiters,
+		originArbiters:              originArbiters,
 		currentOwnerProgramHashes:   originArbitersProgramHashes,
 		nextArbitrators:             originArbiters,
 		nextCandidates:              make([][]byte, 0),
 		crcArbitratorsNodePublicKey: crcNodeMap,
 		crcArbitratorsProgramHashes: crcArbitratorsProgramHashes,
+		blockCounts:                 make(map[string]uint32),
+		producerLess:                compareProducersByVotes,
 	}
 	a.State = NewState(chainParams, a.GetArbitrators)
+	a.State.setCRCArbiters(crcNodeMap)
+	for _, opt := range opts {
+		opt(a)
+	}
 
 	return a, nil
 }
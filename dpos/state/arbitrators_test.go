@@ -0,0 +1,1981 @@
+package state
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/common/config"
+	"github.com/elastos/Elastos.ELA/core/contract"
+	"github.com/elastos/Elastos.ELA/core/types"
+	"github.com/elastos/Elastos.ELA/core/types/outputpayload"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/crypto"
+	"github.com/elastos/Elastos.ELA/dpos/p2p/peer"
+	"github.com/elastos/Elastos.ELA/events"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArbitrators_GetChangeTypeAt(t *testing.T) {
+	params := config.DefaultParams
+	params.CRCOnlyDPOSHeight = 1000
+	params.PublicDPOSHeight = 2000
+	params.PreConnectOffset = 100
+	a, err := NewArbitrators(&params, func() uint32 { return 0 })
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	tests := []struct {
+		height        uint32
+		changeType    ChangeType
+		versionHeight uint32
+	}{
+		{899, None, 899},
+		{900, UpdateNext, 1000},
+		{901, None, 901},
+		{999, None, 999},
+		{1000, NormalChange, 1000},
+		{1001, None, 1001},
+		{1900, UpdateNext, 2000},
+		{2000, NormalChange, 2000},
+		{2001, None, 2001},
+	}
+	for _, test := range tests {
+		changeType, versionHeight := a.GetChangeTypeAt(test.height)
+		if !assert.Equal(t, test.changeType, changeType,
+			"height %d", test.height) {
+			t.FailNow()
+		}
+		if !assert.Equal(t, test.versionHeight, versionHeight,
+			"height %d", test.height) {
+			t.FailNow()
+		}
+	}
+
+	// After H2, arbiters change when duty index reaches the last arbiter.
+	a.dutyIndex = a.arbitersCount - 1
+	changeType, versionHeight := a.GetChangeTypeAt(2001)
+	assert.Equal(t, NormalChange, changeType)
+	assert.Equal(t, uint32(2001), versionHeight)
+}
+
+func TestArbitrators_GetAccumulatedReward(t *testing.T) {
+	params := config.DefaultParams
+	params.PublicDPOSHeight = 2000
+	a, err := NewArbitrators(&params, func() uint32 { return 0 })
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	owner := common.Uint168{1}
+	other := common.Uint168{2}
+	coinbase := func(values ...common.Fixed64) *types.Transaction {
+		outputs := []*types.Output{{}, {}}
+		for i, value := range values {
+			hash := owner
+			if i%2 == 1 {
+				hash = other
+			}
+			outputs = append(outputs,
+				&types.Output{ProgramHash: hash, Value: value})
+		}
+		return &types.Transaction{TxType: types.CoinBase, Outputs: outputs}
+	}
+
+	// Rewards before public DPOS height are not recorded.
+	a.recordRewards(&types.Block{
+		Header:       types.Header{Height: 1999},
+		Transactions: []*types.Transaction{coinbase(100)},
+	})
+	_, err = a.GetAccumulatedReward(owner, 1999, 1999)
+	assert.Error(t, err)
+
+	for i := uint32(0); i < 5; i++ {
+		a.recordRewards(&types.Block{
+			Header: types.Header{Height: 2000 + i},
+			Transactions: []*types.Transaction{
+				coinbase(common.Fixed64(i+1)*100, 10)},
+		})
+	}
+
+	reward, err := a.GetAccumulatedReward(owner, 2000, 2004)
+	assert.NoError(t, err)
+	assert.Equal(t, common.Fixed64(1500), reward)
+
+	reward, err = a.GetAccumulatedReward(owner, 2001, 2002)
+	assert.NoError(t, err)
+	assert.Equal(t, common.Fixed64(500), reward)
+
+	reward, err = a.GetAccumulatedReward(other, 2000, 2004)
+	assert.NoError(t, err)
+	assert.Equal(t, common.Fixed64(50), reward)
+
+	reward, err = a.GetAccumulatedReward(common.Uint168{3}, 2000, 2004)
+	assert.NoError(t, err)
+	assert.Equal(t, common.Fixed64(0), reward)
+
+	_, err = a.GetAccumulatedReward(owner, 2003, 2001)
+	assert.Error(t, err)
+	_, err = a.GetAccumulatedReward(owner, 2000, 2005)
+	assert.Error(t, err)
+
+	// Oldest rewards are dropped when history is full.
+	for i := uint32(5); i < maxRewardHistory+1; i++ {
+		a.recordRewards(&types.Block{
+			Header:       types.Header{Height: 2000 + i},
+			Transactions: []*types.Transaction{coinbase(1)},
+		})
+	}
+	_, err = a.GetAccumulatedReward(owner, 2000, 2001)
+	assert.Error(t, err)
+	reward, err = a.GetAccumulatedReward(owner, 2001, 2001)
+	assert.NoError(t, err)
+	assert.Equal(t, common.Fixed64(200), reward)
+}
+
+func TestArbitrators_GetLastChange(t *testing.T) {
+	params := config.DefaultParams
+	params.CRCOnlyDPOSHeight = 1000
+	params.PublicDPOSHeight = 2000
+	a, err := NewArbitrators(&params, func() uint32 { return 0 })
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, ArbitersChange{}, a.GetLastChange())
+
+	err = a.ProcessSpecialTxPayload(&payload.InactiveArbitrators{}, 1500)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, ArbitersChange{
+		Height:     1500,
+		Reason:     ChangeReasonInactivePayload,
+		ChangeType: NormalChange,
+	}, a.GetLastChange())
+}
+
+// mockRoundArbitrators creates an arbitrators instance with the given count of
+// active producers, and set CRC arbiters and the first half of producers as
+// current arbiters, the others as current candidates.
+func mockRoundArbitrators(count int) (*arbitrators, []*payload.ProducerInfo) {
+	params := config.DefaultParams
+	a, _ := NewArbitrators(&params, func() uint32 { return 0 })
+
+	producers := make([]*payload.ProducerInfo, count)
+	txs := make([]*types.Transaction, count)
+	for i := range producers {
+		_, pk, _ := crypto.GenerateKeyPair()
+		ownerPublicKey, _ := pk.EncodePoint(true)
+		producers[i] = &payload.ProducerInfo{
+			OwnerPublicKey: ownerPublicKey,
+			NodePublicKey:  make([]byte, 33),
+			NickName:       fmt.Sprintf("Producer-%d", i+1),
+		}
+		rand.Read(producers[i].NodePublicKey)
+		txs[i] = mockRegisterProducerTx(producers[i])
+	}
+	a.State.ProcessBlock(mockBlock(1, txs...), nil)
+	for i := uint32(2); i <= 6; i++ {
+		a.State.ProcessBlock(mockBlock(i), nil)
+	}
+
+	a.currentArbitrators = make([][]byte, 0)
+	for _, v := range a.crcArbitratorsNodePublicKey {
+		a.currentArbitrators = append(a.currentArbitrators,
+			v.info.NodePublicKey)
+	}
+	a.currentCandidates = make([][]byte, 0)
+	for i, p := range producers {
+		if i < count/2 {
+			a.currentArbitrators = append(a.currentArbitrators,
+				p.NodePublicKey)
+		} else {
+			a.currentCandidates = append(a.currentCandidates,
+				p.NodePublicKey)
+		}
+	}
+	return a, producers
+}
+
+func TestArbitrators_UpdateOwnerProgramHashesIncremental(t *testing.T) {
+	a, producers := mockRoundArbitrators(20)
+
+	// The first update is a full recompute.
+	if !assert.NoError(t, a.updateOwnerProgramHashes(0)) {
+		t.FailNow()
+	}
+	assert.Equal(t, len(a.currentArbitrators)+len(a.currentCandidates),
+		len(a.roundOwners))
+
+	height := uint32(7)
+	var voteTxs []*types.Transaction
+	for round := 0; round < 5; round++ {
+		// Vote to a few producers and cancel the earliest votes.
+		publicKeys := [][]byte{
+			producers[round].OwnerPublicKey,
+			producers[round+10].OwnerPublicKey,
+		}
+		voteTx := mockMultiVoteTx(publicKeys)
+		voteTxs = append(voteTxs, voteTx)
+		a.State.ProcessBlock(mockBlock(height, voteTx), nil)
+		height++
+		if round > 1 {
+			a.State.ProcessBlock(mockBlock(height,
+				mockCancelVoteTx(voteTxs[round-2])), nil)
+			height++
+		}
+
+		// Update incrementally.
+		totalVotes := a.totalVotesInRound
+		if !assert.NoError(t, a.updateOwnerProgramHashes(0)) {
+			t.FailNow()
+		}
+		incrementalTotal := a.totalVotesInRound - totalVotes
+		incrementalVotes := a.ownerVotesInRound
+		incrementalOwners := a.currentOwnerProgramHashes
+		incrementalCandidates := a.candidateOwnerProgramHashes
+
+		// Update by full recompute.
+		a.roundOwners = nil
+		totalVotes = a.totalVotesInRound
+		if !assert.NoError(t, a.updateOwnerProgramHashes(0)) {
+			t.FailNow()
+		}
+		assert.Equal(t, a.totalVotesInRound-totalVotes, incrementalTotal)
+		assert.Equal(t, a.ownerVotesInRound, incrementalVotes)
+		assert.Equal(t, a.currentOwnerProgramHashes, incrementalOwners)
+		assert.Equal(t, a.candidateOwnerProgramHashes, incrementalCandidates)
+	}
+}
+
+func BenchmarkArbitrators_UpdateOwnerProgramHashes(b *testing.B) {
+	a, _ := mockRoundArbitrators(100)
+
+	b.Run("full", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			a.roundOwners = nil
+			a.updateOwnerProgramHashes(0)
+		}
+	})
+
+	b.Run("incremental", func(b *testing.B) {
+		a.updateOwnerProgramHashes(0)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			a.updateOwnerProgramHashes(0)
+		}
+	})
+}
+
+func TestArbitrators_RegisterOnBlockReward(t *testing.T) {
+	params := config.DefaultParams
+	params.CRCOnlyDPOSHeight = 1000
+	params.PublicDPOSHeight = 2000
+	a, err := NewArbitrators(&params, func() uint32 { return 0 })
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	rewards := make(map[uint32]common.Fixed64)
+	a.RegisterOnBlockReward(func(height uint32, reward common.Fixed64) {
+		rewards[height] = reward
+	})
+
+	coinbase := &types.Transaction{
+		TxType: types.CoinBase,
+		Outputs: []*types.Output{
+			{Value: 300}, {Value: 350}, {Value: 200}, {Value: 150},
+		},
+	}
+	for i := uint32(1999); i <= 2001; i++ {
+		a.notifyBlockReward(&types.Block{
+			Header:       types.Header{Height: i},
+			Transactions: []*types.Transaction{coinbase},
+		})
+	}
+
+	// Callback does not fire at or below public DPOS height.
+	assert.Equal(t, map[uint32]common.Fixed64{2001: 350}, rewards)
+}
+
+func TestArbitrators_GetNextBlockDPOSReward(t *testing.T) {
+	params := config.DefaultParams
+	a, err := NewArbitrators(&params, func() uint32 { return 0 })
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// The DPOS share of 502283105 sela is truncated from 175799086.75 as it is
+	// in coinbase reward assignment.
+	assert.Equal(t, common.Fixed64(502283105), params.RewardPerBlock)
+	assert.Equal(t, common.Fixed64(175799086), a.GetNextBlockDPOSReward())
+}
+
+func TestArbitrators_GetVotesInRound(t *testing.T) {
+	a, producers := mockRoundArbitrators(20)
+
+	publicKeys := make([][]byte, len(producers))
+	for i, p := range producers {
+		publicKeys[i] = p.OwnerPublicKey
+	}
+	a.State.ProcessBlock(mockBlock(7, mockMultiVoteTx(publicKeys)), nil)
+
+	if !assert.NoError(t, a.updateOwnerProgramHashes(0)) {
+		t.FailNow()
+	}
+
+	ownerVotes, total := a.GetVotesInRound()
+	assert.Equal(t, len(producers), len(ownerVotes))
+	var sum common.Fixed64
+	for _, votes := range ownerVotes {
+		sum += votes
+	}
+	assert.Equal(t, sum, total)
+	assert.Equal(t, total, a.GetTotalVotesInRound())
+
+	for i, p := range producers {
+		hash, _ := contract.PublicKeyToStandardProgramHash(p.OwnerPublicKey)
+		assert.Equal(t, common.Fixed64(100*(i+1)),
+			a.GetOwnerVotesInRound(*hash))
+	}
+}
+
+func TestArbitrators_ShuffleArbiters(t *testing.T) {
+	params := config.DefaultParams
+	params.CRCOnlyDPOSHeight = 1000
+	params.PublicDPOSHeight = 2000
+	params.ShuffleArbitersHeight = 1500
+	a, err := NewArbitrators(&params, func() uint32 { return 0 })
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	nextArbiters := make([][]byte, 0)
+	for _, v := range a.crcArbitratorsNodePublicKey {
+		nextArbiters = append(nextArbiters, v.info.NodePublicKey)
+	}
+	sorted := make([][]byte, len(nextArbiters))
+	copy(sorted, nextArbiters)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i], sorted[j]) < 0
+	})
+
+	// Arbiters are sorted before the shuffle height.
+	seed := common.Uint256{1, 2, 3}
+	a.countProducedBlock(1499, seed, nil)
+	a.nextArbitrators = append([][]byte{}, nextArbiters...)
+	if !assert.NoError(t, a.changeCurrentArbitrators(1499)) {
+		t.FailNow()
+	}
+	assert.Equal(t, sorted, a.currentArbitrators)
+
+	// Two nodes with the same seed get the same permutation.
+	shuffled1 := append([][]byte{}, sorted...)
+	shuffleArbiters(shuffled1, seed)
+	shuffled2 := append([][]byte{}, sorted...)
+	shuffleArbiters(shuffled2, seed)
+	assert.Equal(t, shuffled1, shuffled2)
+	assert.NotEqual(t, sorted, shuffled1)
+	assert.ElementsMatch(t, sorted, shuffled1)
+
+	shuffled3 := append([][]byte{}, sorted...)
+	shuffleArbiters(shuffled3, common.Uint256{3, 2, 1})
+	assert.NotEqual(t, shuffled1, shuffled3)
+
+	// Arbiters are shuffled by the hash of the change height block from the
+	// shuffle height.
+	a.countProducedBlock(1500, seed, nil)
+	a.nextArbitrators = append([][]byte{}, nextArbiters...)
+	if !assert.NoError(t, a.changeCurrentArbitrators(1500)) {
+		t.FailNow()
+	}
+	assert.Equal(t, shuffled1, a.currentArbitrators)
+
+	// The seed is restored on rollback.
+	a.countProducedBlock(1501, common.Uint256{3, 2, 1}, nil)
+	if !assert.NoError(t, a.RollbackTo(1500)) {
+		t.FailNow()
+	}
+	a.nextArbitrators = append([][]byte{}, nextArbiters...)
+	if !assert.NoError(t, a.changeCurrentArbitrators(1500)) {
+		t.FailNow()
+	}
+	assert.Equal(t, shuffled1, a.currentArbitrators)
+}
+
+func TestArbitrators_NetworkModeEvents(t *testing.T) {
+	params := config.DefaultParams
+	params.CRCOnlyDPOSHeight = 1000
+	params.PublicDPOSHeight = 2000
+	params.PreConnectOffset = 100
+	params.GeneralArbiters = 4
+	a, err := NewArbitrators(&params, func() uint32 { return 0 })
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	var changes []*events.Event
+	events.Subscribe(func(e *events.Event) {
+		switch e.Type {
+		case events.ETNetworkDegraded, events.ETNetworkRecovered:
+			changes = append(changes, e)
+		}
+	})
+
+	registerProducers := func(height uint32, count int) uint32 {
+		txs := make([]*types.Transaction, count)
+		for i := range txs {
+			_, pk, _ := crypto.GenerateKeyPair()
+			ownerPublicKey, _ := pk.EncodePoint(true)
+			info := &payload.ProducerInfo{
+				OwnerPublicKey: ownerPublicKey,
+				NodePublicKey:  ownerPublicKey,
+				NickName:       fmt.Sprintf("Producer-%d-%d", height, i),
+			}
+			txs[i] = mockRegisterProducerTx(info)
+		}
+		a.State.ProcessBlock(mockBlock(height, txs...), nil)
+		for i := 0; i < 6; i++ {
+			height++
+			a.State.ProcessBlock(mockBlock(height), nil)
+		}
+		return height + 1
+	}
+
+	// Only two producers, next arbiters fall back to CRC only.
+	height := registerProducers(1, 2)
+	a.IncreaseChainHeight(params.PublicDPOSHeight - params.PreConnectOffset - 1)
+	assert.Equal(t, NetworkUnderstaffed, a.GetNetworkMode())
+	if !assert.Equal(t, 1, len(changes)) {
+		t.FailNow()
+	}
+	assert.Equal(t, events.ETNetworkDegraded, changes[0].Type)
+	assert.Equal(t, &NetworkModeChange{
+		Mode:   NetworkUnderstaffed,
+		Height: params.PublicDPOSHeight,
+	}, changes[0].Data)
+
+	// Enough producers registered, next arbiters are fully staffed.
+	registerProducers(height, 2)
+	a.IncreaseChainHeight(params.PublicDPOSHeight - 1)
+	assert.Equal(t, NetworkNormal, a.GetNetworkMode())
+	if !assert.Equal(t, 2, len(changes)) {
+		t.FailNow()
+	}
+	assert.Equal(t, events.ETNetworkRecovered, changes[1].Type)
+	assert.Equal(t, &NetworkModeChange{
+		Mode:   NetworkNormal,
+		Height: params.PublicDPOSHeight,
+	}, changes[1].Data)
+
+	// Rollback restores the network mode changed after the height.
+	a.rollbackNetworkMode(params.PublicDPOSHeight - 2)
+	a.notifyNetworkModeChanges()
+	assert.Equal(t, NetworkUnderstaffed, a.GetNetworkMode())
+	if !assert.Equal(t, 3, len(changes)) {
+		t.FailNow()
+	}
+	assert.Equal(t, events.ETNetworkDegraded, changes[2].Type)
+	assert.Equal(t, &NetworkModeChange{
+		Mode:   NetworkUnderstaffed,
+		Height: params.PublicDPOSHeight - 1,
+	}, changes[2].Data)
+
+	a.rollbackNetworkMode(1)
+	assert.Equal(t, NetworkNormal, a.GetNetworkMode())
+}
+
+func TestArbitrators_GetCRCArbiterDetails(t *testing.T) {
+	params := config.DefaultParams
+	a, err := NewArbitrators(&params, func() uint32 { return 0 })
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	details := a.GetCRCArbiterDetails()
+	if !assert.Equal(t, len(params.CRCArbiters), len(details)) {
+		t.FailNow()
+	}
+	for i, d := range details {
+		if i > 0 {
+			assert.True(t, bytes.Compare(details[i-1].NodePublicKey,
+				d.NodePublicKey) < 0)
+		}
+
+		pk := common.BytesToHexString(d.NodePublicKey)
+		found := false
+		for _, v := range params.CRCArbiters {
+			if v.PublicKey == pk {
+				found = true
+				break
+			}
+		}
+		assert.True(t, found)
+		assert.Equal(t, d.NodePublicKey, d.OwnerPublicKey)
+
+		hash, err := contract.PublicKeyToStandardProgramHash(d.OwnerPublicKey)
+		assert.NoError(t, err)
+		assert.Equal(t, *hash, d.ProgramHash)
+		assert.True(t, a.IsCRCArbitratorProgramHash(&d.ProgramHash))
+	}
+}
+
+func TestArbitrators_GetNeedConnectArbiters(t *testing.T) {
+	params := config.DefaultParams
+	params.CRCOnlyDPOSHeight = 1000
+	params.PublicDPOSHeight = 2000
+	params.PreConnectOffset = 100
+	a, err := NewArbitrators(&params, func() uint32 { return 0 })
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	assert.Equal(t, 0, len(a.GetNeedConnectArbiters(850)))
+	assert.Equal(t, 0, len(a.GetNeedConnectArbiters(899)))
+	assert.NotEqual(t, 0, len(a.GetNeedConnectArbiters(900)))
+
+	// Connect earlier with extra pre-connect offset.
+	params.ExtraPreConnectOffset = 50
+	assert.Equal(t, 0, len(a.GetNeedConnectArbiters(849)))
+	assert.NotEqual(t, 0, len(a.GetNeedConnectArbiters(850)))
+
+	// Offset larger than H1 should not overflow.
+	params.ExtraPreConnectOffset = 1000
+	assert.NotEqual(t, 0, len(a.GetNeedConnectArbiters(0)))
+}
+
+func TestSortPeerAddrs(t *testing.T) {
+	params := config.DefaultParams
+	params.CRCOnlyDPOSHeight = 1000
+	params.PublicDPOSHeight = 2000
+	params.PreConnectOffset = 100
+	a, err := NewArbitrators(&params, func() uint32 { return 0 })
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	peers := a.GetNeedConnectArbiters(900)
+	var count int
+	for _, v := range peers {
+		if v != nil {
+			count++
+		}
+	}
+	addrs := SortPeerAddrs(peers)
+	if !assert.Equal(t, count, len(addrs)) {
+		t.FailNow()
+	}
+	for i := 1; i < len(addrs); i++ {
+		assert.True(t, bytes.Compare(addrs[i-1].PID[:], addrs[i].PID[:]) < 0)
+	}
+
+	// Successive calls on the same state have identical ordering.
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, addrs, SortPeerAddrs(a.GetNeedConnectArbiters(900)))
+	}
+
+	// Nil addresses are skipped.
+	peers["empty"] = nil
+	assert.Equal(t, addrs, SortPeerAddrs(peers))
+	assert.NotEqual(t, 0, count)
+}
+
+func TestArbitrators_GetNeedConnectArbitersRanked(t *testing.T) {
+	a, producers := mockRoundArbitrators(10)
+	a.chainParams.CRCOnlyDPOSHeight = 1000
+	a.chainParams.PublicDPOSHeight = 2000
+	height := uint32(2100)
+	a.bestHeight = func() uint32 { return height }
+	a.nextArbitrators = [][]byte{producers[9].NodePublicKey}
+
+	toPID := func(pk []byte) peer.PID {
+		pid := peer.PID{}
+		copy(pid[:], pk)
+		return pid
+	}
+
+	for dutyIndex := 0; dutyIndex < len(a.currentArbitrators); dutyIndex++ {
+		a.dutyIndex = dutyIndex
+		ranked := a.GetNeedConnectArbitersRanked()
+		if !assert.Equal(t, len(a.currentArbitrators)+1, len(ranked)) {
+			t.FailNow()
+		}
+
+		// The on-duty arbiter is dialed first, then the following ones.
+		assert.Equal(t, toPID(a.GetOnDutyArbitrator()), ranked[0])
+		assert.Equal(t, toPID(a.GetNextOnDutyArbitrator(1)), ranked[1])
+
+		// Next arbiters are dialed after current arbiters.
+		assert.Equal(t, toPID(producers[9].NodePublicKey),
+			ranked[len(ranked)-1])
+	}
+
+	// No peers before pre-connect height.
+	height = 0
+	assert.Nil(t, a.GetNeedConnectArbitersRanked())
+}
+
+func TestArbitrators_CRCArbiterSchedule(t *testing.T) {
+	params := config.DefaultParams
+	params.CRCOnlyDPOSHeight = 1000
+	params.PublicDPOSHeight = 2000
+	newCRCs := make([]config.CRCArbiter, 2)
+	for i := range newCRCs {
+		_, pk, _ := crypto.GenerateKeyPair()
+		pubKey, _ := pk.EncodePoint(true)
+		newCRCs[i] = config.CRCArbiter{
+			PublicKey:  common.BytesToHexString(pubKey),
+			NetAddress: fmt.Sprintf("127.0.0.1:%d", 20000+i),
+		}
+	}
+	params.CRCArbiterSchedule = map[uint32][]config.CRCArbiter{
+		1500: newCRCs,
+	}
+	a, err := NewArbitrators(&params, func() uint32 { return 0 })
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	for i := uint32(1490); i <= 1500; i++ {
+		a.State.ProcessBlock(mockBlock(i), nil)
+	}
+
+	crcKeys := func() []string {
+		keys := make([]string, 0)
+		for k := range a.GetCRCArbitrators() {
+			keys = append(keys, k)
+		}
+		return keys
+	}
+	originKeys := make([]string, 0)
+	for _, v := range params.CRCArbiters {
+		originKeys = append(originKeys, v.PublicKey)
+	}
+	newKeys := []string{newCRCs[0].PublicKey, newCRCs[1].PublicKey}
+
+	// Change before the scheduled height keeps the origin CRC arbiters.
+	if !assert.NoError(t, a.updateNextArbitrators(1400)) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, a.NormalChange(1400)) {
+		t.FailNow()
+	}
+	assert.ElementsMatch(t, originKeys, crcKeys())
+	assert.Equal(t, len(params.CRCArbiters), len(a.currentArbitrators))
+
+	// Change on the scheduled height replaces the CRC arbiters.
+	arbiters := a.GetArbitrators()
+	if !assert.NoError(t, a.NormalChange(1499)) {
+		t.FailNow()
+	}
+	assert.ElementsMatch(t, newKeys, crcKeys())
+	assert.Equal(t, 2, len(a.currentArbitrators))
+	assert.Equal(t, params.GeneralArbiters+2, a.arbitersCount)
+	for _, v := range newCRCs {
+		pk, _ := common.HexStringToBytes(v.PublicKey)
+		assert.True(t, a.IsCRCArbitrator(pk))
+		assert.True(t, a.IsArbitrator(pk))
+		assert.Contains(t, a.GetNeedConnectArbiters(1500), v.PublicKey)
+	}
+
+	// Following changes keep the new CRC arbiters.
+	if !assert.NoError(t, a.NormalChange(1500)) {
+		t.FailNow()
+	}
+	assert.ElementsMatch(t, newKeys, crcKeys())
+	assert.Equal(t, 1, len(a.crcSwaps))
+
+	// The cross chain arbiter of a height is chosen from the CRC arbiters in
+	// charge of that height.
+	sortedKeys := func(keys []string) [][]byte {
+		arbiters := make([][]byte, 0, len(keys))
+		for _, k := range keys {
+			arbiter, _ := common.HexStringToBytes(k)
+			arbiters = append(arbiters, arbiter)
+		}
+		sort.Slice(arbiters, func(i, j int) bool {
+			return bytes.Compare(arbiters[i], arbiters[j]) < 0
+		})
+		return arbiters
+	}
+	originArbiters, newArbiters := sortedKeys(originKeys), sortedKeys(newKeys)
+	for _, height := range []uint32{1000, 1498, 1499} {
+		assert.Equal(t,
+			originArbiters[(height-1000)%uint32(len(originArbiters))],
+			a.GetOnDutyCrossChainArbitratorAtHeight(height))
+	}
+	for _, height := range []uint32{1500, 1501, 1502} {
+		assert.Equal(t, newArbiters[(height-1000)%uint32(len(newArbiters))],
+			a.GetOnDutyCrossChainArbitratorAtHeight(height))
+	}
+
+	// Rollback before the swap height restores the origin CRC arbiters.
+	if !assert.NoError(t, a.RollbackTo(1498)) {
+		t.FailNow()
+	}
+	assert.ElementsMatch(t, originKeys, crcKeys())
+	assert.Equal(t, params.GeneralArbiters+len(params.CRCArbiters),
+		a.arbitersCount)
+	pk, _ := common.HexStringToBytes(newCRCs[0].PublicKey)
+	assert.False(t, a.IsCRCArbitrator(pk))
+	assert.False(t, a.IsArbitrator(pk))
+	assert.Equal(t, arbiters, a.GetArbitrators())
+}
+
+func TestArbitrators_IsOnDuty(t *testing.T) {
+	a, _ := mockRoundArbitrators(10)
+	a.chainParams.CRCOnlyDPOSHeight = 1000
+	a.chainParams.PublicDPOSHeight = 2000
+	a.bestHeight = func() uint32 { return 2100 }
+
+	for dutyIndex := range a.currentArbitrators {
+		a.dutyIndex = dutyIndex
+		onDuty := 0
+		for i, arbiter := range a.currentArbitrators {
+			if a.IsOnDuty(arbiter) {
+				onDuty++
+				assert.Equal(t, dutyIndex, i)
+			}
+		}
+		assert.Equal(t, 1, onDuty)
+	}
+
+	unknown := make([]byte, 33)
+	rand.Read(unknown)
+	assert.False(t, a.IsOnDuty(unknown))
+}
+
+func TestArbitrators_CandidateVotes(t *testing.T) {
+	a, producers := mockRoundArbitrators(6)
+	a.chainParams.CandidateArbiters = 2
+	height := a.chainParams.PublicDPOSHeight
+
+	mockVote := func(value common.Fixed64,
+		contents ...outputpayload.VoteContent) *types.Transaction {
+		return &types.Transaction{
+			Version: types.TxVersion09,
+			TxType:  types.TransferAsset,
+			Payload: &payload.TransferAsset{},
+			Outputs: []*types.Output{{
+				Value: value,
+				Type:  types.OTVote,
+				Payload: &outputpayload.VoteOutput{
+					Version:  0,
+					Contents: contents,
+				},
+			}},
+		}
+	}
+	delegate := func(pks ...[]byte) outputpayload.VoteContent {
+		return outputpayload.VoteContent{
+			VoteType: outputpayload.Delegate, Candidates: pks}
+	}
+	candidate := func(pks ...[]byte) outputpayload.VoteContent {
+		return outputpayload.VoteContent{
+			VoteType: outputpayload.Candidate, Candidates: pks}
+	}
+
+	// Delegate votes decide the order p0 > p1 > ... > p5.
+	txs := make([]*types.Transaction, len(producers))
+	for i, p := range producers {
+		txs[i] = mockVote(common.Fixed64(600-100*i), delegate(p.OwnerPublicKey))
+	}
+	a.State.ProcessBlock(mockBlock(7, txs...), nil)
+
+	selected := func() ([][]byte, [][]byte) {
+		arbiters, err := a.GetNormalArbitratorsDesc(height, 2,
+			a.State.getProducers())
+		assert.NoError(t, err)
+		candidates, err := a.GetCandidatesDesc(height, 2,
+			a.State.getProducers())
+		assert.NoError(t, err)
+		return arbiters, candidates
+	}
+	arbiters, candidates := selected()
+	assert.Equal(t, [][]byte{producers[0].NodePublicKey,
+		producers[1].NodePublicKey}, arbiters)
+	assert.Equal(t, [][]byte{producers[2].NodePublicKey,
+		producers[3].NodePublicKey}, candidates)
+
+	// Candidate votes promote producers to candidates without affecting
+	// arbiters selection.
+	candidateTx := mockVote(1000, delegate(producers[5].OwnerPublicKey),
+		candidate(producers[5].OwnerPublicKey, producers[0].OwnerPublicKey))
+	a.State.ProcessBlock(mockBlock(8, candidateTx,
+		mockVote(50, candidate(producers[4].OwnerPublicKey))), nil)
+	p5 := a.GetProducer(producers[5].OwnerPublicKey)
+	assert.Equal(t, common.Fixed64(1100), p5.Votes())
+	assert.Equal(t, common.Fixed64(1000), p5.CandidateVotes())
+	assert.Equal(t, common.Fixed64(1000),
+		a.GetProducer(producers[0].OwnerPublicKey).CandidateVotes())
+
+	arbiters, candidates = selected()
+	assert.Equal(t, [][]byte{producers[5].NodePublicKey,
+		producers[0].NodePublicKey}, arbiters)
+	assert.Equal(t, [][]byte{producers[4].NodePublicKey,
+		producers[1].NodePublicKey}, candidates)
+
+	// Cancel the candidate votes.
+	a.State.ProcessBlock(mockBlock(9, mockCancelVoteTx(candidateTx)), nil)
+	assert.Equal(t, common.Fixed64(100), p5.Votes())
+	assert.Equal(t, common.Fixed64(0), p5.CandidateVotes())
+	arbiters, candidates = selected()
+	assert.Equal(t, [][]byte{producers[0].NodePublicKey,
+		producers[1].NodePublicKey}, arbiters)
+	assert.Equal(t, [][]byte{producers[4].NodePublicKey,
+		producers[2].NodePublicKey}, candidates)
+
+	// Rollback the cancel and the votes.
+	assert.NoError(t, a.State.RollbackTo(8))
+	assert.Equal(t, common.Fixed64(1000), p5.CandidateVotes())
+	assert.NoError(t, a.State.RollbackTo(7))
+	assert.Equal(t, common.Fixed64(0), p5.CandidateVotes())
+	assert.Equal(t, common.Fixed64(100), p5.Votes())
+	arbiters, candidates = selected()
+	assert.Equal(t, [][]byte{producers[2].NodePublicKey,
+		producers[3].NodePublicKey}, candidates)
+}
+
+func TestArbitrators_SimulateArbiterSet(t *testing.T) {
+	a, producers := mockRoundArbitrators(6)
+	a.chainParams.GeneralArbiters = 2
+	a.chainParams.CandidateArbiters = 2
+	a.bestHeight = func() uint32 { return a.chainParams.PublicDPOSHeight }
+
+	// Votes decide the order p0 > p1 > ... > p5.
+	publicKeys := make([][]byte, len(producers))
+	for i, p := range producers {
+		publicKeys[len(producers)-1-i] = p.OwnerPublicKey
+	}
+	a.State.ProcessBlock(mockBlock(7, mockMultiVoteTx(publicKeys)), nil)
+
+	nodeKeys := func(ps ...*payload.ProducerInfo) [][]byte {
+		keys := make([][]byte, len(ps))
+		for i, p := range ps {
+			keys[i] = p.NodePublicKey
+		}
+		return keys
+	}
+	crcCount := len(a.crcArbitratorsNodePublicKey)
+	nextArbiters := a.nextArbitrators
+
+	arbiters, candidates := a.SimulateArbiterSet(nil)
+	assert.Equal(t, nodeKeys(producers[0], producers[1]), arbiters[crcCount:])
+	assert.Equal(t, nodeKeys(producers[2], producers[3]), candidates)
+
+	// Push p3 above the arbiters cut by owner public key, and p1 below by
+	// node public key.
+	arbiters, candidates = a.SimulateArbiterSet(map[string]common.Fixed64{
+		common.BytesToHexString(producers[3].OwnerPublicKey): 400,
+		common.BytesToHexString(producers[1].NodePublicKey):  -500,
+	})
+	assert.Equal(t, nodeKeys(producers[3], producers[0]), arbiters[crcCount:])
+	assert.Equal(t, nodeKeys(producers[2], producers[4]), candidates)
+
+	// The state is not changed.
+	assert.Equal(t, common.Fixed64(300),
+		a.GetProducer(producers[3].OwnerPublicKey).Votes())
+	assert.Equal(t, common.Fixed64(500),
+		a.GetProducer(producers[1].OwnerPublicKey).Votes())
+	assert.Equal(t, nextArbiters, a.nextArbitrators)
+
+	// Votes above MaxVotesPerProducer are not counted, p3 ties with p0 and
+	// p1 and they are ordered by node public key.
+	a.chainParams.MaxVotesPerProducer = 500
+	a.chainParams.VotesCapHeight = 0
+	arbiters, _ = a.SimulateArbiterSet(map[string]common.Fixed64{
+		common.BytesToHexString(producers[3].OwnerPublicKey): 400,
+	})
+	expected := nodeKeys(producers[0], producers[1], producers[3])
+	sort.Slice(expected, func(i, j int) bool {
+		return bytes.Compare(expected[i], expected[j]) < 0
+	})
+	assert.Equal(t, expected[:2], arbiters[crcCount:])
+}
+
+func TestArbitrators_GetProducerRankDelta(t *testing.T) {
+	a, producers := mockRoundArbitrators(4)
+	a.chainParams.GeneralArbiters = 2
+	a.chainParams.CandidateArbiters = 2
+	height := a.chainParams.PublicDPOSHeight
+
+	// Votes decide the order p0 > p1 > p2 > p3.
+	voteTx := mockMultiVoteTx([][]byte{producers[3].OwnerPublicKey,
+		producers[2].OwnerPublicKey, producers[1].OwnerPublicKey,
+		producers[0].OwnerPublicKey})
+	a.State.ProcessBlock(mockBlock(7, voteTx), nil)
+
+	// There is no delta without a previous ranking.
+	assert.NoError(t, a.updateNextArbitrators(height))
+	for _, p := range producers {
+		assert.Equal(t, 0, a.GetProducerRankDelta(p.NodePublicKey))
+	}
+
+	// Reorder to p2 > p1 > p0, cancel p3 and register p4 with no votes.
+	_, pk, _ := crypto.GenerateKeyPair()
+	ownerPublicKey, _ := pk.EncodePoint(true)
+	p4 := &payload.ProducerInfo{
+		OwnerPublicKey: ownerPublicKey,
+		NodePublicKey:  make([]byte, 33),
+		NickName:       "Producer-5",
+	}
+	rand.Read(p4.NodePublicKey)
+	a.State.ProcessBlock(mockBlock(8, mockCancelVoteTx(voteTx),
+		mockMultiVoteTx([][]byte{producers[0].OwnerPublicKey,
+			producers[1].OwnerPublicKey, producers[2].OwnerPublicKey}),
+		mockCancelProducerTx(producers[3].OwnerPublicKey),
+		mockRegisterProducerTx(p4)), nil)
+	for i := uint32(9); i <= 14; i++ {
+		a.State.ProcessBlock(mockBlock(i), nil)
+	}
+	if !assert.NotNil(t, a.GetProducer(p4.OwnerPublicKey)) ||
+		!assert.Equal(t, Activate, a.GetProducer(p4.OwnerPublicKey).State()) {
+		t.FailNow()
+	}
+
+	assert.NoError(t, a.updateNextArbitrators(height))
+	assert.Equal(t, -2, a.GetProducerRankDelta(producers[0].NodePublicKey))
+	assert.Equal(t, 0, a.GetProducerRankDelta(producers[1].NodePublicKey))
+	assert.Equal(t, 2, a.GetProducerRankDelta(producers[2].NodePublicKey))
+
+	// The leaving producer drops after the last ranked one, and the newly
+	// entering producer climbs from there.
+	assert.Equal(t, -1, a.GetProducerRankDelta(producers[3].NodePublicKey))
+	assert.Equal(t, 1, a.GetProducerRankDelta(p4.NodePublicKey))
+
+	assert.Equal(t, 0, a.GetProducerRankDelta(make([]byte, 33)))
+}
+
+func TestArbitrators_PauseInactivityWhenDegraded(t *testing.T) {
+	params := config.DefaultParams
+	params.PublicDPOSHeight = 7
+	params.MaxInactiveRounds = 10
+	params.GeneralArbiters = 2
+	params.InactivityPauseHeight = 8
+	a, _ := NewArbitrators(&params, func() uint32 { return 0 })
+
+	producers := make([]*payload.ProducerInfo, 2)
+	for i := range producers {
+		producers[i] = &payload.ProducerInfo{
+			OwnerPublicKey: make([]byte, 33),
+			NodePublicKey:  make([]byte, 33),
+			NickName:       fmt.Sprintf("Producer-%d", i+1),
+		}
+		rand.Read(producers[i].OwnerPublicKey)
+		rand.Read(producers[i].NodePublicKey)
+	}
+	a.State.ProcessBlock(mockBlock(1, mockRegisterProducerTx(producers[0]),
+		mockRegisterProducerTx(producers[1])), nil)
+	for i := uint32(2); i <= 6; i++ {
+		a.State.ProcessBlock(mockBlock(i), nil)
+	}
+	a.currentArbitrators = [][]byte{
+		producers[0].NodePublicKey,
+		producers[1].NodePublicKey,
+	}
+
+	// producers[0] misses signing from height 7, the counting is not paused
+	// before InactivityPauseHeight even if the arbiters are understaffed.
+	confirm := &payload.Confirm{
+		Votes: []payload.DPOSProposalVote{
+			{Signer: producers[1].NodePublicKey},
+		},
+	}
+	a.State.ProcessBlock(mockBlock(7), confirm)
+	producer := a.GetProducer(producers[0].OwnerPublicKey)
+	assert.Equal(t, uint32(7), producer.inactiveCountingHeight)
+
+	// Missed signings are not counted while the arbiters are understaffed.
+	for i := uint32(8); i <= 22; i++ {
+		a.State.ProcessBlock(mockBlock(i), confirm)
+	}
+	assert.Equal(t, Activate, producer.State())
+	assert.Equal(t, uint32(22), producer.inactiveCountingHeight)
+
+	// The counting resumes once the arbiters are fully staffed.
+	for _, v := range a.crcArbitratorsNodePublicKey {
+		a.currentArbitrators = append(a.currentArbitrators,
+			v.info.NodePublicKey)
+	}
+	for i := uint32(23); i <= 32; i++ {
+		a.State.ProcessBlock(mockBlock(i), confirm)
+	}
+	assert.Equal(t, Activate, producer.State())
+	a.State.ProcessBlock(mockBlock(33), confirm)
+	assert.Equal(t, Inactivate, producer.State())
+
+	// Rollback restores the producer and the counting.
+	assert.NoError(t, a.State.RollbackTo(30))
+	producer = a.GetProducer(producers[0].OwnerPublicKey)
+	assert.Equal(t, Activate, producer.State())
+	assert.Equal(t, uint32(22), producer.inactiveCountingHeight)
+}
+
+func TestArbitrators_SelectionError(t *testing.T) {
+	a, _ := mockRoundArbitrators(3)
+	height := a.chainParams.PublicDPOSHeight
+
+	_, err := a.GetNormalArbitratorsDesc(height, 10, a.State.getProducers())
+	e, ok := err.(*SelectionError)
+	if !assert.True(t, ok) {
+		t.FailNow()
+	}
+	assert.Equal(t, InsufficientProducers, e.Code)
+	assert.Equal(t, 3, e.Available)
+	assert.Equal(t, 6, e.Required)
+
+	// Enough producers for the minimum count of arbiters.
+	_, err = a.GetNormalArbitratorsDesc(height, 5, a.State.getProducers())
+	assert.NoError(t, err)
+
+	// The error is returned on updating next arbiters too.
+	a.chainParams.GeneralArbiters = 6
+	err = a.updateNextArbitrators(height)
+	e, ok = err.(*SelectionError)
+	if !assert.True(t, ok) {
+		t.FailNow()
+	}
+	assert.Equal(t, InsufficientProducers, e.Code)
+	assert.Equal(t, 3, e.Available)
+	assert.Equal(t, 4, e.Required)
+}
+
+func TestArbitrators_DPOSPeriods(t *testing.T) {
+	params := config.DefaultParams
+	params.CRCOnlyDPOSHeight = 1000
+	params.PublicDPOSHeight = 2000
+	a, err := NewArbitrators(&params, func() uint32 { return 0 })
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	for _, c := range []struct {
+		height     uint32
+		crcOnly    bool
+		publicDPOS bool
+	}{
+		{0, false, false},
+		{999, false, false},
+		{1000, true, false},
+		{1999, true, false},
+		{2000, false, true},
+		{2001, false, true},
+	} {
+		assert.Equal(t, c.crcOnly, a.IsCRCOnlyPeriod(c.height),
+			"height %d", c.height)
+		assert.Equal(t, c.publicDPOS, a.IsPublicDPOSPeriod(c.height),
+			"height %d", c.height)
+	}
+}
+
+func TestArbitrators_EstimateNextChangeHeight(t *testing.T) {
+	params := config.DefaultParams
+	params.CRCOnlyDPOSHeight = 1000
+	params.PublicDPOSHeight = 2000
+	params.PreConnectOffset = 100
+	var bestHeight uint32
+	a, err := NewArbitrators(&params, func() uint32 { return bestHeight })
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// Special change points before H2.
+	bestHeight = 500
+	assert.Equal(t, uint32(1000), a.EstimateNextChangeHeight())
+	bestHeight = 998
+	assert.Equal(t, uint32(1000), a.EstimateNextChangeHeight())
+	bestHeight = 1500
+	assert.Equal(t, uint32(2000), a.EstimateNextChangeHeight())
+
+	// Changes by duty index rotation after H2.
+	bestHeight = 1999
+	a.dutyIndex = 0
+	assert.Equal(t, uint32(2000+a.arbitersCount),
+		a.EstimateNextChangeHeight())
+
+	bestHeight = 2100
+	a.dutyIndex = 10
+	estimate := a.EstimateNextChangeHeight()
+	assert.Equal(t, uint32(2100+1+a.arbitersCount-10), estimate)
+
+	// Verify the estimate by simulating the duty index moving forward.
+	for height := bestHeight + 2; ; height++ {
+		changeType, _ := a.GetChangeTypeAt(height)
+		if changeType == NormalChange {
+			assert.Equal(t, estimate, height)
+			break
+		}
+		a.dutyIndex++
+	}
+
+	bestHeight = 2100
+	a.dutyIndex = a.arbitersCount - 1
+	assert.Equal(t, uint32(2102), a.EstimateNextChangeHeight())
+}
+
+func TestArbitrators_GetArbiterBlockCount(t *testing.T) {
+	params := config.DefaultParams
+	a, err := NewArbitrators(&params, func() uint32 { return 0 })
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	arbiters := make([][]byte, 3)
+	for i := range arbiters {
+		arbiters[i] = make([]byte, 33)
+		rand.Read(arbiters[i])
+	}
+	a.currentArbitrators = arbiters
+	outsider := make([]byte, 33)
+	rand.Read(outsider)
+
+	confirm := func(sponsor []byte) *payload.Confirm {
+		return &payload.Confirm{
+			Proposal: payload.DPOSProposal{Sponsor: sponsor},
+		}
+	}
+
+	// Rotating sponsors, blocks without confirm or sponsored by non arbiters
+	// are not counted.
+	for i := uint32(1); i <= 6; i++ {
+		assert.NoError(t, a.ProcessBlock(mockBlock(i),
+			confirm(arbiters[i%3])))
+	}
+	assert.NoError(t, a.ProcessBlock(mockBlock(7), confirm(outsider)))
+	assert.NoError(t, a.ProcessBlock(mockBlock(8), confirm(arbiters[0])))
+	assert.NoError(t, a.ProcessBlock(mockBlock(9), nil))
+	assert.Equal(t, uint32(3), a.GetArbiterBlockCount(arbiters[0]))
+	assert.Equal(t, uint32(2), a.GetArbiterBlockCount(arbiters[1]))
+	assert.Equal(t, uint32(2), a.GetArbiterBlockCount(arbiters[2]))
+	assert.Equal(t, uint32(0), a.GetArbiterBlockCount(outsider))
+
+	// Rollback decreases the counts.
+	assert.NoError(t, a.RollbackTo(4))
+	assert.Equal(t, uint32(1), a.GetArbiterBlockCount(arbiters[0]))
+	assert.Equal(t, uint32(2), a.GetArbiterBlockCount(arbiters[1]))
+	assert.Equal(t, uint32(1), a.GetArbiterBlockCount(arbiters[2]))
+
+	// The count is reset when the arbiter leaves the arbiters set.
+	a.currentArbitrators = [][]byte{arbiters[1], arbiters[2], outsider}
+	assert.NoError(t, a.ProcessBlock(mockBlock(5), confirm(outsider)))
+	assert.Equal(t, uint32(0), a.GetArbiterBlockCount(arbiters[0]))
+	assert.Equal(t, uint32(1), a.GetArbiterBlockCount(outsider))
+
+	// And restored on rollback.
+	assert.NoError(t, a.RollbackTo(4))
+	assert.Equal(t, uint32(1), a.GetArbiterBlockCount(arbiters[0]))
+	assert.Equal(t, uint32(0), a.GetArbiterBlockCount(outsider))
+}
+
+func TestArbitrators_GetHistoricalDutyIndex(t *testing.T) {
+	a, _ := mockRoundArbitrators(4)
+	a.chainParams.CRCOnlyDPOSHeight = 1
+	a.chainParams.PublicDPOSHeight = 1
+	a.chainParams.GeneralArbiters = 2
+	a.arbitersCount = len(a.currentArbitrators)
+	if !assert.NoError(t, a.ForceChange(6)) {
+		t.FailNow()
+	}
+
+	// Process blocks across round changes, recording the duty index in effect
+	// when each block is produced.
+	indexes := make(map[uint32]int)
+	var changed bool
+	best := 7 + uint32(2*a.arbitersCount)
+	for height := uint32(7); height <= best; height++ {
+		indexes[height] = a.GetDutyIndex()
+		if indexes[height] < indexes[height-1] {
+			changed = true
+		}
+		assert.NoError(t, a.ProcessBlock(mockBlock(height), nil))
+	}
+	assert.True(t, changed)
+	for height, index := range indexes {
+		historical, err := a.GetHistoricalDutyIndex(height)
+		assert.NoError(t, err)
+		assert.Equal(t, index, historical, "height %d", height)
+	}
+
+	// Heights out of history.
+	_, err := a.GetHistoricalDutyIndex(6)
+	assert.Error(t, err)
+	_, err = a.GetHistoricalDutyIndex(best + 1)
+	assert.Error(t, err)
+
+	// Rollback removes the history.
+	assert.NoError(t, a.RollbackTo(best-3))
+	_, err = a.GetHistoricalDutyIndex(best - 2)
+	assert.Error(t, err)
+	index, err := a.GetHistoricalDutyIndex(best - 3)
+	assert.NoError(t, err)
+	assert.Equal(t, indexes[best-3], index)
+}
+
+func TestArbitrators_ValidateIllegalBlockEvidence(t *testing.T) {
+	a, producers := mockRoundArbitrators(4)
+	a.chainParams.CRCOnlyDPOSHeight = 1
+	a.chainParams.PublicDPOSHeight = 1
+	a.chainParams.GeneralArbiters = 2
+	a.arbitersCount = len(a.currentArbitrators)
+	if !assert.NoError(t, a.ForceChange(6)) {
+		t.FailNow()
+	}
+	arbiters := a.GetArbitrators()
+	for height := uint32(7); height <= 10; height++ {
+		assert.NoError(t, a.ProcessBlock(mockBlock(height), nil))
+	}
+
+	// Find a producer being an arbiter and one being not.
+	var arbiter, nonArbiter *payload.ProducerInfo
+	for _, p := range producers {
+		isArbiter := false
+		for _, key := range arbiters {
+			if bytes.Equal(key, p.NodePublicKey) {
+				isArbiter = true
+			}
+		}
+		if isArbiter {
+			arbiter = p
+		} else {
+			nonArbiter = p
+		}
+	}
+	if !assert.NotNil(t, arbiter) || !assert.NotNil(t, nonArbiter) {
+		t.FailNow()
+	}
+
+	evidence := func(height uint32, signer []byte) *payload.DPOSIllegalBlocks {
+		p := mockIllegalBlockTx(signer).Payload.(*payload.DPOSIllegalBlocks)
+		p.BlockHeight = height
+		return p
+	}
+
+	// Signers being arbiters by node or owner public key pass.
+	assert.NoError(t, a.ValidateIllegalBlockEvidence(
+		evidence(8, arbiter.NodePublicKey)))
+	assert.NoError(t, a.ValidateIllegalBlockEvidence(
+		evidence(8, arbiter.OwnerPublicKey)))
+
+	// Signers not being arbiters fail.
+	assert.Error(t, a.ValidateIllegalBlockEvidence(
+		evidence(8, nonArbiter.NodePublicKey)))
+	assert.Error(t, a.ValidateIllegalBlockEvidence(
+		evidence(8, make([]byte, 33))))
+
+	// Heights out of history fail.
+	assert.Error(t, a.ValidateIllegalBlockEvidence(
+		evidence(11, arbiter.NodePublicKey)))
+}
+
+func TestArbitrators_GetProducerUptime(t *testing.T) {
+	params := config.DefaultParams
+	params.CRCOnlyDPOSHeight = 1
+	params.PublicDPOSHeight = 1
+	params.PreConnectOffset = 0
+	a, err := NewArbitrators(&params, func() uint32 { return 0 })
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// Keep arbiters unchanged while processing blocks.
+	a.arbitersCount = 100
+	arbiters := make([][]byte, 4)
+	for i := range arbiters {
+		arbiters[i] = make([]byte, 33)
+		rand.Read(arbiters[i])
+	}
+	a.currentArbitrators = arbiters
+
+	// The first arbiter misses its turns on height 5 and 13, and the next
+	// arbiter sponsors the block instead.
+	for i := uint32(1); i <= 40; i++ {
+		sponsor := a.GetNextOnDutyArbitratorV(i, 0)
+		if i == 5 || i == 13 {
+			assert.Equal(t, arbiters[0], sponsor)
+			sponsor = a.GetNextOnDutyArbitratorV(i, 1)
+		}
+		assert.NoError(t, a.ProcessBlock(mockBlock(i), &payload.Confirm{
+			Proposal: payload.DPOSProposal{Sponsor: sponsor},
+		}))
+	}
+
+	// Turns of the first arbiter are on height 1, 5, 9 ... 37.
+	assert.Equal(t, float64(8)/10, a.GetProducerUptime(arbiters[0], 40))
+	assert.Equal(t, float64(8)/10, a.GetProducerUptime(arbiters[0], 1000))
+	assert.Equal(t, float64(6)/7, a.GetProducerUptime(arbiters[0], 30))
+	assert.Equal(t, float64(1), a.GetProducerUptime(arbiters[0], 20))
+	assert.Equal(t, float64(1), a.GetProducerUptime(arbiters[1], 40))
+
+	// No turn in the window.
+	assert.Equal(t, float64(0), a.GetProducerUptime(arbiters[0], 3))
+	outsider := make([]byte, 33)
+	rand.Read(outsider)
+	assert.Equal(t, float64(0), a.GetProducerUptime(outsider, 40))
+
+	// Rollback removes the turns above the height.
+	assert.NoError(t, a.RollbackTo(33))
+	assert.Equal(t, float64(7)/9, a.GetProducerUptime(arbiters[0], 40))
+}
+
+func TestMajorityAndMinorityCount(t *testing.T) {
+	cases := []struct {
+		count    int
+		majority int
+		minority int
+	}{
+		{0, 0, 0},
+		{1, 0, 1},
+		{2, 1, 1},
+		{3, 2, 1},
+		{4, 2, 2},
+		{5, 3, 2},
+		{12, 8, 4},
+		{36, 24, 12},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.majority, MajorityCount(c.count),
+			"majority of %d", c.count)
+		assert.Equal(t, c.minority, MinorityCount(c.count),
+			"minority of %d", c.count)
+	}
+
+	// Instance methods delegate to the helpers with current arbiters count.
+	a, err := NewArbitrators(&config.DefaultParams, func() uint32 { return 0 })
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	a.currentArbitrators = make([][]byte, 5)
+	assert.Equal(t, 3, a.GetArbitersMajorityCount())
+	assert.False(t, a.HasArbitersMajorityCount(3))
+	assert.True(t, a.HasArbitersMajorityCount(4))
+	assert.False(t, a.HasArbitersMinorityCount(1))
+	assert.True(t, a.HasArbitersMinorityCount(2))
+}
+
+func TestArbitrators_ValidateConfirm(t *testing.T) {
+	params := config.DefaultParams
+	params.CRCOnlyDPOSHeight = 100
+	params.PublicDPOSHeight = 150
+	a, err := NewArbitrators(&params, func() uint32 { return 199 })
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	arbiters := make([][]byte, 5)
+	for i := range arbiters {
+		arbiters[i] = make([]byte, 33)
+		rand.Read(arbiters[i])
+	}
+	a.currentArbitrators = arbiters
+	a.dutyIndex = 2
+
+	block := mockBlock(200)
+	confirm := func(sponsor []byte, viewOffset uint32,
+		signers ...[]byte) *payload.Confirm {
+		c := &payload.Confirm{
+			Proposal: payload.DPOSProposal{
+				Sponsor:    sponsor,
+				BlockHash:  block.Hash(),
+				ViewOffset: viewOffset,
+			},
+		}
+		for _, signer := range signers {
+			c.Votes = append(c.Votes, payload.DPOSProposalVote{
+				ProposalHash: c.Proposal.Hash(),
+				Signer:       signer,
+				Accept:       true,
+			})
+		}
+		return c
+	}
+
+	// Correct confirm.
+	assert.NoError(t, a.ValidateConfirm(block, confirm(arbiters[2], 0,
+		arbiters[0], arbiters[1], arbiters[2], arbiters[3])))
+
+	// Sponsor of the proposal on another view.
+	assert.NoError(t, a.ValidateConfirm(block, confirm(arbiters[3], 1,
+		arbiters[0], arbiters[1], arbiters[2], arbiters[3])))
+
+	// Wrong sponsor.
+	assert.Error(t, a.ValidateConfirm(block, confirm(arbiters[3], 0,
+		arbiters[0], arbiters[1], arbiters[2], arbiters[3])))
+
+	// Under voted.
+	assert.Error(t, a.ValidateConfirm(block, confirm(arbiters[2], 0,
+		arbiters[0], arbiters[1], arbiters[2])))
+
+	// Duplicated signers and signers not arbiters are not counted.
+	outsider := make([]byte, 33)
+	rand.Read(outsider)
+	assert.Error(t, a.ValidateConfirm(block, confirm(arbiters[2], 0,
+		arbiters[0], arbiters[1], arbiters[2], arbiters[2])))
+	assert.Error(t, a.ValidateConfirm(block, confirm(arbiters[2], 0,
+		arbiters[0], arbiters[1], arbiters[2], outsider)))
+
+	// Rejected votes are not counted.
+	c := confirm(arbiters[2], 0, arbiters[0], arbiters[1], arbiters[2],
+		arbiters[3])
+	c.Votes[3].Accept = false
+	assert.Error(t, a.ValidateConfirm(block, c))
+
+	// Confirm of another block.
+	assert.Error(t, a.ValidateConfirm(mockBlock(201), confirm(arbiters[2], 0,
+		arbiters[0], arbiters[1], arbiters[2], arbiters[3])))
+	assert.Error(t, a.ValidateConfirm(block, nil))
+}
+
+func TestArbitrators_GetNonSigners(t *testing.T) {
+	a, _ := mockRoundArbitrators(0)
+	arbiters := make([][]byte, 5)
+	for i := range arbiters {
+		arbiters[i] = make([]byte, 33)
+		rand.Read(arbiters[i])
+	}
+	a.currentArbitrators = arbiters
+
+	confirm := &payload.Confirm{}
+	for _, signer := range [][]byte{arbiters[0], arbiters[2], arbiters[4]} {
+		confirm.Votes = append(confirm.Votes, payload.DPOSProposalVote{
+			Signer: signer,
+			Accept: true,
+		})
+	}
+	assert.Equal(t, [][]byte{arbiters[1], arbiters[3]},
+		a.GetNonSigners(confirm))
+
+	// All arbiters signed.
+	confirm.Votes = append(confirm.Votes,
+		payload.DPOSProposalVote{Signer: arbiters[1], Accept: true},
+		payload.DPOSProposalVote{Signer: arbiters[3], Accept: true})
+	assert.Equal(t, 0, len(a.GetNonSigners(confirm)))
+}
+
+func TestArbitrators_RewardsRollback(t *testing.T) {
+	params := config.DefaultParams
+	params.CRCOnlyDPOSHeight = 1
+	params.PublicDPOSHeight = 1
+	params.PreConnectOffset = 0
+	var bestHeight uint32
+	a, err := NewArbitrators(&params, func() uint32 { return bestHeight })
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	// Keep arbiters unchanged while processing blocks.
+	a.arbitersCount = 100
+
+	owner := common.Uint168{1}
+	blocks := make([]*types.Block, 20)
+	for i := range blocks {
+		blocks[i] = &types.Block{
+			Header: types.Header{Height: uint32(i + 1)},
+			Transactions: []*types.Transaction{{
+				TxType: types.CoinBase,
+				Outputs: []*types.Output{
+					{Value: 0}, {Value: 0},
+					{ProgramHash: owner, Value: common.Fixed64(i + 1)},
+				},
+			}},
+		}
+	}
+	processBlocks := func(blocks []*types.Block) {
+		for _, block := range blocks {
+			assert.NoError(t, a.ProcessBlock(block, nil))
+			bestHeight = block.Height
+		}
+	}
+
+	// Process blocks past the public DPOS height.
+	processBlocks(blocks)
+	reward, err := a.GetAccumulatedReward(owner, 10, 20)
+	assert.NoError(t, err)
+	assert.Equal(t, common.Fixed64(165), reward)
+	lastChange := a.GetLastChange()
+
+	// Rollback before some rewarded heights.
+	assert.NoError(t, a.RollbackTo(15))
+	bestHeight = 15
+	reward, err = a.GetAccumulatedReward(owner, 10, 15)
+	assert.NoError(t, err)
+	assert.Equal(t, common.Fixed64(75), reward)
+	_, err = a.GetAccumulatedReward(owner, 10, 16)
+	assert.Error(t, err)
+
+	// Reprocess the same blocks, rewards are not double counted.
+	processBlocks(blocks[15:])
+	reward, err = a.GetAccumulatedReward(owner, 10, 20)
+	assert.NoError(t, err)
+	assert.Equal(t, common.Fixed64(165), reward)
+	assert.Equal(t, lastChange, a.GetLastChange())
+}
+
+func TestArbitrators_GetOnDutyCrossChainArbitratorAtHeight(t *testing.T) {
+	params := config.DefaultParams
+	params.CRCOnlyDPOSHeight = 100
+	var bestHeight uint32 = 120
+	a, err := NewArbitrators(&params, func() uint32 { return bestHeight })
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	originArbiters := make([][]byte, 0, len(params.OriginArbiters))
+	for _, v := range params.OriginArbiters {
+		arbiter, _ := common.HexStringToBytes(v)
+		originArbiters = append(originArbiters, arbiter)
+	}
+	crcArbiters := make([][]byte, 0, len(params.CRCArbiters))
+	for _, v := range params.CRCArbiters {
+		arbiter, _ := common.HexStringToBytes(v.PublicKey)
+		crcArbiters = append(crcArbiters, arbiter)
+	}
+	sort.Slice(crcArbiters, func(i, j int) bool {
+		return bytes.Compare(crcArbiters[i], crcArbiters[j]) < 0
+	})
+
+	// Before H1 the on-duty arbiter of origin arbiters is used.
+	for _, height := range []uint32{1, 5, 6, 98, 99} {
+		assert.Equal(t, originArbiters[(height-1)%uint32(len(originArbiters))],
+			a.GetOnDutyCrossChainArbitratorAtHeight(height))
+	}
+
+	// Since H1 the sorted CRC arbiters take turns by height.
+	count := uint32(len(crcArbiters))
+	for _, height := range []uint32{100, 101, 100 + count - 1, 100 + count,
+		100 + count*3 + 5} {
+		assert.Equal(t, crcArbiters[(height-100)%count],
+			a.GetOnDutyCrossChainArbitratorAtHeight(height))
+	}
+	assert.Equal(t, crcArbiters[0], a.GetOnDutyCrossChainArbitratorAtHeight(100))
+	assert.Equal(t, crcArbiters[0],
+		a.GetOnDutyCrossChainArbitratorAtHeight(100+count))
+
+	// The current one is the value of the next block.
+	assert.Equal(t, a.GetOnDutyCrossChainArbitratorAtHeight(121),
+		a.GetOnDutyCrossChainArbitrator())
+}
+
+func TestArbitrators_BeginSnapshot(t *testing.T) {
+	params := config.DefaultParams
+	a, err := NewArbitrators(&params, func() uint32 { return 0 })
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, a.ProcessBlock(mockBlock(1), nil))
+
+	release := a.State.BeginSnapshot()
+	done := make(chan error)
+	go func() {
+		done <- a.ProcessBlock(mockBlock(2), nil)
+	}()
+
+	// Processing blocks is blocked while the snapshot is in progress.
+	select {
+	case <-done:
+		t.Fatal("block processed during snapshot")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Reads are not blocked, neither the arbitrators.
+	assert.Equal(t, 0, len(a.GetProducers()))
+	assert.NotEqual(t, 0, a.GetArbitersCount())
+
+	release()
+	release()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("block processing not resumed after release")
+	}
+
+	// Snapshot can begin again after released.
+	release = a.State.BeginSnapshot()
+	release()
+	assert.NoError(t, a.ProcessBlock(mockBlock(3), nil))
+}
+
+func TestArbitrators_GetRecentPromotions(t *testing.T) {
+	params := config.DefaultParams
+	params.CRCOnlyDPOSHeight = 1000
+	params.PublicDPOSHeight = 2000
+	params.GeneralArbiters = 4
+	a, err := NewArbitrators(&params, func() uint32 { return 0 })
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	registerProducers := func(height uint32, count int) [][]byte {
+		keys := make([][]byte, count)
+		txs := make([]*types.Transaction, count)
+		for i := range txs {
+			_, pk, _ := crypto.GenerateKeyPair()
+			keys[i], _ = pk.EncodePoint(true)
+			txs[i] = mockRegisterProducerTx(&payload.ProducerInfo{
+				OwnerPublicKey: keys[i],
+				NodePublicKey:  keys[i],
+				NickName:       fmt.Sprintf("Producer-%d-%d", height, i),
+			})
+		}
+		a.State.ProcessBlock(mockBlock(height, txs...), nil)
+		for i := uint32(1); i <= 6; i++ {
+			a.State.ProcessBlock(mockBlock(height+i), nil)
+		}
+		return keys
+	}
+
+	// All registered producers are promoted to arbiters.
+	producers := registerProducers(1, 4)
+	if !assert.NoError(t, a.ForceChange(params.PublicDPOSHeight)) {
+		t.FailNow()
+	}
+	promotions := a.GetRecentPromotions(10)
+	if !assert.Equal(t, 4, len(promotions)) {
+		t.FailNow()
+	}
+	promoted := make([][]byte, 0, len(promotions))
+	for _, p := range promotions {
+		assert.Equal(t, params.PublicDPOSHeight, p.Height)
+		assert.Equal(t, Promoted, p.Type)
+		promoted = append(promoted, p.NodePublicKey)
+	}
+	assert.ElementsMatch(t, producers, promoted)
+	assert.Equal(t, promotions[2:], a.GetRecentPromotions(2))
+
+	// An inactive arbiter is dropped and the new producer is promoted.
+	a.State.ProcessBlock(mockBlock(8, &types.Transaction{
+		TxType: types.InactiveArbitrators,
+		Payload: &payload.InactiveArbitrators{
+			Arbitrators: [][]byte{producers[0]},
+		},
+	}), nil)
+	candidate := registerProducers(9, 1)[0]
+	if !assert.NoError(t, a.ForceChange(params.PublicDPOSHeight+1)) {
+		t.FailNow()
+	}
+	promotions = a.GetRecentPromotions(2)
+	assert.Equal(t, []PromotionRecord{
+		{
+			Height:        params.PublicDPOSHeight + 1,
+			NodePublicKey: candidate,
+			Type:          Promoted,
+		},
+		{
+			Height:        params.PublicDPOSHeight + 1,
+			NodePublicKey: producers[0],
+			Type:          Dropped,
+		},
+	}, promotions)
+	assert.Equal(t, 6, len(a.GetRecentPromotions(10)))
+}
+
+func TestArbitrators_ProducerComparator(t *testing.T) {
+	params := config.DefaultParams
+	params.PublicDPOSHeight = 2000
+	params.GeneralArbiters = 3
+	byDeposit := func(x, y *Producer) bool {
+		return x.DepositAmount() > y.DepositAmount()
+	}
+	a, err := NewArbitrators(&params, func() uint32 { return 0 },
+		WithProducerComparator(byDeposit))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	producers := make([]*payload.ProducerInfo, 5)
+	txs := make([]*types.Transaction, len(producers))
+	for i := range producers {
+		_, pk, _ := crypto.GenerateKeyPair()
+		publicKey, _ := pk.EncodePoint(true)
+		producers[i] = &payload.ProducerInfo{
+			OwnerPublicKey: publicKey,
+			NodePublicKey:  publicKey,
+			NickName:       fmt.Sprintf("Producer-%d", i+1),
+		}
+		txs[i] = mockRegisterProducerTx(producers[i])
+	}
+	a.State.ProcessBlock(mockBlock(1, txs...), nil)
+	for i := uint32(2); i <= 6; i++ {
+		a.State.ProcessBlock(mockBlock(i), nil)
+	}
+
+	// Deposit grows with the index, so the last producers are selected.
+	for i, p := range producers {
+		a.getProducer(p.OwnerPublicKey).depositAmount =
+			common.Fixed64((i + 1) * 100)
+	}
+	arbiters, err := a.GetNormalArbitratorsDesc(params.PublicDPOSHeight, 3,
+		a.State.getProducers())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, [][]byte{
+		producers[4].NodePublicKey,
+		producers[3].NodePublicKey,
+		producers[2].NodePublicKey,
+	}, arbiters)
+
+	// Producers with the same deposit are ordered by node public key.
+	for _, p := range producers {
+		a.getProducer(p.OwnerPublicKey).depositAmount = 100
+	}
+	keys := make([][]byte, len(producers))
+	for i, p := range producers {
+		keys[i] = p.NodePublicKey
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i], keys[j]) < 0
+	})
+	arbiters, err = a.GetNormalArbitratorsDesc(params.PublicDPOSHeight, 3,
+		a.State.getProducers())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, keys[:3], arbiters)
+}
+
+func TestArbitrators_GetUpcomingArbiterChanges(t *testing.T) {
+	params := config.DefaultParams
+	a, err := NewArbitrators(&params, func() uint32 { return 0 })
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	keys := make([][]byte, 5)
+	for i := range keys {
+		keys[i] = make([]byte, 33)
+		rand.Read(keys[i])
+	}
+	a.currentArbitrators = [][]byte{keys[0], keys[1], keys[2]}
+	a.nextArbitrators = [][]byte{keys[1], keys[3], keys[2], keys[4]}
+
+	joining, leaving := a.GetUpcomingArbiterChanges()
+	assert.Equal(t, [][]byte{keys[3], keys[4]}, joining)
+	assert.Equal(t, [][]byte{keys[0]}, leaving)
+
+	// No changes if next arbiters are the same as current.
+	a.nextArbitrators = [][]byte{keys[2], keys[1], keys[0]}
+	joining, leaving = a.GetUpcomingArbiterChanges()
+	assert.Empty(t, joining)
+	assert.Empty(t, leaving)
+}
+
+func TestArbitrators_ReloadOriginArbiters(t *testing.T) {
+	params := config.DefaultParams
+	a, err := NewArbitrators(&params, func() uint32 { return 0 })
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	arbiters := make([]string, 3)
+	publicKeys := make([][]byte, len(arbiters))
+	for i := range arbiters {
+		_, pk, _ := crypto.GenerateKeyPair()
+		publicKeys[i], _ = pk.EncodePoint(true)
+		arbiters[i] = common.BytesToHexString(publicKeys[i])
+	}
+
+	// Invalid arbiters are rejected and origin arbiters keep unchanged.
+	origin := a.GetArbitrators()
+	assert.Error(t, a.ReloadOriginArbiters([]string{"xyz"}))
+	assert.Equal(t, origin, a.GetArbitrators())
+
+	// Arbiters are reloaded before any block processed.
+	if !assert.NoError(t, a.ReloadOriginArbiters(arbiters)) {
+		t.FailNow()
+	}
+	assert.Equal(t, publicKeys, a.GetArbitrators())
+	assert.Equal(t, publicKeys, a.GetNextArbitrators())
+	hashes := a.GetCurrentOwnerProgramHashes()
+	if !assert.Equal(t, len(publicKeys), len(hashes)) {
+		t.FailNow()
+	}
+	for i, pk := range publicKeys {
+		hash, _ := contract.PublicKeyToStandardProgramHash(pk)
+		assert.Equal(t, hash, hashes[i])
+	}
+
+	// The old version on-duty arbiters take turns among reloaded arbiters.
+	for _, height := range []uint32{1, 2, 3, 4} {
+		assert.Equal(t, publicKeys[(height-1)%3],
+			a.GetOnDutyCrossChainArbitratorAtHeight(height))
+		assert.Equal(t, publicKeys[(height-1)%3],
+			a.GetNextOnDutyArbitratorV(height, 0))
+	}
+	arbitersDesc, err := a.getNormalArbitratorsDescV0()
+	assert.NoError(t, err)
+	assert.Equal(t, publicKeys, arbitersDesc)
+
+	// Reloading after blocks processed is rejected.
+	a.ProcessBlock(mockBlock(1), nil)
+	assert.Error(t, a.ReloadOriginArbiters(params.OriginArbiters))
+	assert.Equal(t, publicKeys, a.GetArbitrators())
+
+	// Reloading is rejected once the chain has blocks, even if none of them
+	// has been processed by the state yet.
+	a, err = NewArbitrators(&params, func() uint32 { return 10 })
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Error(t, a.ReloadOriginArbiters(arbiters))
+}
+
+func TestArbitrators_MaxVotesPerProducer(t *testing.T) {
+	a, producers := mockRoundArbitrators(20)
+	a.chainParams.MaxVotesPerProducer = 500
+	a.chainParams.VotesCapHeight = 0
+
+	publicKeys := make([][]byte, len(producers))
+	for i, p := range producers {
+		publicKeys[i] = p.OwnerPublicKey
+	}
+	a.State.ProcessBlock(mockBlock(7, mockMultiVoteTx(publicKeys)), nil)
+	if !assert.NoError(t, a.updateOwnerProgramHashes(0)) {
+		t.FailNow()
+	}
+
+	var total common.Fixed64
+	for i, p := range producers {
+		votes := common.Fixed64(100 * (i + 1))
+		if votes > 500 {
+			votes = 500
+		}
+		total += votes
+
+		// Raw votes of the producer are not capped.
+		assert.Equal(t, common.Fixed64(100*(i+1)),
+			a.GetProducer(p.OwnerPublicKey).Votes())
+		hash, _ := contract.PublicKeyToStandardProgramHash(p.OwnerPublicKey)
+		assert.Equal(t, votes, a.GetOwnerVotesInRound(*hash))
+	}
+	assert.Equal(t, total, a.GetTotalVotesInRound())
+
+	// Producers above the cap share the same reward.
+	reward := common.Fixed64(1000000)
+	rewardOf := func(p *payload.ProducerInfo) common.Fixed64 {
+		hash, _ := contract.PublicKeyToStandardProgramHash(p.OwnerPublicKey)
+		return common.Fixed64(float64(reward) *
+			float64(a.GetOwnerVotes(hash)) / float64(a.GetTotalVotesInRound()))
+	}
+	assert.Equal(t, rewardOf(producers[4]), rewardOf(producers[19]))
+	assert.True(t, rewardOf(producers[3]) < rewardOf(producers[4]))
+
+	// Votes are not capped before VotesCapHeight.
+	a.chainParams.VotesCapHeight = 100
+	a.totalVotesInRound = 0
+	if !assert.NoError(t, a.updateOwnerProgramHashes(99)) {
+		t.FailNow()
+	}
+	hash, _ := contract.PublicKeyToStandardProgramHash(
+		producers[19].OwnerPublicKey)
+	assert.Equal(t, common.Fixed64(2000), a.GetOwnerVotesInRound(*hash))
+	a.totalVotesInRound = 0
+	if !assert.NoError(t, a.updateOwnerProgramHashes(100)) {
+		t.FailNow()
+	}
+	assert.Equal(t, common.Fixed64(500), a.GetOwnerVotesInRound(*hash))
+	assert.Equal(t, total, a.GetTotalVotesInRound())
+}
+
+func TestArbitrators_DumpState(t *testing.T) {
+	a, producers := mockRoundArbitrators(10)
+
+	publicKeys := make([][]byte, len(producers))
+	for i, p := range producers {
+		publicKeys[i] = p.OwnerPublicKey
+	}
+	pending := &payload.ProducerInfo{
+		OwnerPublicKey: make([]byte, 33),
+		NodePublicKey:  make([]byte, 33),
+		NickName:       "Producer-pending",
+	}
+	rand.Read(pending.OwnerPublicKey)
+	rand.Read(pending.NodePublicKey)
+	a.State.ProcessBlock(mockBlock(7, mockMultiVoteTx(publicKeys),
+		mockCancelProducerTx(producers[0].OwnerPublicKey),
+		mockIllegalBlockTx(producers[1].OwnerPublicKey),
+		mockRegisterProducerTx(pending)), nil)
+	if !assert.NoError(t, a.updateOwnerProgramHashes(0)) {
+		t.FailNow()
+	}
+
+	var buf bytes.Buffer
+	if !assert.NoError(t, a.DumpState(&buf)) {
+		t.FailNow()
+	}
+	var dump arbitratorsDump
+	if !assert.NoError(t, json.Unmarshal(buf.Bytes(), &dump)) {
+		t.FailNow()
+	}
+	assert.Equal(t, uint32(7), dump.State.Height)
+	assert.Equal(t, 1, len(dump.State.Producers[Pending.String()]))
+	assert.Equal(t, 8, len(dump.State.Producers[Activate.String()]))
+	assert.Equal(t, 1, len(dump.State.Producers[Canceled.String()]))
+	assert.Equal(t, 1, len(dump.State.Producers[FoundBad.String()]))
+	assert.Equal(t, pending.NickName,
+		dump.State.Producers[Pending.String()][0].NickName)
+	assert.Equal(t, len(a.currentArbitrators), len(dump.CurrentArbiters))
+	assert.Equal(t, len(a.currentCandidates), len(dump.CurrentCandidates))
+	assert.Equal(t, a.totalVotesInRound, dump.TotalVotes)
+	assert.Equal(t, len(a.ownerVotesInRound), len(dump.OwnerVotes))
+}
+
+func TestArbitrators_RevertLastBlock(t *testing.T) {
+	a, producers := mockRoundArbitrators(4)
+
+	dump := func() string {
+		var buf bytes.Buffer
+		assert.NoError(t, a.DumpState(&buf))
+		return buf.String()
+	}
+	processBlock := func(block *types.Block) {
+		assert.NoError(t, a.ProcessBlock(block, nil))
+	}
+
+	state6 := dump()
+	voteTx := mockVoteTx([][]byte{producers[0].OwnerPublicKey})
+	processBlock(mockBlock(7, voteTx))
+	state7 := dump()
+	processBlock(mockBlock(8, mockCancelVoteTx(voteTx),
+		mockCancelProducerTx(producers[1].OwnerPublicKey)))
+	assert.Equal(t, Canceled,
+		a.GetProducer(producers[1].OwnerPublicKey).State())
+
+	// Revert the last block.
+	assert.NoError(t, a.RevertLastBlock())
+	assert.Equal(t, state7, dump())
+	assert.Equal(t, Activate,
+		a.GetProducer(producers[1].OwnerPublicKey).State())
+	assert.Equal(t, common.Fixed64(100),
+		a.GetProducer(producers[0].OwnerPublicKey).Votes())
+
+	// Revert again.
+	assert.NoError(t, a.RevertLastBlock())
+	assert.Equal(t, state6, dump())
+	assert.Equal(t, common.Fixed64(0),
+		a.GetProducer(producers[0].OwnerPublicKey).Votes())
+
+	// Revert the arbiters change on the last block.
+	arbiters, candidates := a.GetArbitrators(), a.GetCandidates()
+	nextArbiters := [][]byte{producers[2].NodePublicKey,
+		producers[3].NodePublicKey}
+	a.nextArbitrators = copyByteList(nextArbiters)
+	processBlock(mockBlock(7))
+	a.NormalChange(7)
+	assert.ElementsMatch(t, nextArbiters, a.GetArbitrators())
+	assert.NoError(t, a.RevertLastBlock())
+	assert.Equal(t, arbiters, a.GetArbitrators())
+	assert.Equal(t, candidates, a.GetCandidates())
+	assert.Equal(t, nextArbiters, a.GetNextArbitrators())
+
+	// Revert to the history floor.
+	for i := 0; i < 6; i++ {
+		assert.NoError(t, a.RevertLastBlock())
+	}
+	assert.Error(t, a.RevertLastBlock())
+}
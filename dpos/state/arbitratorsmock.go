@@ -1,10 +1,14 @@
 package state
 
 import (
+	"bytes"
+	"io"
+
 	"github.com/elastos/Elastos.ELA/common"
 	"github.com/elastos/Elastos.ELA/core/types"
 	"github.com/elastos/Elastos.ELA/core/types/payload"
 	"github.com/elastos/Elastos.ELA/dpos/p2p"
+	"github.com/elastos/Elastos.ELA/dpos/p2p/peer"
 )
 
 func NewArbitratorsMock(arbitersByte [][]byte, changeCount, majorityCount int) *ArbitratorsMock {
@@ -34,21 +38,31 @@ type ArbitratorsMock struct {
 	TotalVotesInRound           common.Fixed64
 	DutyChangedCount            int
 	MajorityCount               int
+	CRCArbitrators              [][]byte
+	CRCOwnerProgramHashes       []*common.Uint168
 }
 
 func (a *ArbitratorsMock) GetDutyIndexByHeight(height uint32) int {
 	panic("implement me")
 }
 
+func (a *ArbitratorsMock) GetHistoricalDutyIndex(height uint32) (int, error) {
+	panic("implement me")
+}
+
 func (a *ArbitratorsMock) GetDutyIndex() int {
 	panic("implement me")
 }
 
+func (a *ArbitratorsMock) GetChangeTypeAt(height uint32) (ChangeType, uint32) {
+	panic("implement me")
+}
+
 func (a *ArbitratorsMock) ProcessSpecialTxPayload(p types.Payload, height uint32) error {
 	panic("implement me")
 }
 
-func (a *ArbitratorsMock) ProcessBlock(block *types.Block, confirm *payload.Confirm) {
+func (a *ArbitratorsMock) ProcessBlock(block *types.Block, confirm *payload.Confirm) error {
 	panic("implement me")
 }
 
@@ -56,16 +70,29 @@ func (a *ArbitratorsMock) RollbackTo(height uint32) error {
 	panic("implement me")
 }
 
+func (a *ArbitratorsMock) RevertLastBlock() error {
+	panic("implement me")
+}
+
 func (a *ArbitratorsMock) GetNeedConnectArbiters(height uint32) map[string]*p2p.PeerAddr {
 	panic("implement me")
 }
 
+func (a *ArbitratorsMock) GetNeedConnectArbitersRanked() []peer.PID {
+	panic("implement me")
+}
+
 func (a *ArbitratorsMock) IsArbitrator(pk []byte) bool {
 	panic("implement me")
 }
 
 func (a *ArbitratorsMock) IsCRCArbitrator(pk []byte) bool {
-	panic("implement me")
+	for _, v := range a.CRCArbitrators {
+		if bytes.Equal(v, pk) {
+			return true
+		}
+	}
+	return false
 }
 
 func (a *ArbitratorsMock) GetLastConfirmedBlockTimeStamp() uint32 {
@@ -80,11 +107,20 @@ func (a *ArbitratorsMock) GetCRCProducer(publicKey []byte) *Producer {
 	panic("implement me")
 }
 
+func (a *ArbitratorsMock) GetCRCArbiterDetails() []CRCArbiterInfo {
+	panic("implement me")
+}
+
 func (a *ArbitratorsMock) GetCRCArbitrators() map[string]*Producer {
 	panic("implement me")
 }
 
 func (a *ArbitratorsMock) IsCRCArbitratorProgramHash(hash *common.Uint168) bool {
+	for _, v := range a.CRCOwnerProgramHashes {
+		if v.IsEqual(*hash) {
+			return true
+		}
+	}
 	return false
 }
 
@@ -128,6 +164,16 @@ func (a *ArbitratorsMock) GetNextCandidates() [][]byte {
 	return a.NextCandidates
 }
 
+func (a *ArbitratorsMock) GetUpcomingArbiterChanges() (joining [][]byte,
+	leaving [][]byte) {
+	panic("implement me")
+}
+
+func (a *ArbitratorsMock) SimulateArbiterSet(
+	adjustments map[string]common.Fixed64) ([][]byte, [][]byte) {
+	panic("implement me")
+}
+
 func (a *ArbitratorsMock) GetDutyChangedCount() int {
 	return a.DutyChangedCount
 }
@@ -176,10 +222,105 @@ func (a *ArbitratorsMock) GetTotalVotesInRound() common.Fixed64 {
 	return result
 }
 
+func (a *ArbitratorsMock) GetOwnerVotesInRound(
+	programHash common.Uint168) common.Fixed64 {
+	result := a.OwnerVotesInRound[programHash]
+
+	return result
+}
+
+func (a *ArbitratorsMock) GetVotesInRound() (map[common.Uint168]common.Fixed64,
+	common.Fixed64) {
+	ownerVotes := make(map[common.Uint168]common.Fixed64)
+	for k, v := range a.OwnerVotesInRound {
+		ownerVotes[k] = v
+	}
+
+	return ownerVotes, a.TotalVotesInRound
+}
+
+func (a *ArbitratorsMock) RegisterOnBlockReward(
+	onBlockReward func(height uint32, reward common.Fixed64)) {
+	panic("implement me")
+}
+
+func (a *ArbitratorsMock) GetLastChange() ArbitersChange {
+	panic("implement me")
+}
+
+func (a *ArbitratorsMock) EstimateNextChangeHeight() uint32 {
+	panic("implement me")
+}
+
+func (a *ArbitratorsMock) GetArbiterBlockCount(nodePublicKey []byte) uint32 {
+	panic("implement me")
+}
+
+func (a *ArbitratorsMock) GetProducerUptime(nodePublicKey []byte,
+	windowBlocks uint32) float64 {
+	panic("implement me")
+}
+
+func (a *ArbitratorsMock) GetRecentPromotions(n int) []PromotionRecord {
+	panic("implement me")
+}
+
+func (a *ArbitratorsMock) GetProducerRankDelta(nodePublicKey []byte) int {
+	panic("implement me")
+}
+
+func (a *ArbitratorsMock) GetNetworkMode() NetworkMode {
+	panic("implement me")
+}
+
+func (a *ArbitratorsMock) IsCRCOnlyPeriod(height uint32) bool {
+	panic("implement me")
+}
+
+func (a *ArbitratorsMock) IsPublicDPOSPeriod(height uint32) bool {
+	panic("implement me")
+}
+
+func (a *ArbitratorsMock) GetAccumulatedReward(ownerHash common.Uint168,
+	fromHeight, toHeight uint32) (common.Fixed64, error) {
+	panic("implement me")
+}
+
+func (a *ArbitratorsMock) GetNextBlockDPOSReward() common.Fixed64 {
+	panic("implement me")
+}
+
 func (a *ArbitratorsMock) GetOnDutyArbitrator() []byte {
 	return a.GetNextOnDutyArbitrator(0)
 }
 
+func (a *ArbitratorsMock) IsOnDuty(nodePublicKey []byte) bool {
+	return bytes.Equal(a.GetOnDutyArbitrator(), nodePublicKey)
+}
+
+func (a *ArbitratorsMock) ValidateConfirm(block *types.Block,
+	confirm *payload.Confirm) error {
+	panic("implement me")
+}
+
+func (a *ArbitratorsMock) GetNonSigners(confirm *payload.Confirm) [][]byte {
+	panic("implement me")
+}
+
+func (a *ArbitratorsMock) ValidateIllegalBlockEvidence(
+	p *payload.DPOSIllegalBlocks) error {
+	panic("implement me")
+}
+
+func (a *ArbitratorsMock) GetOnDutyCrossChainArbitrator() []byte {
+	panic("implement me")
+}
+
+func (a *ArbitratorsMock) GetOnDutyCrossChainArbitratorAtHeight(
+	height uint32) []byte {
+	panic("implement me")
+}
+
 func (a *ArbitratorsMock) GetNextOnDutyArbitrator(offset uint32) []byte {
 	if len(a.CurrentArbitrators) == 0 {
 		return nil
@@ -199,3 +340,7 @@ func (a *ArbitratorsMock) HasArbitersMinorityCount(num int) bool {
 func (a *ArbitratorsMock) DumpInfo() {
 	panic("implement me")
 }
+
+func (a *ArbitratorsMock) DumpState(w io.Writer) error {
+	panic("implement me")
+}
@@ -0,0 +1,8 @@
+//go:build debug
+// +build debug
+
+package state
+
+// debugCheckInvariants indicates if state invariants will be checked after
+// each block processed, it's enabled in debug builds.
+const debugCheckInvariants = true
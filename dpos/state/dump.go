@@ -0,0 +1,149 @@
+package state
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sort"
+
+	"github.com/elastos/Elastos.ELA/common"
+)
+
+// producerDump is the diagnostic dump of a producer.
+type producerDump struct {
+	OwnerPublicKey string         `json:"ownerpublickey"`
+	NodePublicKey  string         `json:"nodepublickey"`
+	NickName       string         `json:"nickname"`
+	Url            string         `json:"url"`
+	NetAddress     string         `json:"netaddress"`
+	State          string         `json:"state"`
+	RegisterHeight uint32         `json:"registerheight"`
+	CancelHeight   uint32         `json:"cancelheight"`
+	InactiveSince  uint32         `json:"inactivesince"`
+	InactiveCount  uint32         `json:"inactivecount"`
+	JailUntil      uint32         `json:"jailuntil"`
+	IllegalHeight  uint32         `json:"illegalheight"`
+	Penalty        common.Fixed64 `json:"penalty"`
+	Votes          common.Fixed64 `json:"votes"`
+	SelfVotes      common.Fixed64 `json:"selfvotes"`
+	CandidateVotes common.Fixed64 `json:"candidatevotes"`
+	DepositAmount  common.Fixed64 `json:"depositamount"`
+}
+
+// stateDump is the diagnostic dump of State.
+type stateDump struct {
+	Height       uint32                     `json:"height"`
+	Producers    map[string][]*producerDump `json:"producers"`
+	AbstainVotes common.Fixed64             `json:"abstainvotes"`
+	VoteCount    int                        `json:"votecount"`
+}
+
+// arbitratorsDump is the diagnostic dump of arbitrators and its State.
+type arbitratorsDump struct {
+	State             stateDump                 `json:"state"`
+	DutyIndex         int                       `json:"dutyindex"`
+	NetworkMode       string                    `json:"networkmode"`
+	CurrentArbiters   []string                  `json:"currentarbiters"`
+	CurrentCandidates []string                  `json:"currentcandidates"`
+	NextArbiters      []string                  `json:"nextarbiters"`
+	NextCandidates    []string                  `json:"nextcandidates"`
+	OwnerVotes        map[string]common.Fixed64 `json:"ownervotes"`
+	TotalVotes        common.Fixed64            `json:"totalvotes"`
+	Rewards           map[string]common.Fixed64 `json:"rewards"`
+	RewardsFrom       uint32                    `json:"rewardsfrom"`
+	RewardsTo         uint32                    `json:"rewardsto"`
+}
+
+func newProducerDump(p *Producer) *producerDump {
+	return &producerDump{
+		OwnerPublicKey: hex.EncodeToString(p.info.OwnerPublicKey),
+		NodePublicKey:  hex.EncodeToString(p.info.NodePublicKey),
+		NickName:       p.info.NickName,
+		Url:            p.info.Url,
+		NetAddress:     p.info.NetAddress,
+		State:          p.state.String(),
+		RegisterHeight: p.registerHeight,
+		CancelHeight:   p.cancelHeight,
+		InactiveSince:  p.inactiveSince,
+		InactiveCount:  p.inactiveCount,
+		JailUntil:      p.jailUntilHeight,
+		IllegalHeight:  p.illegalHeight,
+		Penalty:        p.penalty,
+		Votes:          p.votes,
+		SelfVotes:      p.selfVotes,
+		CandidateVotes: p.candidateVotes,
+		DepositAmount:  p.depositAmount,
+	}
+}
+
+// dump returns the diagnostic dump of state, producers of each state are
+// ordered by owner public key.
+func (s *State) dump() stateDump {
+	producers := make(map[string][]*producerDump)
+	for _, m := range []map[string]*Producer{s.pendingProducers,
+		s.activityProducers, s.inactiveProducers, s.jailedProducers,
+		s.canceledProducers, s.illegalProducers} {
+		for _, p := range m {
+			state := p.state.String()
+			producers[state] = append(producers[state], newProducerDump(p))
+		}
+	}
+	for _, list := range producers {
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].OwnerPublicKey < list[j].OwnerPublicKey
+		})
+	}
+
+	return stateDump{
+		Height:       s.history.height,
+		Producers:    producers,
+		AbstainVotes: s.abstainVotes,
+		VoteCount:    len(s.votes),
+	}
+}
+
+func hexStrings(keys [][]byte) []string {
+	result := make([]string, 0, len(keys))
+	for _, k := range keys {
+		result = append(result, hex.EncodeToString(k))
+	}
+	return result
+}
+
+// DumpState writes the full state of arbitrators and producers in JSON
+// format to w, for diagnosing.  The state is dumped under the locks so the
+// result is a consistent snapshot.
+func (a *arbitrators) DumpState(w io.Writer) error {
+	a.mtx.Lock()
+	a.State.mtx.RLock()
+	dump := arbitratorsDump{
+		State:             a.State.dump(),
+		DutyIndex:         a.dutyIndex,
+		NetworkMode:       a.networkMode.String(),
+		CurrentArbiters:   hexStrings(a.currentArbitrators),
+		CurrentCandidates: hexStrings(a.currentCandidates),
+		NextArbiters:      hexStrings(a.nextArbitrators),
+		NextCandidates:    hexStrings(a.nextCandidates),
+		OwnerVotes:        make(map[string]common.Fixed64),
+		TotalVotes:        a.totalVotesInRound,
+		Rewards:           make(map[string]common.Fixed64),
+	}
+	for hash, votes := range a.ownerVotesInRound {
+		dump.OwnerVotes[hash.String()] = votes
+	}
+	if len(a.rewardHistory) > 0 {
+		dump.RewardsFrom = a.rewardHistory[0].height
+		dump.RewardsTo = a.rewardHistory[len(a.rewardHistory)-1].height
+	}
+	for _, r := range a.rewardHistory {
+		for hash, reward := range r.rewards {
+			dump.Rewards[hash.String()] += reward
+		}
+	}
+	a.State.mtx.RUnlock()
+	a.mtx.Unlock()
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "\t")
+	return encoder.Encode(&dump)
+}
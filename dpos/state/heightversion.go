@@ -1,21 +1,8 @@
 package state
 
-import (
-	"github.com/elastos/Elastos.ELA/common"
-)
-
 // 0 - H1
 func (a *arbitrators) getNormalArbitratorsDescV0() ([][]byte, error) {
-	arbitersByte := make([][]byte, 0)
-	for _, arbiter := range a.State.chainParams.OriginArbiters {
-		arbiterByte, err := common.HexStringToBytes(arbiter)
-		if err != nil {
-			return nil, err
-		}
-		arbitersByte = append(arbitersByte, arbiterByte)
-	}
-
-	return arbitersByte, nil
+	return copyByteList(a.originArbiters), nil
 }
 
 // H1 - H2
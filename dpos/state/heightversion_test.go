@@ -208,7 +208,7 @@ func TestArbitrators_GetNextOnDutyArbitrator(t *testing.T) {
 	bestHeight = arbiters.State.chainParams.CRCOnlyDPOSHeight - 1
 	arbiters.dutyIndex = 0
 	arbiters.updateNextArbitrators(bestHeight + 1)
-	arbiters.changeCurrentArbitrators()
+	arbiters.changeCurrentArbitrators(bestHeight)
 
 	sortedArbiters := arbiters.State.chainParams.CRCArbiters
 	sort.Slice(sortedArbiters, func(i, j int) bool {
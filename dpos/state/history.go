@@ -18,6 +18,9 @@ type heightChanges struct {
 
 	// changes are the changes on the height.
 	changes []change
+
+	// records are the producer change records on the height.
+	records []ProducerChange
 }
 
 // append add a change into changes
@@ -62,6 +65,10 @@ type history struct {
 	// seekHeight stores a seek height if seekTo method was called, when a new
 	// block received, state will be seek to best height first.
 	seekHeight uint32
+
+	// committing is the height changes being committed for the first time,
+	// producer change records will be added into it.
+	committing *heightChanges
 }
 
 // append add a change and it's rollback into history.
@@ -128,7 +135,9 @@ func (h *history) commit(height uint32) {
 	}
 
 	// commit cached changes and update history height.
+	h.committing = h.cachedChanges
 	h.cachedChanges.commit()
+	h.committing = nil
 	h.height = height
 	h.seekHeight = height
 
@@ -139,6 +148,43 @@ func (h *history) commit(height uint32) {
 	h.cachedChanges = nil
 }
 
+// record adds a producer change record into the changes being committed, it
+// takes no effect when changes are executed by seeking or temporary changes.
+func (h *history) record(pc ProducerChange) {
+	if h.committing == nil {
+		return
+	}
+	pc.Height = h.committing.height
+	h.committing.records = append(h.committing.records, pc)
+}
+
+// diff returns the producer change records happened in range of
+// (fromHeight, toHeight].
+func (h *history) diff(fromHeight, toHeight uint32) ([]ProducerChange, error) {
+	// check whether history is enough to diff
+	limitHeight := h.height - uint32(len(h.changes))
+	if fromHeight < limitHeight {
+		return nil, fmt.Errorf("seek to %d overflow history capacity,"+
+			" at most seek to %d", fromHeight, limitHeight)
+	}
+	if toHeight > h.height {
+		return nil, fmt.Errorf("seek to %d overflow best height %d",
+			toHeight, h.height)
+	}
+	if fromHeight > toHeight {
+		return nil, fmt.Errorf("invalid diff range (%d, %d]",
+			fromHeight, toHeight)
+	}
+
+	records := make([]ProducerChange, 0)
+	for _, changes := range h.changes {
+		if changes.height > fromHeight && changes.height <= toHeight {
+			records = append(records, changes.records...)
+		}
+	}
+	return records, nil
+}
+
 // seekTo changes state to a historical height in range of history capacity.
 func (h *history) seekTo(height uint32) error {
 	// check whether history is enough to seek
@@ -147,6 +193,10 @@ func (h *history) seekTo(height uint32) error {
 		return fmt.Errorf("seek to %d overflow history capacity,"+
 			" at most seek to %d", height, limitHeight)
 	}
+	if height > h.height {
+		return fmt.Errorf("seek to %d beyond current height %d", height,
+			h.height)
+	}
 
 	// seek changes to the historical height.
 	seek := int(h.seekHeight) - int(height)
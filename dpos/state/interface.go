@@ -1,28 +1,39 @@
 package state
 
 import (
+	"io"
+
 	"github.com/elastos/Elastos.ELA/common"
 	"github.com/elastos/Elastos.ELA/core/types"
 	"github.com/elastos/Elastos.ELA/core/types/payload"
 	"github.com/elastos/Elastos.ELA/dpos/p2p"
+	"github.com/elastos/Elastos.ELA/dpos/p2p/peer"
 )
 
 type Arbitrators interface {
-	ProcessBlock(block *types.Block, confirm *payload.Confirm)
+	ProcessBlock(block *types.Block, confirm *payload.Confirm) error
 	ProcessSpecialTxPayload(p types.Payload, height uint32) error
 	RollbackTo(height uint32) error
+	RevertLastBlock() error
 
 	IsArbitrator(pk []byte) bool
 	GetArbitrators() [][]byte
 	GetCandidates() [][]byte
 	GetNextArbitrators() [][]byte
 	GetNextCandidates() [][]byte
+	GetUpcomingArbiterChanges() (joining [][]byte, leaving [][]byte)
+	SimulateArbiterSet(
+		adjustments map[string]common.Fixed64) ([][]byte, [][]byte)
 	GetNeedConnectArbiters(height uint32) map[string]*p2p.PeerAddr
+	GetNeedConnectArbitersRanked() []peer.PID
 	GetDutyIndexByHeight(height uint32) int
+	GetHistoricalDutyIndex(height uint32) (int, error)
 	GetDutyIndex() int
+	GetChangeTypeAt(height uint32) (ChangeType, uint32)
 
 	GetCRCProducer(publicKey []byte) *Producer
 	GetCRCArbitrators() map[string]*Producer
+	GetCRCArbiterDetails() []CRCArbiterInfo
 	IsCRCArbitrator(pk []byte) bool
 	IsCRCArbitratorProgramHash(hash *common.Uint168) bool
 	IsCRCArbitratorNodePublicKey(nodePublicKeyHex string) bool
@@ -31,9 +42,31 @@ type Arbitrators interface {
 	GetCandidateOwnerProgramHashes() []*common.Uint168
 	GetOwnerVotes(programHash *common.Uint168) common.Fixed64
 	GetTotalVotesInRound() common.Fixed64
+	GetOwnerVotesInRound(programHash common.Uint168) common.Fixed64
+	GetVotesInRound() (map[common.Uint168]common.Fixed64, common.Fixed64)
+	GetLastChange() ArbitersChange
+	EstimateNextChangeHeight() uint32
+	GetArbiterBlockCount(nodePublicKey []byte) uint32
+	GetProducerUptime(nodePublicKey []byte, windowBlocks uint32) float64
+	GetRecentPromotions(n int) []PromotionRecord
+	GetProducerRankDelta(nodePublicKey []byte) int
+	GetNetworkMode() NetworkMode
+	IsCRCOnlyPeriod(height uint32) bool
+	IsPublicDPOSPeriod(height uint32) bool
+	RegisterOnBlockReward(
+		onBlockReward func(height uint32, reward common.Fixed64))
+	GetAccumulatedReward(ownerHash common.Uint168,
+		fromHeight, toHeight uint32) (common.Fixed64, error)
+	GetNextBlockDPOSReward() common.Fixed64
 
 	GetOnDutyArbitrator() []byte
 	GetNextOnDutyArbitrator(offset uint32) []byte
+	GetOnDutyCrossChainArbitrator() []byte
+	GetOnDutyCrossChainArbitratorAtHeight(height uint32) []byte
+	IsOnDuty(nodePublicKey []byte) bool
+	ValidateConfirm(block *types.Block, confirm *payload.Confirm) error
+	GetNonSigners(confirm *payload.Confirm) [][]byte
+	ValidateIllegalBlockEvidence(p *payload.DPOSIllegalBlocks) error
 
 	GetArbitersCount() int
 	GetArbitersMajorityCount() int
@@ -41,4 +74,5 @@ type Arbitrators interface {
 	HasArbitersMinorityCount(num int) bool
 
 	DumpInfo()
+	DumpState(w io.Writer) error
 }
@@ -2,16 +2,26 @@ package state
 
 import (
 	"bytes"
+	"container/heap"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"math"
+	"math/big"
+	"net/url"
+	"sort"
+	"strings"
 	"sync"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/elastos/Elastos.ELA/common"
 	"github.com/elastos/Elastos.ELA/common/config"
+	"github.com/elastos/Elastos.ELA/core/contract"
 	"github.com/elastos/Elastos.ELA/core/types"
 	"github.com/elastos/Elastos.ELA/core/types/outputpayload"
 	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/events"
 )
 
 // ProducerState represents the state of a producer.
@@ -38,12 +48,16 @@ const (
 
 	// ReturnedDeposit indicates the producer has canceled and returned deposit
 	ReturnedDeposit
+
+	// Jailed indicates the producer has been inactive too many times and is
+	// excluded from arbiters selection until the jail height.
+	Jailed
 )
 
 // producerStateStrings is a array of producer states back to their constant
 // names for pretty printing.
 var producerStateStrings = []string{"Pending", "Activate", "Inactivate",
-	"Canceled", "FoundBad", "ReturnedDeposit"}
+	"Canceled", "FoundBad", "ReturnedDeposit", "Jailed"}
 
 func (ps ProducerState) String() string {
 	if int(ps) < len(producerStateStrings) {
@@ -52,19 +66,90 @@ func (ps ProducerState) String() string {
 	return fmt.Sprintf("ProducerState-%d", ps)
 }
 
+// ProducerChangeType represents the type of a producer change.
+type ProducerChangeType byte
+
+const (
+	// ProducerRegistered indicates the producer was registered.
+	ProducerRegistered ProducerChangeType = iota
+
+	// ProducerUpdated indicates the producer's info was updated.
+	ProducerUpdated
+
+	// ProducerCanceled indicates the producer was canceled.
+	ProducerCanceled
+
+	// ProducerVotesChanged indicates the producer's votes were changed.
+	ProducerVotesChanged
+
+	// ProducerStateChanged indicates the producer's state was changed.
+	ProducerStateChanged
+)
+
+// producerChangeTypeStrings is a array of producer change types back to their
+// constant names for pretty printing.
+var producerChangeTypeStrings = []string{"ProducerRegistered",
+	"ProducerUpdated", "ProducerCanceled", "ProducerVotesChanged",
+	"ProducerStateChanged"}
+
+func (t ProducerChangeType) String() string {
+	if int(t) < len(producerChangeTypeStrings) {
+		return producerChangeTypeStrings[t]
+	}
+	return fmt.Sprintf("ProducerChangeType-%d", t)
+}
+
+// ProducerChange holds a change record of a producer.
+type ProducerChange struct {
+	// Height is the height the change happened.
+	Height uint32
+
+	// Type is the type of the change.
+	Type ProducerChangeType
+
+	// OwnerPublicKey is the owner public key of the changed producer.
+	OwnerPublicKey []byte
+
+	// Votes is the votes delta of a ProducerVotesChanged change.
+	Votes common.Fixed64
+
+	// State is the producer's state after the change.
+	State ProducerState
+}
+
+// NearInactiveArbiter is the data of ETArbiterNearInactive event.
+type NearInactiveArbiter struct {
+	NodePublicKey []byte
+	MissCount     uint32
+}
+
 // Producer holds a producer's info.  It provides read only methods to access
 // producer's info.
 type Producer struct {
 	info                   payload.ProducerInfo
 	state                  ProducerState
 	registerHeight         uint32
+	registerTxHash         common.Uint256
 	cancelHeight           uint32
+	canceledAsCRC          bool
 	inactiveCountingHeight uint32
+	inactiveWarned         bool
 	inactiveSince          uint32
+	inactiveCount          uint32
+	jailUntilHeight        uint32
 	activateRequestHeight  uint32
 	illegalHeight          uint32
+	illegalEvidence        *payload.DPOSIllegalBlocks
 	penalty                common.Fixed64
 	votes                  common.Fixed64
+	selfVotes              common.Fixed64
+	candidateVotes         common.Fixed64
+	depositAmount          common.Fixed64
+	timeline               []LifecycleEvent
+
+	// voteChangeHeights records the recent heights the votes changed on, in
+	// ascending order, kept for rollback.
+	voteChangeHeights []uint32
 }
 
 // Info returns a copy of the origin registered producer info.
@@ -82,6 +167,11 @@ func (p *Producer) RegisterHeight() uint32 {
 	return p.registerHeight
 }
 
+// RegisterTxHash returns the hash of the transaction registered the producer.
+func (p *Producer) RegisterTxHash() common.Uint256 {
+	return p.registerTxHash
+}
+
 // CancelHeight returns the height when the producer was canceled.
 func (p *Producer) CancelHeight() uint32 {
 	return p.cancelHeight
@@ -92,6 +182,12 @@ func (p *Producer) Votes() common.Fixed64 {
 	return p.votes
 }
 
+// CandidateVotes returns the votes backing the producer to be a candidate,
+// which are used for candidates selection only.
+func (p *Producer) CandidateVotes() common.Fixed64 {
+	return p.candidateVotes
+}
+
 func (p *Producer) NodePublicKey() []byte {
 	return p.info.NodePublicKey
 }
@@ -108,12 +204,211 @@ func (p *Producer) InactiveSince() uint32 {
 	return p.inactiveSince
 }
 
+// JailUntilHeight returns the height the jailed producer will be released.
+func (p *Producer) JailUntilHeight() uint32 {
+	return p.jailUntilHeight
+}
+
 func (p *Producer) IllegalHeight() uint32 {
 	return p.illegalHeight
 }
 
+// LastVoteChangeHeight returns the most recent height on which the producer's
+// votes changed, 0 means the votes never changed.
+func (p *Producer) LastVoteChangeHeight() uint32 {
+	if n := len(p.voteChangeHeights); n > 0 {
+		return p.voteChangeHeights[n-1]
+	}
+	return 0
+}
+
+// DepositAmount returns the deposit amount of the producer, including the
+// deposit top-ups after registration.
+func (p *Producer) DepositAmount() common.Fixed64 {
+	return p.depositAmount
+}
+
+// Timeline returns the significant lifecycle events of the producer in the
+// order they happened.
+func (p *Producer) Timeline() []LifecycleEvent {
+	timeline := make([]LifecycleEvent, len(p.timeline))
+	copy(timeline, p.timeline)
+	return timeline
+}
+
+// addLifecycleEvent appends a lifecycle event to the producer's timeline.
+func (p *Producer) addLifecycleEvent(t LifecycleEventType, height uint32) {
+	p.timeline = append(p.timeline, LifecycleEvent{
+		Height: height,
+		Type:   t,
+		State:  p.state,
+	})
+}
+
+// removeLifecycleEvent removes the last lifecycle event from the producer's
+// timeline on rollback.
+func (p *Producer) removeLifecycleEvent() {
+	if n := len(p.timeline); n > 0 {
+		// Limit the capacity so the following appends will not overwrite the
+		// timeline shared with history snapshots.
+		p.timeline = p.timeline[: n-1 : n-1]
+	}
+}
+
+// addVoteChangeHeight records the height the producer's votes changed on, only
+// the recent heights within the given limit are kept.
+func (p *Producer) addVoteChangeHeight(height uint32, limit int) {
+	if n := len(p.voteChangeHeights); n > 0 &&
+		p.voteChangeHeights[n-1] == height {
+		return
+	}
+	p.voteChangeHeights = append(p.voteChangeHeights, height)
+	if len(p.voteChangeHeights) > limit {
+		p.voteChangeHeights = p.voteChangeHeights[1:]
+	}
+}
+
+// removeVoteChangeHeight removes the height the producer's votes changed on
+// when rollback.
+func (p *Producer) removeVoteChangeHeight(height uint32) {
+	if n := len(p.voteChangeHeights); n > 0 &&
+		p.voteChangeHeights[n-1] == height {
+		// Limit the capacity so the following appends will not overwrite the
+		// heights shared with history snapshots.
+		p.voteChangeHeights = p.voteChangeHeights[: n-1 : n-1]
+	}
+}
+
+// LifecycleEventType represents the type of a producer lifecycle event.
+type LifecycleEventType byte
+
+const (
+	// LifecycleRegistered indicates the producer was registered.
+	LifecycleRegistered LifecycleEventType = iota
+
+	// LifecycleUpdated indicates the producer's info was updated.
+	LifecycleUpdated
+
+	// LifecycleCanceled indicates the producer was canceled.
+	LifecycleCanceled
+
+	// LifecycleActivated indicates the producer became active.
+	LifecycleActivated
+
+	// LifecycleInactive indicates the producer went inactive.
+	LifecycleInactive
+
+	// LifecycleJailed indicates the producer was jailed.
+	LifecycleJailed
+
+	// LifecycleIllegal indicates the producer was found illegal.
+	LifecycleIllegal
+
+	// LifecycleReturnedDeposit indicates the producer's deposit was returned.
+	LifecycleReturnedDeposit
+)
+
+// lifecycleEventTypeStrings is a array of lifecycle event types back to their
+// constant names for pretty printing.
+var lifecycleEventTypeStrings = []string{"LifecycleRegistered",
+	"LifecycleUpdated", "LifecycleCanceled", "LifecycleActivated",
+	"LifecycleInactive", "LifecycleJailed", "LifecycleIllegal",
+	"LifecycleReturnedDeposit"}
+
+func (t LifecycleEventType) String() string {
+	if int(t) < len(lifecycleEventTypeStrings) {
+		return lifecycleEventTypeStrings[t]
+	}
+	return fmt.Sprintf("LifecycleEventType-%d", t)
+}
+
+// LifecycleEvent records a significant event in a producer's lifecycle.
+type LifecycleEvent struct {
+	// Height is the height the event happened.
+	Height uint32
+
+	// Type is the type of the event.
+	Type LifecycleEventType
+
+	// State is the producer's state after the event.
+	State ProducerState
+}
+
+// DepositRefund holds the refundable deposit of a canceled producer.
+type DepositRefund struct {
+	// OwnerPublicKey is the owner public key of the canceled producer.
+	OwnerPublicKey []byte
+
+	// OwnerProgramHash is the standard program hash of the owner public key.
+	OwnerProgramHash common.Uint168
+
+	// Amount is the deposit amount deducted by the producer's penalty.
+	Amount common.Fixed64
+}
+
+// ConflictReport describes different illegal evidences accusing the same
+// producer on the same height, which may indicate forged illegal payloads.
+type ConflictReport struct {
+	// Type is the illegal data type of the evidences.
+	Type payload.IllegalDataType
+
+	// Signer is the public key of the accused producer.
+	Signer []byte
+
+	// Height is the height the illegal behavior happened on.
+	Height uint32
+
+	// PayloadHashes are hashes of the conflicting illegal payloads.
+	PayloadHashes []common.Uint256
+}
+
+// VoteStats holds the votes statistics of the whole network.
+type VoteStats struct {
+	// TotalVotes is the sum of all producers votes.
+	TotalVotes common.Fixed64
+
+	// VotedProducers is the number of producers with nonzero votes.
+	VotedProducers int
+
+	// TopArbitersVotes is the sum of votes of the top general arbiters.
+	TopArbitersVotes common.Fixed64
+
+	// TopArbitersShare is the share of TopArbitersVotes in TotalVotes.
+	TopArbitersShare float64
+
+	// AbstainVotes is the sum of abstain votes.
+	AbstainVotes common.Fixed64
+}
+
+// votesCredit holds the votes credited to a producer by a vote output, the
+// amount is determined when the vote has been executed.
+type votesCredit struct {
+	producer  *Producer
+	amount    common.Fixed64
+	self      bool
+	candidate bool
+}
+
+// voteRecord holds a vote output and the producers it's votes have been
+// credited to.
+type voteRecord struct {
+	outPoint types.OutPoint
+	height   uint32
+	output   *types.Output
+	credits  []*votesCredit
+	abstain  bool
+}
+
+// VoteSource is an outstanding vote output crediting a producer's votes.
+type VoteSource struct {
+	OutPoint types.OutPoint
+	Amount   common.Fixed64
+	Height   uint32
+}
+
 const (
-	// maxHistoryCapacity indicates the maximum capacity of change history.
+	// maxHistoryCapacity indicates the default maximum capacity of change
+	// history if not specified by chain params.
 	maxHistoryCapacity = 10
 
 	// snapshotInterval is the time interval to take a snapshot of the state.
@@ -121,6 +416,10 @@ const (
 
 	// maxSnapshots is the maximum newest snapshots keeps in memory.
 	maxSnapshots = 9
+
+	// activateConfirmations is the confirmations a pending producer needs
+	// to be activated, include the register block.
+	activateConfirmations = 6
 )
 
 // State is a memory database storing DPOS producers state, like pending
@@ -130,18 +429,52 @@ type State struct {
 	getArbiters func() [][]byte
 	chainParams *config.Params
 
+	// getTxReference returns the outputs referenced by inputs of the
+	// transaction, it's used to find the self votes.
+	getTxReference func(tx *types.Transaction) (map[*types.Input]*types.Output,
+		error)
+
+	// processMtx is held by state mutations and snapshotting, so a snapshot
+	// sees a consistent view while reads are still allowed.
+	processMtx sync.Mutex
+
 	mtx               sync.RWMutex
 	nodeOwnerKeys     map[string]string // NodePublicKey as key, OwnerPublicKey as value
 	pendingProducers  map[string]*Producer
 	activityProducers map[string]*Producer
 	inactiveProducers map[string]*Producer
+	jailedProducers   map[string]*Producer
 	canceledProducers map[string]*Producer
 	illegalProducers  map[string]*Producer
-	votes             map[string]*types.Output
+	votes             map[string]*voteRecord
+	abstainVotes      common.Fixed64
 	nicknames         map[string]struct{}
 	specialTxHashes   map[string]struct{}
 	history           *history
 
+	// depositOwnerKeys indexes owner public keys of producers by their
+	// deposit program hashes, to find deposit top-ups.
+	depositOwnerKeys map[common.Uint168]string
+
+	// crcNodePublicKeys are the node public keys of the CRC arbiters in use,
+	// they are set by arbitrators when the CRC arbiters are replaced.
+	crcNodePublicKeys map[string]struct{}
+
+	// illegalPayloads keeps the processed illegal evidence payloads by their
+	// hash for conflicting evidence detection.
+	illegalPayloads map[common.Uint256]payload.DPOSIllegalData
+
+	// votesWatchers are the registered watchers of producer votes.
+	votesWatchers map[*votesWatcher]struct{}
+
+	// nearInactive queues the near inactive warnings found while processing
+	// a block, they are notified after the block processed.
+	nearInactive []*NearInactiveArbiter
+
+	// changedProducers records the producers whose votes or info have been
+	// changed since last taken by takeChangedProducers.
+	changedProducers map[*Producer]struct{}
+
 	// snapshots is the data set of DPOS state snapshots, it takes a snapshot of
 	// state every 12 blocks, and keeps at most 9 newest snapshots in memory.
 	snapshots [maxSnapshots]*State
@@ -165,7 +498,12 @@ func (s *State) getProducerKey(publicKey []byte) string {
 // getProducer returns a producer with the producer's node public key or it's
 // owner public key, if no matches return nil.
 func (s *State) getProducer(publicKey []byte) *Producer {
-	key := s.getProducerKey(publicKey)
+	return s.getProducerByOwnerKey(s.getProducerKey(publicKey))
+}
+
+// getProducerByOwnerKey returns a producer with the producer's owner public key
+// hex string, if no matches return nil.
+func (s *State) getProducerByOwnerKey(key string) *Producer {
 	if producer, ok := s.activityProducers[key]; ok {
 		return producer
 	}
@@ -181,6 +519,9 @@ func (s *State) getProducer(publicKey []byte) *Producer {
 	if producer, ok := s.inactiveProducers[key]; ok {
 		return producer
 	}
+	if producer, ok := s.jailedProducers[key]; ok {
+		return producer
+	}
 	return nil
 }
 
@@ -205,6 +546,28 @@ func (s *State) updateProducerInfo(origin *payload.ProducerInfo, update *payload
 	}
 
 	producer.info = *update
+	s.changedProducers[producer] = struct{}{}
+}
+
+// recordChange records a change of the producer into history.
+func (s *State) recordChange(t ProducerChangeType, producer *Producer,
+	votes common.Fixed64) {
+	s.history.record(ProducerChange{
+		Type:           t,
+		OwnerPublicKey: producer.info.OwnerPublicKey,
+		Votes:          votes,
+		State:          producer.state,
+	})
+}
+
+// takeChangedProducers returns the producers whose votes or info have been
+// changed since last call and resets the records.
+func (s *State) takeChangedProducers() map[*Producer]struct{} {
+	s.mtx.Lock()
+	changed := s.changedProducers
+	s.changedProducers = make(map[*Producer]struct{})
+	s.mtx.Unlock()
+	return changed
 }
 
 // GetProducer returns a producer with the producer's node public key or it's
@@ -217,6 +580,22 @@ func (s *State) GetProducer(publicKey []byte) *Producer {
 	return producer
 }
 
+// GetProducerByAnyKey returns a producer by the given public key, which will be
+// treated as a node public key first and then an owner public key.  If no
+// matches return nil.
+func (s *State) GetProducerByAnyKey(publicKey []byte) *Producer {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	key := hex.EncodeToString(publicKey)
+	if owner, ok := s.nodeOwnerKeys[key]; ok {
+		if producer := s.getProducerByOwnerKey(owner); producer != nil {
+			return producer
+		}
+	}
+	return s.getProducerByOwnerKey(key)
+}
+
 // GetProducers returns all producers including pending and active producers (no
 // canceled and illegal producers).
 func (s *State) GetProducers() []*Producer {
@@ -233,6 +612,25 @@ func (s *State) GetProducers() []*Producer {
 	return producers
 }
 
+// GetOwnerNodeKeyMap returns the node public keys of pending, active,
+// inactive and jailed producers, keyed by owner public key, both in hex string
+// format.
+func (s *State) GetOwnerNodeKeyMap() map[string]string {
+	s.mtx.RLock()
+	keys := make(map[string]string, len(s.pendingProducers)+
+		len(s.activityProducers)+len(s.inactiveProducers)+
+		len(s.jailedProducers))
+	for _, producers := range []map[string]*Producer{s.pendingProducers,
+		s.activityProducers, s.inactiveProducers, s.jailedProducers} {
+		for _, producer := range producers {
+			keys[hex.EncodeToString(producer.info.OwnerPublicKey)] =
+				hex.EncodeToString(producer.info.NodePublicKey)
+		}
+	}
+	s.mtx.RUnlock()
+	return keys
+}
+
 func (s *State) getProducers() []*Producer {
 	producers := make([]*Producer, 0, len(s.activityProducers))
 	for _, producer := range s.activityProducers {
@@ -241,6 +639,50 @@ func (s *State) getProducers() []*Producer {
 	return producers
 }
 
+// GetProducersByState returns all producers in any of the given states, each
+// producer appears only once in the result.
+func (s *State) GetProducersByState(states ...ProducerState) []*Producer {
+	wanted := make(map[ProducerState]struct{}, len(states))
+	for _, state := range states {
+		wanted[state] = struct{}{}
+	}
+
+	s.mtx.RLock()
+	producers := make([]*Producer, 0)
+	seen := make(map[*Producer]struct{})
+	for _, set := range []map[string]*Producer{s.pendingProducers,
+		s.activityProducers, s.inactiveProducers, s.jailedProducers,
+		s.canceledProducers, s.illegalProducers} {
+		for _, producer := range set {
+			if _, ok := wanted[producer.state]; !ok {
+				continue
+			}
+			if _, ok := seen[producer]; ok {
+				continue
+			}
+			seen[producer] = struct{}{}
+			producers = append(producers, producer)
+		}
+	}
+	s.mtx.RUnlock()
+	return producers
+}
+
+// GetIllegalEvidence returns the illegal blocks evidence that caused the
+// producer's illegal status by the producer's node or owner public key.
+func (s *State) GetIllegalEvidence(
+	nodePublicKey []byte) (*payload.DPOSIllegalBlocks, bool) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	producer := s.getProducer(nodePublicKey)
+	if producer == nil || producer.state != FoundBad ||
+		producer.illegalEvidence == nil {
+		return nil, false
+	}
+	return producer.illegalEvidence, true
+}
+
 // GetPendingProducers returns all producers that in pending state.
 func (s *State) GetPendingProducers() []*Producer {
 	s.mtx.RLock()
@@ -252,6 +694,32 @@ func (s *State) GetPendingProducers() []*Producer {
 	return producers
 }
 
+// GetPromotableProducers returns pending producers that will be promoted to
+// active state when the block on the given height is processed.
+func (s *State) GetPromotableProducers(height uint32) []*Producer {
+	s.mtx.RLock()
+	producers := make([]*Producer, 0)
+	for _, producer := range s.pendingProducers {
+		if isPromotable(producer, height) {
+			producers = append(producers, producer)
+		}
+	}
+	s.mtx.RUnlock()
+	return producers
+}
+
+// promotionHeight returns the height a pending producer will be promoted to
+// active state.
+func promotionHeight(producer *Producer) uint32 {
+	return producer.registerHeight + activateConfirmations - 1
+}
+
+// isPromotable returns if the pending producer will be promoted on the given
+// height.
+func isPromotable(producer *Producer, height uint32) bool {
+	return height >= promotionHeight(producer)
+}
+
 // GetActiveProducers returns all producers that in active state.
 func (s *State) GetActiveProducers() []*Producer {
 	s.mtx.RLock()
@@ -263,6 +731,66 @@ func (s *State) GetActiveProducers() []*Producer {
 	return producers
 }
 
+// producerRanksBefore returns if producer x ranks before y, by votes in
+// descending order then by node public key.
+func producerRanksBefore(x, y *Producer) bool {
+	if x.votes != y.votes {
+		return x.votes > y.votes
+	}
+	return bytes.Compare(x.info.NodePublicKey, y.info.NodePublicKey) < 0
+}
+
+// producerHeap is a heap of producers with the lowest ranked one on top.
+type producerHeap []*Producer
+
+func (h producerHeap) Len() int { return len(h) }
+
+func (h producerHeap) Less(i, j int) bool {
+	return producerRanksBefore(h[j], h[i])
+}
+
+func (h producerHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *producerHeap) Push(x interface{}) { *h = append(*h, x.(*Producer)) }
+
+func (h *producerHeap) Pop() interface{} {
+	old := *h
+	p := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return p
+}
+
+// GetTopProducers returns copies of the top n active producers by votes in
+// descending order, producers with the same votes are ordered by node public
+// key.  A bounded heap is used so it's cheaper than sorting all producers.
+func (s *State) GetTopProducers(n int) []*Producer {
+	if n <= 0 {
+		return nil
+	}
+
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	h := make(producerHeap, 0, n)
+	for _, p := range s.activityProducers {
+		if len(h) < n {
+			heap.Push(&h, p)
+			continue
+		}
+		if producerRanksBefore(p, h[0]) {
+			h[0] = p
+			heap.Fix(&h, 0)
+		}
+	}
+
+	result := make([]*Producer, len(h))
+	for i := len(h) - 1; i >= 0; i-- {
+		producer := *heap.Pop(&h).(*Producer)
+		result[i] = &producer
+	}
+	return result
+}
+
 // GetCanceledProducers returns all producers that in cancel state.
 func (s *State) GetCanceledProducers() []*Producer {
 	s.mtx.RLock()
@@ -295,6 +823,103 @@ func (s *State) GetInactiveProducers() []*Producer {
 	return producers
 }
 
+// GetJailedProducers returns all producers that in jailed state.
+func (s *State) GetJailedProducers() []*Producer {
+	s.mtx.RLock()
+	producers := make([]*Producer, 0, len(s.jailedProducers))
+	for _, producer := range s.jailedProducers {
+		producers = append(producers, producer)
+	}
+	s.mtx.RUnlock()
+	return producers
+}
+
+// GetUndercollateralizedProducers returns the producers that have not been
+// canceled or found illegal, and whose deposit amount deducted by penalty is
+// below the minimum producer deposit.
+func (s *State) GetUndercollateralizedProducers() []*Producer {
+	s.mtx.RLock()
+	producers := make([]*Producer, 0)
+	for _, set := range []map[string]*Producer{s.pendingProducers,
+		s.activityProducers, s.inactiveProducers, s.jailedProducers} {
+		for _, producer := range set {
+			if producer.depositAmount-producer.penalty <
+				s.chainParams.MinProducerDeposit {
+				producers = append(producers, producer)
+			}
+		}
+	}
+	s.mtx.RUnlock()
+	return producers
+}
+
+// GetDepositLockupBlocks returns the blocks the deposit of the canceled
+// producer keeps locked before it can be returned.
+func (s *State) GetDepositLockupBlocks(producer *Producer) uint32 {
+	return s.depositLockupBlocks(producer)
+}
+
+// depositLockupBlocks returns the blocks the deposit of the canceled producer
+// keeps locked, CRCDepositLockupBlocks applies to producers canceled as CRC
+// arbiters from CRCDepositLockupHeight if set.
+func (s *State) depositLockupBlocks(producer *Producer) uint32 {
+	if producer.canceledAsCRC && s.chainParams.CRCDepositLockupBlocks > 0 &&
+		producer.cancelHeight >= s.chainParams.CRCDepositLockupHeight {
+		return s.chainParams.CRCDepositLockupBlocks
+	}
+	return s.chainParams.CRDepositLockupBlocks
+}
+
+// setCRCArbiters sets the CRC arbiters in use by their node public keys.
+func (s *State) setCRCArbiters(crcArbiters map[string]*Producer) {
+	keys := make(map[string]struct{}, len(crcArbiters))
+	for _, producer := range crcArbiters {
+		keys[hex.EncodeToString(producer.info.NodePublicKey)] = struct{}{}
+	}
+	s.mtx.Lock()
+	s.crcNodePublicKeys = keys
+	s.mtx.Unlock()
+}
+
+// isCRCArbiter returns if the node public key is of a CRC arbiter in use.
+func (s *State) isCRCArbiter(nodePublicKey []byte) bool {
+	_, ok := s.crcNodePublicKeys[hex.EncodeToString(nodePublicKey)]
+	return ok
+}
+
+// GetRefundableDeposits returns the deposits of canceled producers that have
+// passed the deposit lockup blocks on the given height.
+func (s *State) GetRefundableDeposits(height uint32) []DepositRefund {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	refunds := make([]DepositRefund, 0)
+	for _, producer := range s.canceledProducers {
+		if producer.state != Canceled || height < producer.cancelHeight+
+			s.depositLockupBlocks(producer) {
+			continue
+		}
+
+		programHash, err := contract.PublicKeyToStandardProgramHash(
+			producer.info.OwnerPublicKey)
+		if err != nil {
+			log.Warn("[GetRefundableDeposits] invalid owner public key")
+			continue
+		}
+
+		amount := producer.depositAmount - producer.penalty
+		if amount < 0 {
+			amount = 0
+		}
+		refunds = append(refunds, DepositRefund{
+			OwnerPublicKey:   producer.info.OwnerPublicKey,
+			OwnerProgramHash: *programHash,
+			Amount:           amount,
+		})
+	}
+	return refunds
+}
+
 // IsPendingProducer returns if a producer is in pending list according to the
 // public key.
 func (s *State) IsPendingProducer(publicKey []byte) bool {
@@ -345,12 +970,40 @@ func (s *State) IsIllegalProducer(publicKey []byte) bool {
 	return ok
 }
 
-// NicknameExists returns if a nickname is exists.
+// NicknameExists returns if a nickname is exists, or reserved by a canceled
+// or illegal producer.
 func (s *State) NicknameExists(nickname string) bool {
 	s.mtx.RLock()
-	_, ok := s.nicknames[nickname]
-	s.mtx.RUnlock()
-	return ok
+	defer s.mtx.RUnlock()
+
+	if _, ok := s.nicknames[nickname]; ok {
+		return true
+	}
+	return s.isNicknameReserved(nickname)
+}
+
+// isNicknameReserved returns if the nickname is reserved for the next block by
+// a producer canceled within NicknameReservationBlocks, or by an illegal
+// producer, from NicknameReserveHeight.
+func (s *State) isNicknameReserved(nickname string) bool {
+	blocks := s.chainParams.NicknameReservationBlocks
+	height := s.history.height + 1
+	if blocks == 0 || height < s.chainParams.NicknameReserveHeight {
+		return false
+	}
+
+	for _, producer := range s.illegalProducers {
+		if producer.info.NickName == nickname {
+			return true
+		}
+	}
+	for _, producer := range s.canceledProducers {
+		if producer.info.NickName == nickname &&
+			height < producer.cancelHeight+blocks {
+			return true
+		}
+	}
+	return false
 }
 
 // ProducerExists returns if a producer is exists by it's node public key or
@@ -362,6 +1015,48 @@ func (s *State) ProducerExists(publicKey []byte) bool {
 	return producer != nil
 }
 
+// GetVoteStats returns the votes statistics computed from all producers, the
+// top arbiters are the active producers with the most votes up to the general
+// arbiters count.
+func (s *State) GetVoteStats() VoteStats {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var stats VoteStats
+	active := make([]common.Fixed64, 0, len(s.activityProducers))
+	count := func(producers map[string]*Producer, isActive bool) {
+		for _, p := range producers {
+			stats.TotalVotes += p.votes
+			if p.votes > 0 {
+				stats.VotedProducers++
+			}
+			if isActive {
+				active = append(active, p.votes)
+			}
+		}
+	}
+	count(s.pendingProducers, false)
+	count(s.activityProducers, true)
+	count(s.inactiveProducers, false)
+	count(s.jailedProducers, false)
+	count(s.canceledProducers, false)
+	count(s.illegalProducers, false)
+
+	sort.Slice(active, func(i, j int) bool {
+		return active[i] > active[j]
+	})
+	for i := 0; i < len(active) && i < s.chainParams.GeneralArbiters; i++ {
+		stats.TopArbitersVotes += active[i]
+	}
+	if stats.TotalVotes > 0 {
+		stats.TopArbitersShare = float64(stats.TopArbitersVotes) /
+			float64(stats.TotalVotes)
+	}
+	stats.AbstainVotes = s.abstainVotes
+
+	return stats
+}
+
 // SpecialTxExists returns if a special tx (typically means illegal and
 // inactive tx) is exists by it's hash
 func (s *State) SpecialTxExists(hash *common.Uint256) bool {
@@ -371,12 +1066,11 @@ func (s *State) SpecialTxExists(hash *common.Uint256) bool {
 	return ok
 }
 
-// IsDPOSTransaction returns if a transaction will change the producers and
-// votes state.
-func (s *State) IsDPOSTransaction(tx *types.Transaction) bool {
-	s.mtx.RLock()
-	defer s.mtx.RUnlock()
-
+// IsDPOSTransactionType returns if a transaction will change the producers and
+// votes state by it's type and payload, no state is required.  Note that
+// transactions canceling votes can not be detected without state, use
+// State.IsDPOSTransaction instead if needed.
+func IsDPOSTransactionType(tx *types.Transaction) bool {
 	switch tx.TxType {
 	// Transactions will changes the producers state.
 	case types.RegisterProducer, types.UpdateProducer, types.CancelProducer,
@@ -399,6 +1093,19 @@ func (s *State) IsDPOSTransaction(tx *types.Transaction) bool {
 
 	}
 
+	return false
+}
+
+// IsDPOSTransaction returns if a transaction will change the producers and
+// votes state.
+func (s *State) IsDPOSTransaction(tx *types.Transaction) bool {
+	if IsDPOSTransactionType(tx) {
+		return true
+	}
+
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
 	// Cancel votes.
 	for _, input := range tx.Inputs {
 		_, ok := s.votes[input.ReferKey()]
@@ -411,16 +1118,48 @@ func (s *State) IsDPOSTransaction(tx *types.Transaction) bool {
 }
 
 // ProcessBlock takes a block and it's confirm to update producers state and
-// votes accordingly.
-func (s *State) ProcessBlock(block *types.Block, confirm *payload.Confirm) {
+// votes accordingly.  Transactions failed to be processed are skipped and
+// returned as an error, while the rest of the block is still processed.
+func (s *State) ProcessBlock(block *types.Block,
+	confirm *payload.Confirm) error {
+	s.processMtx.Lock()
 	s.mtx.Lock()
-	defer s.mtx.Unlock()
-
-	s.processTransactions(block.Transactions, block.Height)
+	if err := s.checkProcessHeight(block.Height); err != nil {
+		s.mtx.Unlock()
+		s.processMtx.Unlock()
+		return err
+	}
+	watched := s.getWatchedVotes()
+	err := s.processBlock(block, confirm)
+	notify := s.diffWatchedVotes(watched)
+	nearInactive := s.nearInactive
+	s.nearInactive = nil
+	s.mtx.Unlock()
+	s.processMtx.Unlock()
+
+	// Notify after unlocked, so the callbacks are free to process blocks or
+	// begin a snapshot.
+	notify(block.Height)
+	for _, n := range nearInactive {
+		events.Notify(events.ETArbiterNearInactive, n)
+	}
+	return err
+}
 
-	if confirm != nil {
-		s.countArbitratorsInactivity(block.Height, confirm)
+// checkProcessHeight returns error if the block on the given height has been
+// processed already, processing it again would apply changes twice.
+func (s *State) checkProcessHeight(height uint32) error {
+	if len(s.history.changes) > 0 && height <= s.history.height {
+		return fmt.Errorf("block height %d already processed, current"+
+			" height %d", height, s.history.height)
 	}
+	return nil
+}
+
+func (s *State) processBlock(block *types.Block,
+	confirm *payload.Confirm) error {
+	err := s.processTransactions(block.Transactions, block.Height)
+	s.countArbitratorsInactivity(block.Height, confirm)
 
 	// Take snapshot when snapshot point arrives.
 	if (block.Height-s.chainParams.VoteStartHeight)%snapshotInterval == 0 {
@@ -431,14 +1170,117 @@ func (s *State) ProcessBlock(block *types.Block, confirm *payload.Confirm) {
 
 	// Commit changes here if no errors found.
 	s.history.commit(block.Height)
+
+	if debugCheckInvariants {
+		if e := s.checkInvariants(); e != nil {
+			panic(fmt.Sprintf("state invariants broken on height %d, %s",
+				block.Height, e))
+		}
+	}
+	return err
+}
+
+// CheckInvariants verifies the internal consistency of the state, like no
+// producer in two mutually exclusive sets, and the nickname and node public
+// key indexes agree with producers.
+func (s *State) CheckInvariants() error {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.checkInvariants()
+}
+
+func (s *State) checkInvariants() error {
+	sets := []struct {
+		name      string
+		producers map[string]*Producer
+		states    []ProducerState
+		nicknames bool
+	}{
+		{"pending", s.pendingProducers, []ProducerState{Pending}, true},
+		{"active", s.activityProducers, []ProducerState{Activate}, true},
+		{"inactive", s.inactiveProducers, []ProducerState{Inactivate}, true},
+		{"jailed", s.jailedProducers, []ProducerState{Jailed}, true},
+		{"canceled", s.canceledProducers,
+			[]ProducerState{Canceled, ReturnedDeposit}, false},
+		{"illegal", s.illegalProducers, []ProducerState{FoundBad}, false},
+	}
+
+	total := 0
+	owners := make(map[string]string)
+	nicknames := make(map[string]struct{})
+	for _, set := range sets {
+		total += len(set.producers)
+		for key, producer := range set.producers {
+			if other, ok := owners[key]; ok {
+				return fmt.Errorf("producer %s in both %s and %s producers",
+					key, other, set.name)
+			}
+			owners[key] = set.name
+
+			ownerKey := hex.EncodeToString(producer.info.OwnerPublicKey)
+			if ownerKey != key {
+				return fmt.Errorf("%s producer %s indexed by %s",
+					set.name, ownerKey, key)
+			}
+
+			stateMatched := false
+			for _, state := range set.states {
+				if producer.state == state {
+					stateMatched = true
+					break
+				}
+			}
+			if !stateMatched {
+				return fmt.Errorf("%s producer %s in %s state", set.name,
+					key, producer.state)
+			}
+
+			nodeKey := hex.EncodeToString(producer.info.NodePublicKey)
+			if s.nodeOwnerKeys[nodeKey] != key {
+				return fmt.Errorf("node public key %s of producer %s"+
+					" indexed to %s", nodeKey, key, s.nodeOwnerKeys[nodeKey])
+			}
+
+			if set.nicknames {
+				nickname := producer.info.NickName
+				if _, ok := s.nicknames[nickname]; !ok {
+					return fmt.Errorf("nickname %s of producer %s not"+
+						" indexed", nickname, key)
+				}
+				nicknames[nickname] = struct{}{}
+			}
+		}
+	}
+
+	if len(owners) != total {
+		return fmt.Errorf("producers count %d not equal to sum of"+
+			" categorized producers %d", len(owners), total)
+	}
+	if len(nicknames) != len(s.nicknames) {
+		return fmt.Errorf("nicknames count %d not equal to producers"+
+			" nicknames count %d", len(s.nicknames), len(nicknames))
+	}
+	for nodeKey, ownerKey := range s.nodeOwnerKeys {
+		if _, ok := owners[ownerKey]; !ok {
+			return fmt.Errorf("node public key %s indexed to unknown"+
+				" producer %s", nodeKey, ownerKey)
+		}
+	}
+
+	return nil
 }
 
 // processTransactions takes the transactions and the height when they have been
 // packed into a block.  Then loop through the transactions to update producers
 // state and votes according to transactions content.
-func (s *State) processTransactions(txs []*types.Transaction, height uint32) {
+func (s *State) processTransactions(txs []*types.Transaction,
+	height uint32) error {
+	var errs []string
 	for _, tx := range txs {
-		s.processTransaction(tx, height)
+		if err := s.processTransaction(tx, height); err != nil {
+			errs = append(errs, fmt.Sprintf("transaction %s: %s",
+				tx.Hash().String(), err))
+		}
 	}
 
 	// Check if any pending producers has got 6 confirms, set them to activate.
@@ -447,10 +1289,13 @@ func (s *State) processTransactions(txs []*types.Transaction, height uint32) {
 			producer.state = Activate
 			s.activityProducers[key] = producer
 			delete(s.pendingProducers, key)
+			s.recordChange(ProducerStateChanged, producer, 0)
+			producer.addLifecycleEvent(LifecycleActivated, height)
 		}, func() {
 			producer.state = Pending
 			s.pendingProducers[key] = producer
 			delete(s.activityProducers, key)
+			producer.removeLifecycleEvent()
 		})
 	}
 
@@ -460,16 +1305,48 @@ func (s *State) processTransactions(txs []*types.Transaction, height uint32) {
 			producer.state = Activate
 			s.activityProducers[key] = producer
 			delete(s.inactiveProducers, key)
+			s.recordChange(ProducerStateChanged, producer, 0)
+			producer.addLifecycleEvent(LifecycleActivated, height)
 		}, func() {
 			producer.state = Inactivate
 			s.inactiveProducers[key] = producer
 			delete(s.activityProducers, key)
+			producer.removeLifecycleEvent()
+		})
+	}
+
+	// Clear the activate request not fulfilled in ActivateRequestExpiry blocks.
+	expireActivateRequest := func(producer *Producer) {
+		requestHeight := producer.activateRequestHeight
+		s.history.append(height, func() {
+			producer.activateRequestHeight = math.MaxUint32
+		}, func() {
+			producer.activateRequestHeight = requestHeight
+		})
+	}
+
+	// Release jailed producers when the jail height arrives.
+	releaseJailedProducer := func(key string, producer *Producer) {
+		jailUntilHeight := producer.jailUntilHeight
+		s.history.append(height, func() {
+			producer.state = Activate
+			producer.jailUntilHeight = 0
+			s.activityProducers[key] = producer
+			delete(s.jailedProducers, key)
+			s.recordChange(ProducerStateChanged, producer, 0)
+			producer.addLifecycleEvent(LifecycleActivated, height)
+		}, func() {
+			producer.state = Jailed
+			producer.jailUntilHeight = jailUntilHeight
+			s.jailedProducers[key] = producer
+			delete(s.activityProducers, key)
+			producer.removeLifecycleEvent()
 		})
 	}
 
 	if len(s.pendingProducers) > 0 {
 		for key, producer := range s.pendingProducers {
-			if height-producer.registerHeight+1 >= 6 {
+			if isPromotable(producer, height) {
 				activateProducerFromPending(key, producer)
 			}
 		}
@@ -477,36 +1354,84 @@ func (s *State) processTransactions(txs []*types.Transaction, height uint32) {
 	}
 	if len(s.inactiveProducers) > 0 {
 		for key, producer := range s.inactiveProducers {
+			if s.isActivateRequestExpired(producer, height) {
+				expireActivateRequest(producer)
+				continue
+			}
 			if height > producer.activateRequestHeight &&
-				height-producer.activateRequestHeight+1 >= 6 {
+				height-producer.activateRequestHeight+1 >=
+					activateConfirmations {
 				activateProducerFromInactive(key, producer)
 			}
 		}
 	}
+	if len(s.jailedProducers) > 0 {
+		for key, producer := range s.jailedProducers {
+			if height >= producer.jailUntilHeight {
+				releaseJailedProducer(key, producer)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("process transactions on height %d failed, %s",
+			height, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// isActivateRequestExpired returns if the activate request of the producer has
+// not been fulfilled in ActivateRequestExpiry blocks on the given height, the
+// expiry is at least activateConfirmations so a request can be fulfilled.
+func (s *State) isActivateRequestExpired(producer *Producer,
+	height uint32) bool {
+	expiry := s.chainParams.ActivateRequestExpiry
+	if expiry == 0 || height < s.chainParams.ActivateExpiryHeight {
+		return false
+	}
+	if expiry < activateConfirmations {
+		expiry = activateConfirmations
+	}
+	return producer.activateRequestHeight != math.MaxUint32 &&
+		height >= producer.activateRequestHeight+expiry
 }
 
 // processTransaction take a transaction and the height it has been packed into
 // a block, then update producers state and votes according to the transaction
 // content.
-func (s *State) processTransaction(tx *types.Transaction, height uint32) {
+func (s *State) processTransaction(tx *types.Transaction, height uint32) error {
+	var err error
 	switch tx.TxType {
 	case types.RegisterProducer:
-		s.registerProducer(tx.Payload.(*payload.ProducerInfo),
-			height)
+		err = s.registerProducer(tx, height)
 
 	case types.UpdateProducer:
-		s.updateProducer(tx.Payload.(*payload.ProducerInfo),
-			height)
+		info, ok := tx.Payload.(*payload.ProducerInfo)
+		if !ok {
+			err = invalidPayloadError(tx)
+			break
+		}
+		err = s.updateProducer(info, height)
 
 	case types.CancelProducer:
-		s.cancelProducer(tx.Payload.(*payload.ProcessProducer),
-			height)
+		p, ok := tx.Payload.(*payload.ProcessProducer)
+		if !ok {
+			err = invalidPayloadError(tx)
+			break
+		}
+		err = s.cancelProducer(p, height)
 
 	case types.ActivateProducer:
-		s.activateProducer(tx.Payload.(*payload.ProcessProducer), height)
+		p, ok := tx.Payload.(*payload.ProcessProducer)
+		if !ok {
+			err = invalidPayloadError(tx)
+			break
+		}
+		err = s.activateProducer(p, height)
 
 	case types.TransferAsset:
 		s.processVotes(tx, height)
+		s.processDepositTopUps(tx, height)
 
 	case types.IllegalProposalEvidence, types.IllegalVoteEvidence,
 		types.IllegalBlockEvidence, types.IllegalSidechainEvidence:
@@ -514,8 +1439,12 @@ func (s *State) processTransaction(tx *types.Transaction, height uint32) {
 		s.recordSpecialTx(tx, height)
 
 	case types.InactiveArbitrators:
-		s.processEmergencyInactiveArbitrators(
-			tx.Payload.(*payload.InactiveArbitrators), height)
+		p, ok := tx.Payload.(*payload.InactiveArbitrators)
+		if !ok {
+			err = invalidPayloadError(tx)
+			break
+		}
+		s.processEmergencyInactiveArbitrators(p, height)
 		s.recordSpecialTx(tx, height)
 
 	case types.ReturnDepositCoin:
@@ -523,72 +1452,280 @@ func (s *State) processTransaction(tx *types.Transaction, height uint32) {
 	}
 
 	s.processCancelVotes(tx, height)
+	return err
+}
+
+// invalidPayloadError returns the error of a transaction with payload not
+// matching it's type.
+func invalidPayloadError(tx *types.Transaction) error {
+	return fmt.Errorf("invalid payload type %T of %s transaction",
+		tx.Payload, tx.TxType.Name())
+}
+
+// CheckProducerInfo checks the nickname and url of the producer info by the
+// limits in chain parameters from ProducerInfoCheckHeight, the nickname should
+// be printable and the url should be a well-formed http(s) url.
+func CheckProducerInfo(params *config.Params, info *payload.ProducerInfo,
+	height uint32) error {
+	if height < params.ProducerInfoCheckHeight {
+		return nil
+	}
+	if limit := params.MaxProducerNickNameLength; limit > 0 {
+		if uint32(len(info.NickName)) > limit {
+			return fmt.Errorf("nickname length %d exceeds limit %d",
+				len(info.NickName), limit)
+		}
+		if !utf8.ValidString(info.NickName) {
+			return errors.New("nickname is not valid utf-8")
+		}
+		for _, r := range info.NickName {
+			if !unicode.IsPrint(r) {
+				return fmt.Errorf("nickname contains invalid character %q", r)
+			}
+		}
+	}
+
+	if limit := params.MaxProducerUrlLength; limit > 0 {
+		if uint32(len(info.Url)) > limit {
+			return fmt.Errorf("url length %d exceeds limit %d",
+				len(info.Url), limit)
+		}
+		u, err := url.Parse(info.Url)
+		if err != nil {
+			return fmt.Errorf("invalid url %s", info.Url)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("url %s is not a http(s) url", info.Url)
+		}
+	}
+	return nil
 }
 
 // registerProducer handles the register producer transaction.
-func (s *State) registerProducer(payload *payload.ProducerInfo, height uint32) {
+func (s *State) registerProducer(tx *types.Transaction, height uint32) error {
+	payload, ok := tx.Payload.(*payload.ProducerInfo)
+	if !ok {
+		return invalidPayloadError(tx)
+	}
+	if err := CheckProducerInfo(s.chainParams, payload, height); err != nil {
+		return err
+	}
+	// Registrations from blocklisted owners are dropped.
+	if IsProducerBlocklisted(s.chainParams, payload.OwnerPublicKey, height) {
+		return nil
+	}
 	nickname := payload.NickName
 	nodeKey := hex.EncodeToString(payload.NodePublicKey)
 	ownerKey := hex.EncodeToString(payload.OwnerPublicKey)
+	// Owners not in a valid public key format can not receive deposit.
+	depositHash, _ := contract.PublicKeyToDepositProgramHash(
+		payload.OwnerPublicKey)
 	producer := Producer{
 		info:                   *payload,
 		registerHeight:         height,
+		registerTxHash:         tx.Hash(),
 		votes:                  0,
 		inactiveSince:          0,
 		inactiveCountingHeight: 0,
 		penalty:                common.Fixed64(0),
 		activateRequestHeight:  math.MaxUint32,
+		depositAmount:          getDepositAmount(tx, payload.OwnerPublicKey),
+	}
+
+	s.history.append(height, func() {
+		s.nicknames[nickname] = struct{}{}
+		s.nodeOwnerKeys[nodeKey] = ownerKey
+		if depositHash != nil {
+			s.depositOwnerKeys[*depositHash] = ownerKey
+		}
+		s.pendingProducers[ownerKey] = &producer
+		s.changedProducers[&producer] = struct{}{}
+		s.recordChange(ProducerRegistered, &producer, 0)
+		producer.addLifecycleEvent(LifecycleRegistered, height)
+	}, func() {
+		delete(s.nicknames, nickname)
+		delete(s.nodeOwnerKeys, nodeKey)
+		if depositHash != nil {
+			delete(s.depositOwnerKeys, *depositHash)
+		}
+		delete(s.pendingProducers, ownerKey)
+		s.changedProducers[&producer] = struct{}{}
+		producer.removeLifecycleEvent()
+	})
+	return nil
+}
+
+// IsProducerBlocklisted returns if the owner public key is in
+// ProducerBlocklist on the given height.
+func IsProducerBlocklisted(params *config.Params, ownerPublicKey []byte,
+	height uint32) bool {
+	if height < params.ProducerBlocklistHeight {
+		return false
+	}
+	ownerKey := hex.EncodeToString(ownerPublicKey)
+	for _, key := range params.ProducerBlocklist {
+		if strings.EqualFold(key, ownerKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// getDepositAmount returns the amount of outputs to the deposit address of the
+// given owner public key.
+func getDepositAmount(tx *types.Transaction,
+	ownerPublicKey []byte) common.Fixed64 {
+	hash, err := contract.PublicKeyToDepositProgramHash(ownerPublicKey)
+	if err != nil {
+		return 0
+	}
+
+	var amount common.Fixed64
+	for _, output := range tx.Outputs {
+		if output.ProgramHash.IsEqual(*hash) {
+			amount += output.Value
+		}
 	}
+	return amount
+}
 
-	s.history.append(height, func() {
-		s.nicknames[nickname] = struct{}{}
-		s.nodeOwnerKeys[nodeKey] = ownerKey
-		s.pendingProducers[ownerKey] = &producer
-	}, func() {
-		delete(s.nicknames, nickname)
-		delete(s.nodeOwnerKeys, nodeKey)
-		delete(s.pendingProducers, ownerKey)
-	})
+// processDepositTopUps increases the deposit amount of producers by the
+// outputs to their deposit addresses after registration.
+func (s *State) processDepositTopUps(tx *types.Transaction, height uint32) {
+	for _, output := range tx.Outputs {
+		ownerKey, ok := s.depositOwnerKeys[output.ProgramHash]
+		if !ok {
+			continue
+		}
+		producer := s.getProducerByOwnerKey(ownerKey)
+		if producer == nil || producer.state == ReturnedDeposit {
+			continue
+		}
+		amount := output.Value
+		s.history.append(height, func() {
+			producer.depositAmount += amount
+		}, func() {
+			producer.depositAmount -= amount
+		})
+	}
 }
 
 // updateProducer handles the update producer transaction.
-func (s *State) updateProducer(info *payload.ProducerInfo, height uint32) {
-	producer := s.getProducer(info.OwnerPublicKey)
+func (s *State) updateProducer(info *payload.ProducerInfo,
+	height uint32) error {
+	// The owner public key is the identity of a producer and can not be
+	// changed, so the producer must be found by it's owner public key.
+	producer := s.getProducerByOwnerKey(hex.EncodeToString(info.OwnerPublicKey))
+	if producer == nil {
+		return fmt.Errorf("update unknown producer %s",
+			hex.EncodeToString(info.OwnerPublicKey))
+	}
+	if IsProducerBlocklisted(s.chainParams, info.OwnerPublicKey, height) {
+		return nil
+	}
+	if err := CheckProducerInfo(s.chainParams, info, height); err != nil {
+		return err
+	}
 	producerInfo := producer.info
 	s.history.append(height, func() {
 		s.updateProducerInfo(&producerInfo, info)
+		s.recordChange(ProducerUpdated, producer, 0)
+		producer.addLifecycleEvent(LifecycleUpdated, height)
 	}, func() {
 		s.updateProducerInfo(info, &producerInfo)
+		producer.removeLifecycleEvent()
 	})
+	return nil
 }
 
 // cancelProducer handles the cancel producer transaction.
-func (s *State) cancelProducer(payload *payload.ProcessProducer, height uint32) {
+func (s *State) cancelProducer(payload *payload.ProcessProducer,
+	height uint32) error {
 	key := hex.EncodeToString(payload.OwnerPublicKey)
 	producer := s.getProducer(payload.OwnerPublicKey)
+	if producer == nil {
+		return fmt.Errorf("cancel unknown producer %s", key)
+	}
+
+	// A pending producer is canceled without any penalty, and it's pending slot
+	// is freed immediately.
+	if _, ok := s.pendingProducers[key]; ok {
+		s.history.append(height, func() {
+			producer.state = Canceled
+			producer.cancelHeight = height
+			s.canceledProducers[key] = producer
+			delete(s.pendingProducers, key)
+			delete(s.nicknames, producer.info.NickName)
+			s.recordChange(ProducerCanceled, producer, 0)
+			producer.addLifecycleEvent(LifecycleCanceled, height)
+		}, func() {
+			producer.state = Pending
+			producer.cancelHeight = 0
+			delete(s.canceledProducers, key)
+			s.pendingProducers[key] = producer
+			s.nicknames[producer.info.NickName] = struct{}{}
+			producer.removeLifecycleEvent()
+		})
+		return nil
+	}
+
+	// A jailed producer is canceled from jail, so it will not be released
+	// into active producers later.
+	_, jailed := s.jailedProducers[key]
+	asCRC := s.isCRCArbiter(producer.info.NodePublicKey)
 	s.history.append(height, func() {
 		producer.state = Canceled
 		producer.cancelHeight = height
+		producer.canceledAsCRC = asCRC
 		s.canceledProducers[key] = producer
 		delete(s.activityProducers, key)
+		delete(s.jailedProducers, key)
 		delete(s.nicknames, producer.info.NickName)
+		s.recordChange(ProducerCanceled, producer, 0)
+		producer.addLifecycleEvent(LifecycleCanceled, height)
 	}, func() {
-		producer.state = Activate
 		producer.cancelHeight = 0
+		producer.canceledAsCRC = false
 		delete(s.canceledProducers, key)
-		s.activityProducers[key] = producer
+		if jailed {
+			producer.state = Jailed
+			s.jailedProducers[key] = producer
+		} else {
+			producer.state = Activate
+			s.activityProducers[key] = producer
+		}
 		s.nicknames[producer.info.NickName] = struct{}{}
+		producer.removeLifecycleEvent()
 	})
+	return nil
 }
 
 // activateProducer handles the activate producer transaction.
-func (s *State) activateProducer(p *payload.ProcessProducer, height uint32) {
+func (s *State) activateProducer(p *payload.ProcessProducer,
+	height uint32) error {
 	producer := s.getProducer(p.OwnerPublicKey)
+	if producer == nil {
+		return fmt.Errorf("activate unknown producer %s",
+			hex.EncodeToString(p.OwnerPublicKey))
+	}
+	if height >= s.chainParams.OwnerActivateHeight &&
+		!bytes.Equal(producer.info.OwnerPublicKey, p.OwnerPublicKey) {
+		return fmt.Errorf("activate producer %s by non owner public key",
+			hex.EncodeToString(producer.info.OwnerPublicKey))
+	}
+
+	// Only inactive producers can be activated.
+	if producer.state != Inactivate ||
+		IsProducerBlocklisted(s.chainParams, producer.info.OwnerPublicKey,
+			height) {
+		return nil
+	}
 	s.history.append(height, func() {
 		producer.activateRequestHeight = height
 	}, func() {
 		producer.activateRequestHeight = math.MaxUint32
 	})
+	return nil
 }
 
 // processVotes takes a transaction, if the transaction including any vote
@@ -596,55 +1733,159 @@ func (s *State) activateProducer(p *payload.ProcessProducer, height uint32) {
 func (s *State) processVotes(tx *types.Transaction, height uint32) {
 	if tx.Version >= types.TxVersion09 {
 		// Votes to producers.
+		var voters map[common.Uint168]struct{}
 		for i, output := range tx.Outputs {
 			if output.Type == types.OTVote {
+				if voters == nil {
+					voters = s.getVoters(tx, height)
+				}
 				op := types.NewOutPoint(tx.Hash(), uint16(i))
-				s.votes[op.ReferKey()] = output
-				s.processVoteOutput(output, height)
+				key := op.ReferKey()
+				credits, abstain := s.processVoteOutput(voters, output,
+					height)
+				v := &voteRecord{
+					outPoint: *op,
+					height:   height,
+					output:   output,
+					credits:  credits,
+					abstain:  abstain,
+				}
+				// Record the vote immediately so it can be canceled by
+				// transactions in the same block.
+				s.votes[key] = v
+				s.history.append(height, func() {
+					s.votes[key] = v
+				}, func() {
+					delete(s.votes, key)
+				})
 			}
 		}
 	}
 }
 
+// processCancelVotes takes a transaction and cancel the votes of each spent
+// vote output, other vote outputs of the same vote transaction keep unchanged.
 func (s *State) processCancelVotes(tx *types.Transaction, height uint32) {
 	for _, input := range tx.Inputs {
-		output, ok := s.votes[input.ReferKey()]
+		key := input.ReferKey()
+		v, ok := s.votes[key]
 		if ok {
-			s.processVoteCancel(output, height)
+			s.processVoteCancel(v, height)
+			s.history.append(height, func() {
+				delete(s.votes, key)
+			}, func() {
+				s.votes[key] = v
+			})
 		}
 	}
 }
 
-// processVoteOutput takes a transaction output with vote payload.
-func (s *State) processVoteOutput(output *types.Output, height uint32) {
-	payload := output.Payload.(*outputpayload.VoteOutput)
-	for _, vote := range payload.Contents {
-		for _, candidate := range vote.Candidates {
-			key := hex.EncodeToString(candidate)
-			producer := s.activityProducers[key]
-			switch vote.VoteType {
-			case outputpayload.CRC:
-				// TODO separate CRC and Delegate votes.
-				fallthrough
-			case outputpayload.Delegate:
-				s.history.append(height, func() {
-					producer.votes += output.Value
-				}, func() {
-					producer.votes -= output.Value
-				})
-			}
-		}
+// votableProducer returns the producer by the given owner public key if the
+// producer can receive votes, votes to unknown, canceled or illegal producers
+// will not be counted.
+func (s *State) votableProducer(ownerPublicKey []byte) *Producer {
+	key := hex.EncodeToString(ownerPublicKey)
+	if producer, ok := s.activityProducers[key]; ok {
+		return producer
+	}
+	if producer, ok := s.pendingProducers[key]; ok {
+		return producer
+	}
+	if producer, ok := s.inactiveProducers[key]; ok {
+		return producer
+	}
+	if producer, ok := s.jailedProducers[key]; ok {
+		return producer
+	}
+	return nil
+}
+
+// selfVotesLimited returns if self votes are limited by the max self vote
+// ratio on the given height.
+func (s *State) selfVotesLimited(height uint32) bool {
+	numerator := s.chainParams.MaxSelfVoteNumerator
+	return height >= s.chainParams.SelfVoteCapHeight && numerator > 0 &&
+		numerator < s.chainParams.MaxSelfVoteDenominator
+}
+
+// getVoters returns the program hashes of the outputs referenced by the vote
+// transaction's inputs, an empty set is returned if self votes are not
+// limited on the given height.
+func (s *State) getVoters(tx *types.Transaction,
+	height uint32) map[common.Uint168]struct{} {
+	voters := make(map[common.Uint168]struct{})
+	if !s.selfVotesLimited(height) || s.getTxReference == nil {
+		return voters
+	}
+	references, err := s.getTxReference(tx)
+	if err != nil {
+		log.Warn("[getVoters] get tx reference failed: ", err)
+		return voters
+	}
+	for _, output := range references {
+		voters[output.ProgramHash] = struct{}{}
+	}
+	return voters
+}
+
+// isSelfVote returns if the votes are funded by the producer's owner address.
+func isSelfVote(voters map[common.Uint168]struct{}, producer *Producer) bool {
+	if len(voters) == 0 {
+		return false
+	}
+	programHash, err := contract.PublicKeyToStandardProgramHash(
+		producer.info.OwnerPublicKey)
+	if err != nil {
+		return false
+	}
+	_, ok := voters[*programHash]
+	return ok
+}
+
+// selfVotesCredit returns the amount of self votes can be credited to the
+// producer without exceeding the max self vote ratio of the producer's votes.
+func (s *State) selfVotesCredit(producer *Producer,
+	amount common.Fixed64) common.Fixed64 {
+	numerator := big.NewInt(int64(s.chainParams.MaxSelfVoteNumerator))
+	denominator := big.NewInt(int64(s.chainParams.MaxSelfVoteDenominator))
+
+	// Credited amount c satisfies
+	// (selfVotes + c) * denominator <= (votes + c) * numerator.
+	limit := new(big.Int).Mul(numerator, big.NewInt(int64(producer.votes)))
+	limit.Sub(limit, new(big.Int).Mul(denominator,
+		big.NewInt(int64(producer.selfVotes))))
+	if limit.Sign() <= 0 {
+		return 0
+	}
+	limit.Quo(limit, new(big.Int).Sub(denominator, numerator))
+	if limit.Cmp(big.NewInt(int64(amount))) < 0 {
+		return common.Fixed64(limit.Int64())
 	}
+	return amount
 }
 
-// processVoteCancel takes a previous vote output and decrease producers votes.
-func (s *State) processVoteCancel(output *types.Output, height uint32) {
+// processVoteOutput takes a transaction output with vote payload, and returns
+// the votes credited to producers and if the votes have been counted as
+// abstain votes, voters are the program hashes funding the votes.
+func (s *State) processVoteOutput(voters map[common.Uint168]struct{},
+	output *types.Output, height uint32) (credits []*votesCredit,
+	abstain bool) {
 	payload := output.Payload.(*outputpayload.VoteOutput)
 	for _, vote := range payload.Contents {
+		if vote.VoteType == outputpayload.Abstain {
+			s.history.append(height, func() {
+				s.abstainVotes += output.Value
+			}, func() {
+				s.abstainVotes -= output.Value
+			})
+			abstain = true
+			continue
+		}
 		for _, candidate := range vote.Candidates {
-			producer := s.getProducer(candidate)
+			producer := s.votableProducer(candidate)
 			if producer == nil {
-				// This should not happen, just in case.
+				log.Debugf("[processVoteOutput] drop votes to unknown"+
+					" producer %s", hex.EncodeToString(candidate))
 				continue
 			}
 			switch vote.VoteType {
@@ -652,14 +1893,144 @@ func (s *State) processVoteCancel(output *types.Output, height uint32) {
 				// TODO separate CRC and Delegate votes.
 				fallthrough
 			case outputpayload.Delegate:
+				credit := &votesCredit{
+					producer: producer,
+					self:     isSelfVote(voters, producer),
+				}
+				s.history.append(height, func() {
+					credit.amount = output.Value
+					if credit.self {
+						credit.amount = s.selfVotesCredit(producer,
+							output.Value)
+						producer.selfVotes += credit.amount
+					}
+					producer.votes += credit.amount
+					producer.addVoteChangeHeight(height,
+						s.history.capacity+1)
+					s.changedProducers[producer] = struct{}{}
+					s.recordChange(ProducerVotesChanged, producer,
+						credit.amount)
+				}, func() {
+					if credit.self {
+						producer.selfVotes -= credit.amount
+					}
+					producer.votes -= credit.amount
+					producer.removeVoteChangeHeight(height)
+					s.changedProducers[producer] = struct{}{}
+				})
+				credits = append(credits, credit)
+
+			case outputpayload.Candidate:
+				credit := &votesCredit{
+					producer:  producer,
+					amount:    output.Value,
+					candidate: true,
+				}
 				s.history.append(height, func() {
-					producer.votes -= output.Value
+					producer.candidateVotes += credit.amount
 				}, func() {
-					producer.votes += output.Value
+					producer.candidateVotes -= credit.amount
 				})
+				credits = append(credits, credit)
+			}
+		}
+	}
+	return credits, abstain
+}
+
+// processVoteCancel takes a previous vote and decrease votes of the producers
+// it has been credited to.
+func (s *State) processVoteCancel(v *voteRecord, height uint32) {
+	for _, credit := range v.credits {
+		credit := credit
+		producer := credit.producer
+		if credit.candidate {
+			s.history.append(height, func() {
+				producer.candidateVotes -= credit.amount
+			}, func() {
+				producer.candidateVotes += credit.amount
+			})
+			continue
+		}
+		s.history.append(height, func() {
+			if credit.self {
+				producer.selfVotes -= credit.amount
+			}
+			producer.votes -= credit.amount
+			producer.addVoteChangeHeight(height, s.history.capacity+1)
+			s.changedProducers[producer] = struct{}{}
+			s.recordChange(ProducerVotesChanged, producer, -credit.amount)
+		}, func() {
+			if credit.self {
+				producer.selfVotes += credit.amount
+			}
+			producer.votes += credit.amount
+			producer.removeVoteChangeHeight(height)
+			s.changedProducers[producer] = struct{}{}
+		})
+	}
+	value := v.output.Value
+	if v.abstain {
+		s.history.append(height, func() {
+			s.abstainVotes -= value
+		}, func() {
+			s.abstainVotes += value
+		})
+	}
+}
+
+// GetProducerVoteSources returns the outstanding vote outputs crediting the
+// producer's votes by the producer's node or owner public key, ordered by
+// height then outpoint.
+func (s *State) GetProducerVoteSources(publicKey []byte) []VoteSource {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	producer := s.getProducer(publicKey)
+	if producer == nil {
+		return nil
+	}
+	var sources []VoteSource
+	for _, v := range s.votes {
+		for _, credit := range v.credits {
+			if credit.producer != producer || credit.candidate {
+				continue
 			}
+			sources = append(sources, VoteSource{
+				OutPoint: v.outPoint,
+				Amount:   credit.amount,
+				Height:   v.height,
+			})
 		}
 	}
+	sort.Slice(sources, func(i, j int) bool {
+		if sources[i].Height != sources[j].Height {
+			return sources[i].Height < sources[j].Height
+		}
+		return sources[i].OutPoint.ReferKey() < sources[j].OutPoint.ReferKey()
+	})
+	return sources
+}
+
+// GetActiveVotingPower returns the sum of votes of active producers, votes of
+// pending, inactive, jailed, canceled and illegal producers are excluded.
+func (s *State) GetActiveVotingPower() common.Fixed64 {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var votes common.Fixed64
+	for _, producer := range s.activityProducers {
+		votes += producer.votes
+	}
+	return votes
+}
+
+// GetAbstainVotes returns the sum of abstain votes.
+func (s *State) GetAbstainVotes() common.Fixed64 {
+	s.mtx.RLock()
+	abstainVotes := s.abstainVotes
+	s.mtx.RUnlock()
+	return abstainVotes
 }
 
 func (s *State) returnDeposit(tx *types.Transaction, height uint32) {
@@ -667,8 +2038,11 @@ func (s *State) returnDeposit(tx *types.Transaction, height uint32) {
 	returnAction := func(producer *Producer) {
 		s.history.append(height, func() {
 			producer.state = ReturnedDeposit
+			s.recordChange(ProducerStateChanged, producer, 0)
+			producer.addLifecycleEvent(LifecycleReturnedDeposit, height)
 		}, func() {
 			producer.state = Canceled
+			producer.removeLifecycleEvent()
 		})
 	}
 
@@ -711,18 +2085,15 @@ func (s *State) recordSpecialTx(tx *types.Transaction, height uint32) {
 	})
 }
 
-// processIllegalEvidence takes the illegal evidence payload and change producer
-// state according to the evidence.
-func (s *State) processIllegalEvidence(payloadData types.Payload,
-	height uint32) {
-	// Get illegal producers from evidence.
-	var illegalProducers [][]byte
-	switch p := payloadData.(type) {
+// getIllegalProducers returns the public keys of producers found doing bad by
+// the illegal evidence payload.
+func getIllegalProducers(illegalData payload.DPOSIllegalData) [][]byte {
+	switch p := illegalData.(type) {
 	case *payload.DPOSIllegalProposals:
-		illegalProducers = [][]byte{p.Evidence.Proposal.Sponsor}
+		return [][]byte{p.Evidence.Proposal.Sponsor}
 
 	case *payload.DPOSIllegalVotes:
-		illegalProducers = [][]byte{p.Evidence.Vote.Signer}
+		return [][]byte{p.Evidence.Vote.Signer}
 
 	case *payload.DPOSIllegalBlocks:
 		signers := make(map[string]interface{})
@@ -730,19 +2101,108 @@ func (s *State) processIllegalEvidence(payloadData types.Payload,
 			signers[hex.EncodeToString(pk)] = nil
 		}
 
+		var illegalProducers [][]byte
 		for _, pk := range p.CompareEvidence.Signers {
 			key := hex.EncodeToString(pk)
 			if _, ok := signers[key]; ok {
 				illegalProducers = append(illegalProducers, pk)
 			}
 		}
+		return illegalProducers
 
 	case *payload.SidechainIllegalData:
-		illegalProducers = [][]byte{p.IllegalSigner}
+		return [][]byte{p.IllegalSigner}
+	}
+	return nil
+}
+
+// recordIllegalPayload keeps the illegal evidence payload for conflicting
+// evidence detection.
+func (s *State) recordIllegalPayload(p payload.DPOSIllegalData,
+	height uint32) {
+	hash := p.Hash()
+	if _, ok := s.illegalPayloads[hash]; ok {
+		return
+	}
+	s.history.append(height, func() {
+		s.illegalPayloads[hash] = p
+	}, func() {
+		delete(s.illegalPayloads, hash)
+	})
+}
+
+// DetectConflictingEvidence scans the processed illegal evidence payloads and
+// reports producers accused on the same height by different evidences of the
+// same type.
+func (s *State) DetectConflictingEvidence() []ConflictReport {
+	type accusation struct {
+		illegalType payload.IllegalDataType
+		signer      string
+		height      uint32
+	}
+
+	s.mtx.RLock()
+	accusations := make(map[accusation][]common.Uint256)
+	for hash, p := range s.illegalPayloads {
+		for _, pk := range getIllegalProducers(p) {
+			key := accusation{p.Type(), hex.EncodeToString(pk),
+				p.GetBlockHeight()}
+			accusations[key] = append(accusations[key], hash)
+		}
+	}
+	s.mtx.RUnlock()
+
+	reports := make([]ConflictReport, 0)
+	for key, hashes := range accusations {
+		if len(hashes) < 2 {
+			continue
+		}
+		sort.Slice(hashes, func(i, j int) bool {
+			return bytes.Compare(hashes[i][:], hashes[j][:]) < 0
+		})
+		signer, _ := hex.DecodeString(key.signer)
+		reports = append(reports, ConflictReport{
+			Type:          key.illegalType,
+			Signer:        signer,
+			Height:        key.height,
+			PayloadHashes: hashes,
+		})
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].Height != reports[j].Height {
+			return reports[i].Height < reports[j].Height
+		}
+		if reports[i].Type != reports[j].Type {
+			return reports[i].Type < reports[j].Type
+		}
+		return bytes.Compare(reports[i].Signer, reports[j].Signer) < 0
+	})
+	return reports
+}
+
+// processIllegalEvidence takes the illegal evidence payload and change producer
+// state according to the evidence.
+func (s *State) processIllegalEvidence(payloadData types.Payload,
+	height uint32) {
+	illegalData, ok := payloadData.(payload.DPOSIllegalData)
+	if !ok {
+		return
+	}
 
-	default:
+	// Get illegal producers from evidence.
+	illegalProducers := getIllegalProducers(illegalData)
+	if illegalProducers == nil {
 		return
 	}
+	s.recordIllegalPayload(illegalData, height)
+
+	// Keep the illegal blocks evidence for the producers found doing bad.
+	evidence, _ := payloadData.(*payload.DPOSIllegalBlocks)
+
+	var penalty common.Fixed64
+	if height >= s.chainParams.IllegalPenaltyHeight {
+		penalty = s.chainParams.IllegalPenalty
+	}
 
 	// Set illegal producers to FoundBad state
 	for _, pk := range illegalProducers {
@@ -751,12 +2211,19 @@ func (s *State) processIllegalEvidence(payloadData types.Payload,
 			s.history.append(height, func() {
 				producer.state = FoundBad
 				producer.illegalHeight = height
+				producer.illegalEvidence = evidence
+				producer.penalty += penalty
 				s.illegalProducers[key] = producer
 				delete(s.activityProducers, key)
 				delete(s.nicknames, producer.info.NickName)
+				s.recordChange(ProducerStateChanged, producer, 0)
+				producer.addLifecycleEvent(LifecycleIllegal, height)
 			}, func() {
+				producer.removeLifecycleEvent()
 				producer.state = Activate
 				producer.illegalHeight = 0
+				producer.illegalEvidence = nil
+				producer.penalty -= penalty
 				s.activityProducers[key] = producer
 				delete(s.illegalProducers, key)
 				s.nicknames[producer.info.NickName] = struct{}{}
@@ -768,12 +2235,19 @@ func (s *State) processIllegalEvidence(payloadData types.Payload,
 			s.history.append(height, func() {
 				producer.state = FoundBad
 				producer.illegalHeight = height
+				producer.illegalEvidence = evidence
+				producer.penalty += penalty
 				s.illegalProducers[key] = producer
 				delete(s.canceledProducers, key)
 				delete(s.nicknames, producer.info.NickName)
+				s.recordChange(ProducerStateChanged, producer, 0)
+				producer.addLifecycleEvent(LifecycleIllegal, height)
 			}, func() {
+				producer.removeLifecycleEvent()
 				producer.state = Canceled
 				producer.illegalHeight = 0
+				producer.illegalEvidence = nil
+				producer.penalty -= penalty
 				s.canceledProducers[key] = producer
 				delete(s.illegalProducers, key)
 				s.nicknames[producer.info.NickName] = struct{}{}
@@ -787,6 +2261,9 @@ func (s *State) processIllegalEvidence(payloadData types.Payload,
 // producers state immediately.  This is a spacial case that can be handled
 // before it packed into a block.
 func (s *State) ProcessSpecialTxPayload(p types.Payload) {
+	s.processMtx.Lock()
+	defer s.processMtx.Unlock()
+
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
 
@@ -800,24 +2277,47 @@ func (s *State) ProcessSpecialTxPayload(p types.Payload) {
 	s.history.commit(0)
 }
 
-// setInactiveProducer set active producer to inactive state
+// setInactiveProducer set active producer to inactive state, or to jailed
+// state if it has been inactive for JailInactiveCount times from JailHeight.
 func (s *State) setInactiveProducer(producer *Producer, key string,
 	height uint32) {
 	producer.inactiveSince = height
-	producer.state = Inactivate
-	s.inactiveProducers[key] = producer
+	producer.inactiveCount++
 	delete(s.activityProducers, key)
 
+	if height >= s.chainParams.JailHeight &&
+		s.chainParams.JailInactiveCount > 0 &&
+		producer.inactiveCount >= s.chainParams.JailInactiveCount {
+		producer.state = Jailed
+		producer.jailUntilHeight = height + s.chainParams.JailBlocks
+		producer.inactiveCount = 0
+		s.jailedProducers[key] = producer
+		producer.addLifecycleEvent(LifecycleJailed, height)
+	} else {
+		producer.state = Inactivate
+		s.inactiveProducers[key] = producer
+		producer.addLifecycleEvent(LifecycleInactive, height)
+	}
+
 	producer.penalty += s.chainParams.InactivePenalty
+	s.recordChange(ProducerStateChanged, producer, 0)
 }
 
 // revertSettingInactiveProducer revert operation about setInactiveProducer
 func (s *State) revertSettingInactiveProducer(producer *Producer, key string,
 	height uint32) {
+	producer.removeLifecycleEvent()
+	if producer.state == Jailed {
+		producer.jailUntilHeight = 0
+		producer.inactiveCount = s.chainParams.JailInactiveCount
+		delete(s.jailedProducers, key)
+	} else {
+		delete(s.inactiveProducers, key)
+	}
+	producer.inactiveCount--
 	producer.inactiveSince = 0
 	producer.state = Activate
 	s.activityProducers[key] = producer
-	delete(s.inactiveProducers, key)
 
 	if producer.penalty < s.chainParams.InactivePenalty {
 		producer.penalty = common.Fixed64(0)
@@ -835,14 +2335,25 @@ func (s *State) countArbitratorsInactivity(height uint32,
 		return
 	}
 
+	// a block without confirm or votes (such as blocks reorganized from other
+	// nodes) has no signing evidence, so skip counting inactivity.
+	if confirm == nil || len(confirm.Votes) == 0 {
+		return
+	}
+
+	currentArbiters := s.getArbiters()
 	arbiters := make(map[string]bool)
-	for _, a := range s.getArbiters() {
+	for _, a := range currentArbiters {
 		arbiters[common.BytesToHexString(a)] = false
 	}
 	for _, v := range confirm.Votes {
 		arbiters[common.BytesToHexString(v.Signer)] = true
 	}
 
+	// signings missed during a network wide outage could not be made anyway,
+	// so the counting start moves along with the blocks and the counting
+	// resumes where it paused once the network recovers.
+	degraded := s.isNetworkDegraded(height, len(currentArbiters))
 	for k, v := range arbiters {
 		buf, _ := common.HexStringToBytes(k)
 		key := s.getProducerKey(buf)
@@ -853,15 +2364,37 @@ func (s *State) countArbitratorsInactivity(height uint32,
 			countingHeight := producer.inactiveCountingHeight
 			signed := v
 
+			if degraded && !signed {
+				s.history.append(height, func() {
+					if producer.inactiveCountingHeight != 0 {
+						producer.inactiveCountingHeight++
+					}
+				}, func() {
+					producer.inactiveCountingHeight = countingHeight
+				})
+				continue
+			}
+
+			warned := producer.inactiveWarned
 			s.history.append(height, func() {
 				s.tryUpdateInactivity(key, producer, signed, height)
 			}, func() {
 				s.tryRevertInactivity(key, producer, signed, height, countingHeight)
+				producer.inactiveWarned = warned
 			})
 		}
 	}
 }
 
+// isNetworkDegraded returns if the DPOS network is degraded on the given
+// height, that is the current arbiters are fewer than the general arbiters
+// plus the CRC arbiters.
+func (s *State) isNetworkDegraded(height uint32, arbitersCount int) bool {
+	return height >= s.chainParams.InactivityPauseHeight &&
+		arbitersCount < s.chainParams.GeneralArbiters+
+			len(s.chainParams.CRCArbiters)
+}
+
 func (s *State) tryRevertInactivity(key string, producer *Producer,
 	signed bool, height, startHeight uint32) {
 	if signed {
@@ -873,7 +2406,7 @@ func (s *State) tryRevertInactivity(key string, producer *Producer,
 		producer.inactiveCountingHeight = 0
 	}
 
-	if producer.state == Inactivate {
+	if producer.state == Inactivate || producer.state == Jailed {
 		s.revertSettingInactiveProducer(producer, key, height)
 		producer.inactiveCountingHeight = startHeight
 	}
@@ -888,20 +2421,172 @@ func (s *State) tryUpdateInactivity(key string, producer *Producer,
 
 	if producer.inactiveCountingHeight == 0 {
 		producer.inactiveCountingHeight = height
+		producer.inactiveWarned = false
 	}
 
-	if height-producer.inactiveCountingHeight > s.chainParams.MaxInactiveRounds {
+	missCount := height - producer.inactiveCountingHeight
+	if missCount > s.chainParams.MaxInactiveRounds {
 		s.setInactiveProducer(producer, key, height)
 		producer.inactiveCountingHeight = 0
+		return
+	}
+
+	s.tryWarnNearInactive(producer, missCount)
+}
+
+// tryWarnNearInactive queues a near inactive warning of the producer once
+// the missed signings reach "NearInactiveRatio" of "MaxInactiveRounds".
+func (s *State) tryWarnNearInactive(producer *Producer, missCount uint32) {
+	ratio := s.chainParams.NearInactiveRatio
+	if ratio <= 0 || producer.inactiveWarned {
+		return
+	}
+	if missCount < uint32(float64(s.chainParams.MaxInactiveRounds)*ratio) {
+		return
+	}
+	producer.inactiveWarned = true
+
+	// only warn on first time processing, not on replaying history.
+	if s.history.committing == nil {
+		return
 	}
+	s.nearInactive = append(s.nearInactive, &NearInactiveArbiter{
+		NodePublicKey: producer.info.NodePublicKey,
+		MissCount:     missCount,
+	})
 }
 
 // RollbackTo restores the database state to the given height, if no enough
 // history to rollback to return error.
 func (s *State) RollbackTo(height uint32) error {
+	s.processMtx.Lock()
+	notify, err := s.rollbackTo(height)
+	s.processMtx.Unlock()
+
+	notify(height)
+	return err
+}
+
+// rollbackTo restores the database state to the given height with processMtx
+// held by the caller, the returned function notifies the votes watchers and
+// should be called after processMtx released.
+func (s *State) rollbackTo(height uint32) (func(height uint32), error) {
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
-	return s.history.rollbackTo(height)
+
+	watched := s.getWatchedVotes()
+	err := s.history.rollbackTo(height)
+	return s.diffWatchedVotes(watched), err
+}
+
+// RevertLastBlock restores the state to the height before the last processed
+// block, if there is no history of the last block return error.
+func (s *State) RevertLastBlock() error {
+	s.processMtx.Lock()
+	s.mtx.RLock()
+	height, err := s.lastBlockHeight()
+	s.mtx.RUnlock()
+	if err != nil {
+		s.processMtx.Unlock()
+		return err
+	}
+	notify, err := s.rollbackTo(height - 1)
+	s.processMtx.Unlock()
+
+	notify(height - 1)
+	return err
+}
+
+// lastBlockHeight returns the height of the last processed block that can be
+// reverted.
+func (s *State) lastBlockHeight() (uint32, error) {
+	if len(s.history.changes) == 0 {
+		return 0, errors.New("no processed block to revert")
+	}
+	return s.history.height, nil
+}
+
+// BeginSnapshot freezes state mutations like processing blocks and rollbacks
+// until the returned release function is called, so a long serialization sees
+// a consistent view. Mutations during the freeze block until released, reads
+// are not affected.
+//
+// Only the producers state held by State is frozen. The arbiter sets, duty
+// index and rewards kept by arbitrators are updated after the producers state
+// of the same block, so they may lag behind by the block in process when the
+// snapshot begins, and are changed directly by IncreaseChainHeight and
+// DecreaseChainHeight during the freeze.
+func (s *State) BeginSnapshot() (release func()) {
+	s.processMtx.Lock()
+	var once sync.Once
+	return func() {
+		once.Do(s.processMtx.Unlock)
+	}
+}
+
+// votesWatcher holds a callback observing votes of a producer.
+type votesWatcher struct {
+	publicKey []byte
+	callback  func(height uint32, votes common.Fixed64)
+}
+
+// WatchProducerVotes registers a callback invoked with the new votes of the
+// producer, by node public key or owner public key, whenever a processed or
+// rollbacked block changes it. The returned function removes the watch.
+func (s *State) WatchProducerVotes(publicKey []byte,
+	callback func(height uint32, votes common.Fixed64)) func() {
+	watcher := &votesWatcher{publicKey: publicKey, callback: callback}
+	s.mtx.Lock()
+	s.votesWatchers[watcher] = struct{}{}
+	s.mtx.Unlock()
+
+	return func() {
+		s.mtx.Lock()
+		delete(s.votesWatchers, watcher)
+		s.mtx.Unlock()
+	}
+}
+
+// getWatchedVotes returns the current votes of each watched producer.
+func (s *State) getWatchedVotes() map[*votesWatcher]common.Fixed64 {
+	votes := make(map[*votesWatcher]common.Fixed64, len(s.votesWatchers))
+	for watcher := range s.votesWatchers {
+		if producer := s.getProducer(watcher.publicKey); producer != nil {
+			votes[watcher] = producer.votes
+		} else {
+			votes[watcher] = 0
+		}
+	}
+	return votes
+}
+
+// diffWatchedVotes compares the current votes of watched producers with the
+// given votes, and returns a function to notify the changed ones. The
+// returned function should be called without holding the lock.
+func (s *State) diffWatchedVotes(
+	previous map[*votesWatcher]common.Fixed64) func(height uint32) {
+	var watchers []*votesWatcher
+	var votes []common.Fixed64
+	for watcher, current := range s.getWatchedVotes() {
+		if prev, ok := previous[watcher]; ok && prev != current {
+			watchers = append(watchers, watcher)
+			votes = append(votes, current)
+		}
+	}
+	return func(height uint32) {
+		for i, watcher := range watchers {
+			watcher.callback(height, votes[i])
+		}
+	}
+}
+
+// GetHistoryDiff returns the producer changes happened after fromHeight until
+// toHeight in the order they happened.
+func (s *State) GetHistoryDiff(fromHeight, toHeight uint32) ([]ProducerChange,
+	error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.history.diff(fromHeight, toHeight)
 }
 
 // GetHistory returns a history state instance storing the producers and votes
@@ -925,12 +2610,14 @@ func (s *State) snapshot() *State {
 		pendingProducers:  make(map[string]*Producer),
 		activityProducers: make(map[string]*Producer),
 		inactiveProducers: make(map[string]*Producer),
+		jailedProducers:   make(map[string]*Producer),
 		canceledProducers: make(map[string]*Producer),
 		illegalProducers:  make(map[string]*Producer),
 	}
 	copyMap(state.pendingProducers, s.pendingProducers)
 	copyMap(state.activityProducers, s.activityProducers)
 	copyMap(state.inactiveProducers, s.inactiveProducers)
+	copyMap(state.jailedProducers, s.jailedProducers)
 	copyMap(state.canceledProducers, s.canceledProducers)
 	copyMap(state.illegalProducers, s.illegalProducers)
 	return &state
@@ -955,6 +2642,10 @@ func copyMap(dst map[string]*Producer, src map[string]*Producer) {
 
 // NewState returns a new State instance.
 func NewState(chainParams *config.Params, getArbiters func() [][]byte) *State {
+	capacity := chainParams.StateHistoryCapacity
+	if capacity <= 0 {
+		capacity = maxHistoryCapacity
+	}
 	return &State{
 		chainParams:       chainParams,
 		getArbiters:       getArbiters,
@@ -962,11 +2653,16 @@ func NewState(chainParams *config.Params, getArbiters func() [][]byte) *State {
 		pendingProducers:  make(map[string]*Producer),
 		activityProducers: make(map[string]*Producer),
 		inactiveProducers: make(map[string]*Producer),
+		jailedProducers:   make(map[string]*Producer),
 		canceledProducers: make(map[string]*Producer),
 		illegalProducers:  make(map[string]*Producer),
-		votes:             make(map[string]*types.Output),
+		votes:             make(map[string]*voteRecord),
 		nicknames:         make(map[string]struct{}),
 		specialTxHashes:   make(map[string]struct{}),
-		history:           newHistory(maxHistoryCapacity),
+		history:           newHistory(capacity),
+		depositOwnerKeys:  make(map[common.Uint168]string),
+		illegalPayloads:   make(map[common.Uint256]payload.DPOSIllegalData),
+		changedProducers:  make(map[*Producer]struct{}),
+		votesWatchers:     make(map[*votesWatcher]struct{}),
 	}
 }
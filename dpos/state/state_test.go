@@ -2,14 +2,20 @@ package state
 
 import (
 	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"math"
+	"strings"
 	"testing"
 
 	"github.com/elastos/Elastos.ELA/common"
 	"github.com/elastos/Elastos.ELA/common/config"
+	"github.com/elastos/Elastos.ELA/core/contract"
 	"github.com/elastos/Elastos.ELA/core/types"
 	"github.com/elastos/Elastos.ELA/core/types/outputpayload"
 	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/crypto"
+	"github.com/elastos/Elastos.ELA/events"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -82,7 +88,34 @@ func mockVoteTx(publicKeys [][]byte) *types.Transaction {
 	}
 }
 
-// mockVoteTx creates a cancel vote transaction with the previous vote
+// mockMultiVoteTx creates a vote transaction with one vote output for each
+// of the producers public keys.
+func mockMultiVoteTx(publicKeys [][]byte) *types.Transaction {
+	outputs := make([]*types.Output, len(publicKeys))
+	for i, pk := range publicKeys {
+		outputs[i] = &types.Output{
+			Value: common.Fixed64(100 * (i + 1)),
+			Type:  types.OTVote,
+			Payload: &outputpayload.VoteOutput{
+				Version: 0,
+				Contents: []outputpayload.VoteContent{
+					{
+						VoteType:   outputpayload.Delegate,
+						Candidates: [][]byte{pk},
+					},
+				},
+			},
+		}
+	}
+
+	return &types.Transaction{
+		Version: types.TxVersion09,
+		TxType:  types.TransferAsset,
+		Outputs: outputs,
+	}
+}
+
+// mockCancelVoteTx creates a cancel vote transaction with the previous vote
 // transaction.
 func mockCancelVoteTx(tx *types.Transaction) *types.Transaction {
 	inputs := make([]*types.Input, len(tx.Outputs))
@@ -568,6 +601,11 @@ func TestState_GetHistory(t *testing.T) {
 		t.FailNow()
 	}
 
+	_, err = state.GetHistory(15)
+	if !assert.EqualError(t, err, "seek to 15 beyond current height 14") {
+		t.FailNow()
+	}
+
 	s, err := state.GetHistory(10)
 	if !assert.NoError(t, err) {
 		t.FailNow()
@@ -708,6 +746,56 @@ func TestState_NicknameExists(t *testing.T) {
 	}
 }
 
+func TestState_NicknameReservation(t *testing.T) {
+	params := config.DefaultParams
+	params.NicknameReservationBlocks = 10
+	params.NicknameReserveHeight = 8
+	state := NewState(&params, nil)
+
+	producers := make([]*payload.ProducerInfo, 2)
+	for i := range producers {
+		producers[i] = &payload.ProducerInfo{
+			OwnerPublicKey: make([]byte, 33),
+			NodePublicKey:  make([]byte, 33),
+			NickName:       fmt.Sprintf("Producer-%d", i+1),
+		}
+		rand.Read(producers[i].OwnerPublicKey)
+		rand.Read(producers[i].NodePublicKey)
+	}
+	state.ProcessBlock(mockBlock(1, mockRegisterProducerTx(producers[0]),
+		mockRegisterProducerTx(producers[1])), nil)
+	for i := uint32(2); i <= 6; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+
+	// Cancel producer-1 and make producer-2 illegal.
+	state.ProcessBlock(mockBlock(7,
+		mockCancelProducerTx(producers[0].OwnerPublicKey),
+		mockIllegalBlockTx(producers[1].OwnerPublicKey)), nil)
+
+	// Nicknames are not reserved before NicknameReserveHeight.
+	params.NicknameReserveHeight = 9
+	assert.False(t, state.NicknameExists("Producer-1"))
+	assert.False(t, state.NicknameExists("Producer-2"))
+	params.NicknameReserveHeight = 8
+
+	// The nickname of producer-1 is reserved until the window elapses.
+	height := uint32(8)
+	for ; height < 7+10; height++ {
+		assert.True(t, state.NicknameExists("Producer-1"),
+			"height %d", height)
+		state.ProcessBlock(mockBlock(height), nil)
+	}
+	assert.False(t, state.NicknameExists("Producer-1"))
+
+	// The nickname of illegal producer-2 is reserved permanently.
+	assert.True(t, state.NicknameExists("Producer-2"))
+
+	// Rollback into the window.
+	assert.NoError(t, state.RollbackTo(10))
+	assert.True(t, state.NicknameExists("Producer-1"))
+}
+
 func TestState_ProducerExists(t *testing.T) {
 	state := NewState(&config.DefaultParams, nil)
 
@@ -1086,3 +1174,2270 @@ func TestState_InactiveProducer_RecoverFromInactiveState(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestState_VoteUnknownProducers(t *testing.T) {
+	state := NewState(&config.DefaultParams, nil)
+
+	// Create 10 producers info.
+	producers := make([]*payload.ProducerInfo, 10)
+	for i, p := range producers {
+		p = &payload.ProducerInfo{
+			OwnerPublicKey: make([]byte, 33),
+			NodePublicKey:  make([]byte, 33),
+		}
+		for j := range p.OwnerPublicKey {
+			p.OwnerPublicKey[j] = byte(i)
+		}
+		rand.Read(p.NodePublicKey)
+		p.NickName = fmt.Sprintf("Producer-%d", i+1)
+		producers[i] = p
+	}
+
+	// Register each producer on one height.
+	for i, p := range producers {
+		tx := mockRegisterProducerTx(p)
+		state.ProcessBlock(mockBlock(uint32(i+1), tx), nil)
+	}
+
+	// Vote 3 real producers and 3 fake producers.
+	publicKeys := make([][]byte, 0, 6)
+	for _, p := range producers[:3] {
+		publicKeys = append(publicKeys, p.OwnerPublicKey)
+	}
+	fakeKeys := make([][]byte, 3)
+	for i := range fakeKeys {
+		fakeKeys[i] = make([]byte, 33)
+		rand.Read(fakeKeys[i])
+		publicKeys = append(publicKeys, fakeKeys[i])
+	}
+	voteTx := mockVoteTx(publicKeys)
+	state.ProcessBlock(mockBlock(11, voteTx), nil)
+
+	for _, p := range producers[:3] {
+		producer := state.getProducer(p.OwnerPublicKey)
+		if !assert.Equal(t, common.Fixed64(100), producer.votes) {
+			t.FailNow()
+		}
+	}
+	for _, p := range producers[3:] {
+		producer := state.getProducer(p.OwnerPublicKey)
+		if !assert.Equal(t, common.Fixed64(0), producer.votes) {
+			t.FailNow()
+		}
+	}
+	for _, pk := range fakeKeys {
+		if !assert.Nil(t, state.getProducer(pk)) {
+			t.FailNow()
+		}
+	}
+
+	// Cancel the votes, only the credited votes will be reversed.
+	state.ProcessBlock(mockBlock(12, mockCancelVoteTx(voteTx)), nil)
+	for _, p := range producers {
+		producer := state.getProducer(p.OwnerPublicKey)
+		if !assert.Equal(t, common.Fixed64(0), producer.votes) {
+			t.FailNow()
+		}
+	}
+
+	// Rollback the cancel and the votes.
+	assert.NoError(t, state.RollbackTo(11))
+	for _, p := range producers[:3] {
+		producer := state.getProducer(p.OwnerPublicKey)
+		if !assert.Equal(t, common.Fixed64(100), producer.votes) {
+			t.FailNow()
+		}
+	}
+	assert.NoError(t, state.RollbackTo(10))
+	for _, p := range producers {
+		producer := state.getProducer(p.OwnerPublicKey)
+		if !assert.Equal(t, common.Fixed64(0), producer.votes) {
+			t.FailNow()
+		}
+	}
+}
+
+func TestState_GetVoteStats(t *testing.T) {
+	params := config.DefaultParams
+	params.GeneralArbiters = 2
+	state := NewState(&params, nil)
+
+	// Create 5 producers info.
+	producers := make([]*payload.ProducerInfo, 5)
+	for i, p := range producers {
+		p = &payload.ProducerInfo{
+			OwnerPublicKey: make([]byte, 33),
+			NodePublicKey:  make([]byte, 33),
+		}
+		for j := range p.OwnerPublicKey {
+			p.OwnerPublicKey[j] = byte(i)
+		}
+		rand.Read(p.NodePublicKey)
+		p.NickName = fmt.Sprintf("Producer-%d", i+1)
+		producers[i] = p
+	}
+
+	// Register all producers and let them be active.
+	txs := make([]*types.Transaction, len(producers))
+	for i, p := range producers {
+		txs[i] = mockRegisterProducerTx(p)
+	}
+	state.ProcessBlock(mockBlock(1, txs...), nil)
+	for i := uint32(2); i <= 6; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+
+	stats := state.GetVoteStats()
+	if !assert.Equal(t, VoteStats{}, stats) {
+		t.FailNow()
+	}
+
+	// Vote producer-1 three times, producer-2 twice and producer-3 once.
+	txs = []*types.Transaction{
+		mockVoteTx([][]byte{producers[0].OwnerPublicKey}),
+		mockVoteTx([][]byte{producers[0].OwnerPublicKey,
+			producers[1].OwnerPublicKey}),
+		mockVoteTx([][]byte{producers[0].OwnerPublicKey,
+			producers[1].OwnerPublicKey, producers[2].OwnerPublicKey}),
+	}
+	state.ProcessBlock(mockBlock(7, txs...), nil)
+
+	stats = state.GetVoteStats()
+	assert.Equal(t, common.Fixed64(600), stats.TotalVotes)
+	assert.Equal(t, 3, stats.VotedProducers)
+	assert.Equal(t, common.Fixed64(500), stats.TopArbitersVotes)
+	assert.InDelta(t, float64(500)/float64(600), stats.TopArbitersShare, 1e-9)
+}
+
+func TestState_PartialCancelVotes(t *testing.T) {
+	state := NewState(&config.DefaultParams, nil)
+
+	// Create 3 producers info.
+	producers := make([]*payload.ProducerInfo, 3)
+	for i, p := range producers {
+		p = &payload.ProducerInfo{
+			OwnerPublicKey: make([]byte, 33),
+			NodePublicKey:  make([]byte, 33),
+		}
+		for j := range p.OwnerPublicKey {
+			p.OwnerPublicKey[j] = byte(i)
+		}
+		rand.Read(p.NodePublicKey)
+		p.NickName = fmt.Sprintf("Producer-%d", i+1)
+		producers[i] = p
+	}
+
+	// Register all producers and let them be active.
+	txs := make([]*types.Transaction, len(producers))
+	for i, p := range producers {
+		txs[i] = mockRegisterProducerTx(p)
+	}
+	state.ProcessBlock(mockBlock(1, txs...), nil)
+	for i := uint32(2); i <= 6; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+
+	// Vote 100, 200, 300 to producers by separate outputs.
+	publicKeys := make([][]byte, len(producers))
+	for i, p := range producers {
+		publicKeys[i] = p.OwnerPublicKey
+	}
+	voteTx := mockMultiVoteTx(publicKeys)
+	state.ProcessBlock(mockBlock(7, voteTx), nil)
+	for i, pk := range publicKeys {
+		p := state.getProducer(pk)
+		if !assert.Equal(t, common.Fixed64(100*(i+1)), p.votes) {
+			t.FailNow()
+		}
+	}
+
+	// Spend only the second vote output.
+	cancelTx := &types.Transaction{
+		Version: types.TxVersion09,
+		TxType:  types.TransferAsset,
+		Inputs: []*types.Input{
+			{Previous: *types.NewOutPoint(voteTx.Hash(), 1)},
+		},
+	}
+	if !assert.True(t, state.IsDPOSTransaction(cancelTx)) {
+		t.FailNow()
+	}
+	state.ProcessBlock(mockBlock(8, cancelTx), nil)
+	assert.Equal(t, common.Fixed64(100), state.getProducer(publicKeys[0]).votes)
+	assert.Equal(t, common.Fixed64(0), state.getProducer(publicKeys[1]).votes)
+	assert.Equal(t, common.Fixed64(300), state.getProducer(publicKeys[2]).votes)
+
+	// The spent vote output will not be a vote any more.
+	if !assert.False(t, state.IsDPOSTransaction(cancelTx)) {
+		t.FailNow()
+	}
+
+	// Rollback the partial cancel.
+	assert.NoError(t, state.RollbackTo(7))
+	for i, pk := range publicKeys {
+		p := state.getProducer(pk)
+		if !assert.Equal(t, common.Fixed64(100*(i+1)), p.votes) {
+			t.FailNow()
+		}
+	}
+	assert.True(t, state.IsDPOSTransaction(cancelTx))
+}
+
+func TestState_GetRefundableDeposits(t *testing.T) {
+	params := config.DefaultParams
+	params.CRDepositLockupBlocks = 10
+	state := NewState(&params, nil)
+
+	_, pk, _ := crypto.GenerateKeyPair()
+	ownerPublicKey, _ := pk.EncodePoint(true)
+	depositHash, _ := contract.PublicKeyToDepositProgramHash(ownerPublicKey)
+	ownerHash, _ := contract.PublicKeyToStandardProgramHash(ownerPublicKey)
+	info := &payload.ProducerInfo{
+		OwnerPublicKey: ownerPublicKey,
+		NodePublicKey:  make([]byte, 33),
+		NickName:       "Producer",
+	}
+	rand.Read(info.NodePublicKey)
+
+	// Register the producer with 5000 ELA deposit.
+	tx := mockRegisterProducerTx(info)
+	tx.Outputs = []*types.Output{{
+		ProgramHash: *depositHash,
+		Value:       5000 * 100000000,
+	}}
+	state.ProcessBlock(mockBlock(1, tx), nil)
+	for i := uint32(2); i <= 6; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+	producer := state.GetProducer(ownerPublicKey)
+	if !assert.Equal(t, common.Fixed64(5000*100000000),
+		producer.DepositAmount()) {
+		t.FailNow()
+	}
+
+	// Cancel the producer on height 7.
+	state.ProcessBlock(mockBlock(7,
+		mockCancelProducerTx(ownerPublicKey)), nil)
+	if !assert.Equal(t, uint32(7), producer.CancelHeight()) {
+		t.FailNow()
+	}
+
+	// Deposit is not refundable until the lockup blocks passed.
+	for i := uint32(8); i < 17; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+		if !assert.Equal(t, 0, len(state.GetRefundableDeposits(i))) {
+			t.FailNow()
+		}
+	}
+	state.ProcessBlock(mockBlock(17), nil)
+	refunds := state.GetRefundableDeposits(17)
+	if !assert.Equal(t, 1, len(refunds)) {
+		t.FailNow()
+	}
+	assert.Equal(t, ownerPublicKey, refunds[0].OwnerPublicKey)
+	assert.Equal(t, *ownerHash, refunds[0].OwnerProgramHash)
+	assert.Equal(t, common.Fixed64(5000*100000000), refunds[0].Amount)
+}
+
+func TestState_GetRefundableDeposits_CRCLockup(t *testing.T) {
+	params := config.DefaultParams
+	params.CRDepositLockupBlocks = 10
+	params.CRCDepositLockupBlocks = 20
+	params.CRCDepositLockupHeight = 0
+	params.StateHistoryCapacity = 30
+	state := NewState(&params, nil)
+
+	producers := make([]*payload.ProducerInfo, 2)
+	txs := make([]*types.Transaction, 2)
+	for i := range producers {
+		_, pk, _ := crypto.GenerateKeyPair()
+		ownerPublicKey, _ := pk.EncodePoint(true)
+		depositHash, _ := contract.PublicKeyToDepositProgramHash(ownerPublicKey)
+		producers[i] = &payload.ProducerInfo{
+			OwnerPublicKey: ownerPublicKey,
+			NodePublicKey:  make([]byte, 33),
+			NickName:       fmt.Sprintf("Producer-%d", i+1),
+		}
+		rand.Read(producers[i].NodePublicKey)
+		txs[i] = mockRegisterProducerTx(producers[i])
+		txs[i].Outputs = []*types.Output{{
+			ProgramHash: *depositHash,
+			Value:       5000 * 100000000,
+		}}
+	}
+
+	// The second producer is a CRC arbiter.
+	state.setCRCArbiters(map[string]*Producer{
+		hex.EncodeToString(producers[1].NodePublicKey): {
+			info: *producers[1],
+		},
+	})
+
+	state.ProcessBlock(mockBlock(1, txs...), nil)
+	for i := uint32(2); i <= 6; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+	state.ProcessBlock(mockBlock(7,
+		mockCancelProducerTx(producers[0].OwnerPublicKey),
+		mockCancelProducerTx(producers[1].OwnerPublicKey)), nil)
+	for i := uint32(8); i <= 27; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+
+	// The normal producer is refundable after 10 blocks.
+	assert.Equal(t, 0, len(state.GetRefundableDeposits(16)))
+	refunds := state.GetRefundableDeposits(17)
+	if !assert.Equal(t, 1, len(refunds)) {
+		t.FailNow()
+	}
+	assert.Equal(t, producers[0].OwnerPublicKey, refunds[0].OwnerPublicKey)
+
+	// The CRC producer is refundable after 20 blocks.
+	assert.Equal(t, 1, len(state.GetRefundableDeposits(26)))
+	assert.Equal(t, 2, len(state.GetRefundableDeposits(27)))
+
+	assert.Equal(t, uint32(10), state.GetDepositLockupBlocks(
+		state.GetProducer(producers[0].OwnerPublicKey)))
+	assert.Equal(t, uint32(20), state.GetDepositLockupBlocks(
+		state.GetProducer(producers[1].OwnerPublicKey)))
+
+	// CRCDepositLockupBlocks does not apply to producers canceled before
+	// CRCDepositLockupHeight.
+	params.CRCDepositLockupHeight = 8
+	assert.Equal(t, uint32(10), state.GetDepositLockupBlocks(
+		state.GetProducer(producers[1].OwnerPublicKey)))
+
+	// The CRC arbiters replaced later do not change the recorded lockup.
+	params.CRCDepositLockupHeight = 0
+	state.setCRCArbiters(map[string]*Producer{})
+	assert.Equal(t, uint32(20), state.GetDepositLockupBlocks(
+		state.GetProducer(producers[1].OwnerPublicKey)))
+
+	// Rollback the cancel clears the record.
+	assert.NoError(t, state.RollbackTo(6))
+	assert.False(t, state.GetProducer(
+		producers[1].OwnerPublicKey).canceledAsCRC)
+}
+
+func TestState_GetProducerByAnyKey(t *testing.T) {
+	state := NewState(&config.DefaultParams, nil)
+
+	// Create 10 producers info.
+	producers := make([]*payload.ProducerInfo, 10)
+	for i, p := range producers {
+		p = &payload.ProducerInfo{
+			OwnerPublicKey: make([]byte, 33),
+			NodePublicKey:  make([]byte, 33),
+		}
+		for j := range p.OwnerPublicKey {
+			p.OwnerPublicKey[j] = byte(i)
+		}
+		rand.Read(p.NodePublicKey)
+		p.NickName = fmt.Sprintf("Producer-%d", i+1)
+		producers[i] = p
+	}
+
+	// Register each producer on one height.
+	for i, p := range producers {
+		tx := mockRegisterProducerTx(p)
+		state.ProcessBlock(mockBlock(uint32(i+1), tx), nil)
+	}
+
+	for _, p := range producers {
+		byOwner := state.GetProducerByAnyKey(p.OwnerPublicKey)
+		byNode := state.GetProducerByAnyKey(p.NodePublicKey)
+		if !assert.NotNil(t, byOwner) || !assert.True(t, byOwner == byNode) {
+			t.FailNow()
+		}
+	}
+
+	// Change producer node public key.
+	oldPublicKey := producers[0].NodePublicKey
+	producers[0].NodePublicKey = make([]byte, 33)
+	rand.Read(producers[0].NodePublicKey)
+	tx := mockUpdateProducerTx(producers[0])
+	state.ProcessBlock(mockBlock(11, tx), nil)
+
+	byOwner := state.GetProducerByAnyKey(producers[0].OwnerPublicKey)
+	byNode := state.GetProducerByAnyKey(producers[0].NodePublicKey)
+	if !assert.NotNil(t, byOwner) || !assert.True(t, byOwner == byNode) {
+		t.FailNow()
+	}
+	assert.Equal(t, producers[0].NodePublicKey, byOwner.NodePublicKey())
+	assert.Nil(t, state.GetProducerByAnyKey(oldPublicKey))
+}
+
+func TestState_IllegalPenalty(t *testing.T) {
+	params := config.DefaultParams
+	params.IllegalPenaltyHeight = 0
+	state := NewState(&params, nil)
+
+	// Create 10 producers info.
+	producers := make([]*payload.ProducerInfo, 10)
+	for i, p := range producers {
+		p = &payload.ProducerInfo{
+			OwnerPublicKey: make([]byte, 33),
+			NodePublicKey:  make([]byte, 33),
+		}
+		for j := range p.OwnerPublicKey {
+			p.OwnerPublicKey[j] = byte(i)
+		}
+		rand.Read(p.NodePublicKey)
+		p.NickName = fmt.Sprintf("Producer-%d", i+1)
+		producers[i] = p
+	}
+
+	// Register each producer on one height.
+	for i, p := range producers {
+		tx := mockRegisterProducerTx(p)
+		state.ProcessBlock(mockBlock(uint32(i+1), tx), nil)
+	}
+
+	// Make producer 0 illegal by block.
+	tx := mockIllegalBlockTx(producers[0].OwnerPublicKey)
+	state.ProcessBlock(mockBlock(11, tx), nil)
+	producer := state.GetProducer(producers[0].OwnerPublicKey)
+	if !assert.Equal(t, FoundBad, producer.State()) {
+		t.FailNow()
+	}
+	assert.Equal(t, state.chainParams.IllegalPenalty, producer.Penalty())
+	assert.NotEqual(t, state.chainParams.InactivePenalty, producer.Penalty())
+
+	// Make producer 1 illegal temporarily by special tx payload.
+	tx = mockIllegalBlockTx(producers[1].OwnerPublicKey)
+	state.ProcessSpecialTxPayload(tx.Payload)
+	producer = state.GetProducer(producers[1].OwnerPublicKey)
+	assert.Equal(t, state.chainParams.IllegalPenalty, producer.Penalty())
+
+	// Penalty will be reverted when next block comes.
+	state.ProcessBlock(mockBlock(12), nil)
+	assert.Equal(t, common.Fixed64(0), producer.Penalty())
+
+	// Rollback the illegal block, penalty will be reverted.
+	assert.NoError(t, state.RollbackTo(10))
+	producer = state.GetProducer(producers[0].OwnerPublicKey)
+	assert.Equal(t, common.Fixed64(0), producer.Penalty())
+
+	// No penalty is taken before IllegalPenaltyHeight.
+	params.IllegalPenaltyHeight = 12
+	state.ProcessBlock(mockBlock(11, tx), nil)
+	producer = state.GetProducer(producers[1].OwnerPublicKey)
+	assert.Equal(t, FoundBad, producer.State())
+	assert.Equal(t, common.Fixed64(0), producer.Penalty())
+}
+
+func TestState_AbstainVotes(t *testing.T) {
+	state := NewState(&config.DefaultParams, nil)
+
+	// Register a producer and let it be active.
+	info := &payload.ProducerInfo{
+		OwnerPublicKey: make([]byte, 33),
+		NodePublicKey:  make([]byte, 33),
+		NickName:       "Producer",
+	}
+	rand.Read(info.OwnerPublicKey)
+	rand.Read(info.NodePublicKey)
+	state.ProcessBlock(mockBlock(1, mockRegisterProducerTx(info)), nil)
+	for i := uint32(2); i <= 6; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+	voteTx := mockVoteTx([][]byte{info.OwnerPublicKey})
+	state.ProcessBlock(mockBlock(7, voteTx), nil)
+	producer := state.GetProducer(info.OwnerPublicKey)
+	if !assert.Equal(t, common.Fixed64(100), producer.Votes()) {
+		t.FailNow()
+	}
+
+	// Abstain votes do not change any producer's votes.
+	abstainTx := &types.Transaction{
+		Version: types.TxVersion09,
+		TxType:  types.TransferAsset,
+		Outputs: []*types.Output{{
+			Value: 300,
+			Type:  types.OTVote,
+			Payload: &outputpayload.VoteOutput{
+				Version: 0,
+				Contents: []outputpayload.VoteContent{
+					{VoteType: outputpayload.Abstain},
+				},
+			},
+		}},
+	}
+	state.ProcessBlock(mockBlock(8, abstainTx), nil)
+	assert.Equal(t, common.Fixed64(100), producer.Votes())
+	assert.Equal(t, common.Fixed64(300), state.GetAbstainVotes())
+	assert.Equal(t, common.Fixed64(300), state.GetVoteStats().AbstainVotes)
+
+	// Cancel abstain votes.
+	state.ProcessBlock(mockBlock(9, mockCancelVoteTx(abstainTx)), nil)
+	assert.Equal(t, common.Fixed64(100), producer.Votes())
+	assert.Equal(t, common.Fixed64(0), state.GetAbstainVotes())
+
+	// Rollback cancel and abstain votes.
+	assert.NoError(t, state.RollbackTo(8))
+	assert.Equal(t, common.Fixed64(300), state.GetAbstainVotes())
+	assert.NoError(t, state.RollbackTo(7))
+	assert.Equal(t, common.Fixed64(0), state.GetAbstainVotes())
+	assert.Equal(t, common.Fixed64(100), producer.Votes())
+}
+
+func TestState_MaxSelfVoteRatio(t *testing.T) {
+	params := config.DefaultParams
+	params.MaxSelfVoteNumerator = 1
+	params.MaxSelfVoteDenominator = 2
+	params.SelfVoteCapHeight = 0
+	state := NewState(&params, nil)
+
+	_, pk, _ := crypto.GenerateKeyPair()
+	ownerPublicKey, _ := pk.EncodePoint(true)
+	ownerHash, _ := contract.PublicKeyToStandardProgramHash(ownerPublicKey)
+	funders := make(map[common.Uint256]common.Uint168)
+	state.getTxReference = func(tx *types.Transaction) (
+		map[*types.Input]*types.Output, error) {
+		references := make(map[*types.Input]*types.Output)
+		if hash, ok := funders[tx.Hash()]; ok {
+			references[&types.Input{}] = &types.Output{ProgramHash: hash}
+		}
+		return references, nil
+	}
+	info := &payload.ProducerInfo{
+		OwnerPublicKey: ownerPublicKey,
+		NodePublicKey:  make([]byte, 33),
+		NickName:       "Producer",
+	}
+	rand.Read(info.NodePublicKey)
+	state.ProcessBlock(mockBlock(1, mockRegisterProducerTx(info)), nil)
+	for i := uint32(2); i <= 6; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+
+	// Votes from others are not limited.
+	state.ProcessBlock(mockBlock(7, mockVoteTx([][]byte{ownerPublicKey})), nil)
+	producer := state.GetProducer(ownerPublicKey)
+	if !assert.Equal(t, common.Fixed64(100), producer.Votes()) {
+		t.FailNow()
+	}
+
+	// Self votes funded by the owner address are clamped to the half of
+	// producer's votes, no matter who signed the transaction.
+	selfVoteTx := mockVoteTx([][]byte{ownerPublicKey})
+	selfVoteTx.Outputs[0].Value = 300
+	funders[selfVoteTx.Hash()] = *ownerHash
+	state.ProcessBlock(mockBlock(8, selfVoteTx), nil)
+	assert.Equal(t, common.Fixed64(200), producer.Votes())
+	assert.Equal(t, common.Fixed64(100), producer.selfVotes)
+
+	// Cancel self votes with the credited amount.
+	state.ProcessBlock(mockBlock(9, mockCancelVoteTx(selfVoteTx)), nil)
+	assert.Equal(t, common.Fixed64(100), producer.Votes())
+	assert.Equal(t, common.Fixed64(0), producer.selfVotes)
+
+	// Rollback cancel and self votes.
+	assert.NoError(t, state.RollbackTo(8))
+	assert.Equal(t, common.Fixed64(200), producer.Votes())
+	assert.Equal(t, common.Fixed64(100), producer.selfVotes)
+	assert.NoError(t, state.RollbackTo(7))
+	assert.Equal(t, common.Fixed64(100), producer.Votes())
+	assert.Equal(t, common.Fixed64(0), producer.selfVotes)
+
+	// Self votes are not limited before SelfVoteCapHeight.
+	params.SelfVoteCapHeight = 9
+	state.ProcessBlock(mockBlock(8, selfVoteTx), nil)
+	assert.Equal(t, common.Fixed64(400), producer.Votes())
+	assert.Equal(t, common.Fixed64(0), producer.selfVotes)
+}
+
+func TestState_GetHistoryDiff(t *testing.T) {
+	state := NewState(&config.DefaultParams, nil)
+
+	// Create 2 producers info.
+	producers := make([]*payload.ProducerInfo, 2)
+	for i := range producers {
+		producers[i] = &payload.ProducerInfo{
+			OwnerPublicKey: make([]byte, 33),
+			NodePublicKey:  make([]byte, 33),
+			NickName:       fmt.Sprintf("Producer-%d", i+1),
+		}
+		rand.Read(producers[i].OwnerPublicKey)
+		rand.Read(producers[i].NodePublicKey)
+	}
+	ownerA := producers[0].OwnerPublicKey
+	ownerB := producers[1].OwnerPublicKey
+
+	state.ProcessBlock(mockBlock(1, mockRegisterProducerTx(producers[0])), nil)
+	state.ProcessBlock(mockBlock(2, mockRegisterProducerTx(producers[1])), nil)
+	for i := uint32(3); i <= 6; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+	voteTx := mockVoteTx([][]byte{ownerA})
+	state.ProcessBlock(mockBlock(7, voteTx), nil)
+	update := *producers[1]
+	update.NickName = "Producer-2-updated"
+	state.ProcessBlock(mockBlock(8, mockUpdateProducerTx(&update)), nil)
+	state.ProcessBlock(mockBlock(9, mockCancelVoteTx(voteTx),
+		mockCancelProducerTx(ownerB)), nil)
+
+	changes, err := state.GetHistoryDiff(0, 9)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []ProducerChange{
+		{1, ProducerRegistered, ownerA, 0, Pending},
+		{2, ProducerRegistered, ownerB, 0, Pending},
+		{6, ProducerStateChanged, ownerA, 0, Activate},
+		{7, ProducerVotesChanged, ownerA, 100, Activate},
+		{7, ProducerStateChanged, ownerB, 0, Activate},
+		{8, ProducerUpdated, ownerB, 0, Activate},
+		{9, ProducerVotesChanged, ownerA, -100, Activate},
+		{9, ProducerCanceled, ownerB, 0, Canceled},
+	}, changes)
+
+	changes, err = state.GetHistoryDiff(6, 8)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []ProducerChange{
+		{7, ProducerVotesChanged, ownerA, 100, Activate},
+		{7, ProducerStateChanged, ownerB, 0, Activate},
+		{8, ProducerUpdated, ownerB, 0, Activate},
+	}, changes)
+
+	// Seeking to history height should not duplicate records.
+	_, err = state.GetHistory(5)
+	assert.NoError(t, err)
+	changes, err = state.GetHistoryDiff(8, 9)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(changes))
+
+	// Rollback removes records.
+	assert.NoError(t, state.RollbackTo(8))
+	changes, err = state.GetHistoryDiff(8, 8)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(changes))
+
+	// Out of range heights.
+	_, err = state.GetHistoryDiff(8, 9)
+	assert.EqualError(t, err, "seek to 9 overflow best height 8")
+	for i := uint32(9); i <= 12; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+	_, err = state.GetHistoryDiff(1, 12)
+	assert.EqualError(t, err, "seek to 1 overflow history capacity,"+
+		" at most seek to 2")
+	_, err = state.GetHistory(1)
+	assert.EqualError(t, err, "seek to 1 overflow history capacity,"+
+		" at most seek to 2")
+}
+
+func TestState_StateHistoryCapacity(t *testing.T) {
+	params := config.DefaultParams
+	params.StateHistoryCapacity = 3
+	state := NewState(&params, nil)
+
+	info := &payload.ProducerInfo{
+		OwnerPublicKey: make([]byte, 33),
+		NodePublicKey:  make([]byte, 33),
+		NickName:       "Producer",
+	}
+	rand.Read(info.OwnerPublicKey)
+	rand.Read(info.NodePublicKey)
+	state.ProcessBlock(mockBlock(1, mockRegisterProducerTx(info)), nil)
+	state.ProcessBlock(mockBlock(2), nil)
+	state.ProcessBlock(mockBlock(3), nil)
+	assert.Equal(t, 3, len(state.history.changes))
+
+	// All changes are kept before the history is full.
+	_, err := state.GetHistory(0)
+	assert.NoError(t, err)
+
+	// The oldest change is dropped when the history is full.
+	state.ProcessBlock(mockBlock(4), nil)
+	assert.Equal(t, 3, len(state.history.changes))
+	assert.Equal(t, uint32(2), state.history.changes[0].height)
+	_, err = state.GetHistory(0)
+	assert.EqualError(t, err, "seek to 0 overflow history capacity,"+
+		" at most seek to 1")
+	history, err := state.GetHistory(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(history.GetPendingProducers()))
+	assert.EqualError(t, state.RollbackTo(0), "rollback to 0 overflow"+
+		" history capacity, at most rollback to 1")
+	assert.NoError(t, state.RollbackTo(1))
+}
+
+func TestState_JailedProducer(t *testing.T) {
+	params := config.DefaultParams
+	params.JailInactiveCount = 2
+	params.JailHeight = 0
+	params.JailBlocks = 10
+	params.StateHistoryCapacity = 30
+	state := NewState(&params, nil)
+
+	info := &payload.ProducerInfo{
+		OwnerPublicKey: make([]byte, 33),
+		NodePublicKey:  make([]byte, 33),
+		NickName:       "Producer",
+	}
+	rand.Read(info.OwnerPublicKey)
+	rand.Read(info.NodePublicKey)
+	inactiveTx := func(height uint32) *types.Transaction {
+		return &types.Transaction{
+			TxType: types.InactiveArbitrators,
+			Payload: &payload.InactiveArbitrators{
+				Arbitrators: [][]byte{info.OwnerPublicKey},
+				BlockHeight: height,
+			},
+		}
+	}
+
+	state.ProcessBlock(mockBlock(1, mockRegisterProducerTx(info)), nil)
+	for i := uint32(2); i <= 6; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+	producer := state.GetProducer(info.OwnerPublicKey)
+
+	// The first inactivity sets producer to inactive state.
+	state.ProcessBlock(mockBlock(7, inactiveTx(7)), nil)
+	if !assert.Equal(t, Inactivate, producer.State()) {
+		t.FailNow()
+	}
+	state.ProcessBlock(mockBlock(8,
+		mockActivateProducerTx(info.OwnerPublicKey)), nil)
+	for i := uint32(9); i <= 13; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+	if !assert.Equal(t, Activate, producer.State()) {
+		t.FailNow()
+	}
+
+	// The second inactivity sets producer to jailed state.
+	state.ProcessBlock(mockBlock(14, inactiveTx(14)), nil)
+	if !assert.Equal(t, Jailed, producer.State()) {
+		t.FailNow()
+	}
+	assert.Equal(t, uint32(24), producer.JailUntilHeight())
+	assert.Equal(t, 1, len(state.GetJailedProducers()))
+	assert.Equal(t, 0, len(state.GetInactiveProducers()))
+	assert.Equal(t, 0, len(state.GetActiveProducers()))
+
+	// Jailed producer is released on the jail height.
+	for i := uint32(15); i < 24; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+		if !assert.Equal(t, Jailed, producer.State()) {
+			t.FailNow()
+		}
+	}
+	state.ProcessBlock(mockBlock(24), nil)
+	assert.Equal(t, Activate, producer.State())
+	assert.Equal(t, 0, len(state.GetJailedProducers()))
+	assert.Equal(t, 1, len(state.GetActiveProducers()))
+
+	// Rollback the release and jail.
+	assert.NoError(t, state.RollbackTo(23))
+	assert.Equal(t, Jailed, producer.State())
+	assert.Equal(t, uint32(24), producer.JailUntilHeight())
+	assert.Equal(t, 1, len(state.GetJailedProducers()))
+	assert.NoError(t, state.RollbackTo(13))
+	assert.Equal(t, Activate, producer.State())
+	assert.Equal(t, uint32(1), producer.inactiveCount)
+	assert.Equal(t, 0, len(state.GetJailedProducers()))
+	assert.Equal(t, 1, len(state.GetActiveProducers()))
+	// Producers are not jailed before JailHeight.
+	params.JailHeight = 15
+	state.ProcessBlock(mockBlock(14, inactiveTx(14)), nil)
+	assert.Equal(t, Inactivate, producer.State())
+	assert.Equal(t, 0, len(state.GetJailedProducers()))
+}
+
+func TestState_CancelJailedProducer(t *testing.T) {
+	params := config.DefaultParams
+	params.JailInactiveCount = 1
+	params.JailHeight = 0
+	params.JailBlocks = 10
+	params.StateHistoryCapacity = 30
+	state := NewState(&params, nil)
+
+	info := &payload.ProducerInfo{
+		OwnerPublicKey: make([]byte, 33),
+		NodePublicKey:  make([]byte, 33),
+		NickName:       "Producer",
+	}
+	rand.Read(info.OwnerPublicKey)
+	rand.Read(info.NodePublicKey)
+	state.ProcessBlock(mockBlock(1, mockRegisterProducerTx(info)), nil)
+	for i := uint32(2); i <= 6; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+	producer := state.GetProducer(info.OwnerPublicKey)
+
+	state.ProcessBlock(mockBlock(7, &types.Transaction{
+		TxType: types.InactiveArbitrators,
+		Payload: &payload.InactiveArbitrators{
+			Arbitrators: [][]byte{info.OwnerPublicKey},
+			BlockHeight: 7,
+		},
+	}), nil)
+	if !assert.Equal(t, Jailed, producer.State()) {
+		t.FailNow()
+	}
+
+	// Cancel the jailed producer.
+	state.ProcessBlock(mockBlock(8,
+		mockCancelProducerTx(info.OwnerPublicKey)), nil)
+	assert.Equal(t, Canceled, producer.State())
+	assert.Equal(t, 0, len(state.GetJailedProducers()))
+	assert.Equal(t, 1, len(state.GetCanceledProducers()))
+
+	// Canceled producer is not released on the jail height.
+	for i := uint32(9); i <= 17; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+	assert.Equal(t, Canceled, producer.State())
+	assert.Equal(t, 0, len(state.GetActiveProducers()))
+	assert.Equal(t, 1, len(state.GetCanceledProducers()))
+
+	// Rollback the cancel.
+	assert.NoError(t, state.RollbackTo(7))
+	assert.Equal(t, Jailed, producer.State())
+	assert.Equal(t, 1, len(state.GetJailedProducers()))
+	assert.Equal(t, 0, len(state.GetCanceledProducers()))
+	assert.Equal(t, 0, len(state.GetActiveProducers()))
+}
+
+func TestState_ActivateRequestExpiry(t *testing.T) {
+	params := config.DefaultParams
+	params.ActivateRequestExpiry = 10
+	params.ActivateExpiryHeight = 1
+	state := NewState(&params, nil)
+
+	info := &payload.ProducerInfo{
+		OwnerPublicKey: make([]byte, 33),
+		NodePublicKey:  make([]byte, 33),
+		NickName:       "Producer",
+	}
+	rand.Read(info.OwnerPublicKey)
+	rand.Read(info.NodePublicKey)
+	inactiveTx := func(height uint32) *types.Transaction {
+		return &types.Transaction{
+			TxType: types.InactiveArbitrators,
+			Payload: &payload.InactiveArbitrators{
+				Arbitrators: [][]byte{info.OwnerPublicKey},
+				BlockHeight: height,
+			},
+		}
+	}
+
+	state.ProcessBlock(mockBlock(1, mockRegisterProducerTx(info)), nil)
+	for i := uint32(2); i <= 6; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+	producer := state.GetProducer(info.OwnerPublicKey)
+
+	// A fulfilled request activates the producer in time.
+	state.ProcessBlock(mockBlock(7, inactiveTx(7)), nil)
+	state.ProcessBlock(mockBlock(8,
+		mockActivateProducerTx(info.OwnerPublicKey)), nil)
+	for i := uint32(9); i <= 13; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+	if !assert.Equal(t, Activate, producer.State()) {
+		t.FailNow()
+	}
+
+	// The fulfilled request is stale after expiry, it does not activate the
+	// producer being inactive again.
+	for i := uint32(14); i <= 17; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+	state.ProcessBlock(mockBlock(18, inactiveTx(18)), nil)
+	for i := uint32(19); i <= 25; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+		if !assert.Equal(t, Inactivate, producer.State()) {
+			t.FailNow()
+		}
+	}
+	assert.Equal(t, uint32(math.MaxUint32), producer.activateRequestHeight)
+
+	// Expiry below the activate confirmations does not block activation.
+	params.ActivateRequestExpiry = 3
+	state.ProcessBlock(mockBlock(26,
+		mockActivateProducerTx(info.OwnerPublicKey)), nil)
+	assert.Equal(t, uint32(26), producer.activateRequestHeight)
+	for i := uint32(27); i <= 30; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+		assert.Equal(t, Inactivate, producer.State())
+		assert.Equal(t, uint32(26), producer.activateRequestHeight)
+	}
+	state.ProcessBlock(mockBlock(31), nil)
+	assert.Equal(t, Activate, producer.State())
+
+	// Rollback restores the request.
+	assert.NoError(t, state.RollbackTo(28))
+	assert.Equal(t, Inactivate, producer.State())
+	assert.Equal(t, uint32(26), producer.activateRequestHeight)
+
+	// A request is not expired before ActivateExpiryHeight.
+	params.ActivateExpiryHeight = 100
+	assert.False(t, state.isActivateRequestExpired(producer, 99))
+	params.ActivateExpiryHeight = 1
+	assert.True(t, state.isActivateRequestExpired(producer, 32))
+}
+
+func TestIsDPOSTransactionType(t *testing.T) {
+	producer := &payload.ProducerInfo{
+		OwnerPublicKey: make([]byte, 33),
+		NodePublicKey:  make([]byte, 33),
+		NickName:       "Producer",
+	}
+	rand.Read(producer.OwnerPublicKey)
+	rand.Read(producer.NodePublicKey)
+
+	assert.True(t, IsDPOSTransactionType(mockRegisterProducerTx(producer)))
+	assert.True(t, IsDPOSTransactionType(mockUpdateProducerTx(producer)))
+	assert.True(t, IsDPOSTransactionType(
+		mockCancelProducerTx(producer.OwnerPublicKey)))
+	assert.True(t, IsDPOSTransactionType(
+		mockActivateProducerTx(producer.OwnerPublicKey)))
+	assert.True(t, IsDPOSTransactionType(
+		mockIllegalBlockTx(producer.OwnerPublicKey)))
+
+	voteTx := mockVoteTx([][]byte{producer.OwnerPublicKey})
+	assert.True(t, IsDPOSTransactionType(voteTx))
+
+	// Vote outputs are not counted before TxVersion09.
+	voteTx.Version = types.TxVersionDefault
+	assert.False(t, IsDPOSTransactionType(voteTx))
+
+	// Cancel votes can not be detected without state.
+	voteTx.Version = types.TxVersion09
+	assert.False(t, IsDPOSTransactionType(mockCancelVoteTx(voteTx)))
+
+	assert.False(t, IsDPOSTransactionType(&types.Transaction{
+		TxType: types.TransferAsset,
+		Outputs: []*types.Output{{
+			Value: 100,
+			Type:  types.OTNone,
+		}},
+	}))
+}
+
+func TestState_CancelPendingProducer(t *testing.T) {
+	state := NewState(&config.DefaultParams, nil)
+
+	info := &payload.ProducerInfo{
+		OwnerPublicKey: make([]byte, 33),
+		NodePublicKey:  make([]byte, 33),
+		NickName:       "Producer",
+	}
+	rand.Read(info.OwnerPublicKey)
+	rand.Read(info.NodePublicKey)
+	state.ProcessBlock(mockBlock(1, mockRegisterProducerTx(info)), nil)
+	if !assert.Equal(t, 1, len(state.GetPendingProducers())) {
+		t.FailNow()
+	}
+
+	// Cancel the producer while it's pending.
+	state.ProcessBlock(mockBlock(2,
+		mockCancelProducerTx(info.OwnerPublicKey)), nil)
+	producer := state.GetProducer(info.OwnerPublicKey)
+	assert.Equal(t, Canceled, producer.State())
+	assert.Equal(t, common.Fixed64(0), producer.Penalty())
+	assert.Equal(t, uint32(2), producer.CancelHeight())
+	assert.Equal(t, 0, len(state.GetPendingProducers()))
+	assert.Equal(t, 1, len(state.GetCanceledProducers()))
+	assert.False(t, state.NicknameExists(info.NickName))
+
+	// The canceled producer will not be activated later.
+	for i := uint32(3); i <= 8; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+	assert.Equal(t, Canceled, producer.State())
+	assert.Equal(t, 0, len(state.GetActiveProducers()))
+
+	// Rollback the cancel.
+	assert.NoError(t, state.RollbackTo(1))
+	assert.Equal(t, Pending, producer.State())
+	assert.Equal(t, 1, len(state.GetPendingProducers()))
+	assert.Equal(t, 0, len(state.GetCanceledProducers()))
+	assert.True(t, state.NicknameExists(info.NickName))
+}
+
+func TestState_InactiveProducer_NilConfirm(t *testing.T) {
+	params := config.DefaultParams
+	params.PublicDPOSHeight = 8
+	params.MaxInactiveRounds = 2
+	arbitrators := &ArbitratorsMock{}
+	state := NewState(&params, arbitrators.GetArbitrators)
+
+	producers := make([]*payload.ProducerInfo, 2)
+	for i := range producers {
+		producers[i] = &payload.ProducerInfo{
+			OwnerPublicKey: make([]byte, 33),
+			NodePublicKey:  make([]byte, 33),
+			NickName:       fmt.Sprintf("Producer-%d", i+1),
+		}
+		rand.Read(producers[i].OwnerPublicKey)
+		rand.Read(producers[i].NodePublicKey)
+		state.ProcessBlock(mockBlock(uint32(i+1),
+			mockRegisterProducerTx(producers[i])), nil)
+	}
+	for i := uint32(3); i < 8; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+	if !assert.Equal(t, 2, len(state.GetActiveProducers())) {
+		t.FailNow()
+	}
+	arbitrators.CurrentArbitrators = [][]byte{
+		producers[0].NodePublicKey,
+		producers[1].NodePublicKey,
+	}
+
+	// Blocks after PublicDPOSHeight without confirm or votes should not be
+	// counted as inactive.
+	height := uint32(8)
+	for i := 0; i < 5; i++ {
+		assert.NotPanics(t, func() {
+			state.ProcessBlock(mockBlock(height), nil)
+		})
+		height++
+		assert.NotPanics(t, func() {
+			state.ProcessBlock(mockBlock(height), &payload.Confirm{})
+		})
+		height++
+	}
+	assert.Equal(t, 0, len(state.GetInactiveProducers()))
+	for _, p := range producers {
+		assert.Equal(t, uint32(0),
+			state.GetProducer(p.NodePublicKey).inactiveCountingHeight)
+	}
+}
+
+func TestProducer_Timeline(t *testing.T) {
+	state := NewState(&config.DefaultParams, nil)
+
+	info := &payload.ProducerInfo{
+		OwnerPublicKey: make([]byte, 33),
+		NodePublicKey:  make([]byte, 33),
+		NickName:       "Producer",
+	}
+	rand.Read(info.OwnerPublicKey)
+	rand.Read(info.NodePublicKey)
+
+	// Register, activate, update and cancel the producer.
+	state.ProcessBlock(mockBlock(1, mockRegisterProducerTx(info)), nil)
+	for i := uint32(2); i <= 6; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+	update := *info
+	update.NickName = "Producer-Updated"
+	state.ProcessBlock(mockBlock(7, mockUpdateProducerTx(&update)), nil)
+	state.ProcessBlock(mockBlock(8,
+		mockCancelProducerTx(info.OwnerPublicKey)), nil)
+
+	producer := state.GetProducer(info.OwnerPublicKey)
+	assert.Equal(t, []LifecycleEvent{
+		{Height: 1, Type: LifecycleRegistered, State: Pending},
+		{Height: 6, Type: LifecycleActivated, State: Activate},
+		{Height: 7, Type: LifecycleUpdated, State: Activate},
+		{Height: 8, Type: LifecycleCanceled, State: Canceled},
+	}, producer.Timeline())
+
+	// The canceled producer found illegal.
+	state.ProcessBlock(mockBlock(9,
+		mockIllegalBlockTx(info.OwnerPublicKey)), nil)
+	timeline := producer.Timeline()
+	if !assert.Equal(t, 5, len(timeline)) {
+		t.FailNow()
+	}
+	assert.Equal(t, LifecycleEvent{Height: 9, Type: LifecycleIllegal,
+		State: FoundBad}, timeline[4])
+
+	// Timeline events are removed on rollback.
+	assert.NoError(t, state.RollbackTo(7))
+	assert.Equal(t, []LifecycleEvent{
+		{Height: 1, Type: LifecycleRegistered, State: Pending},
+		{Height: 6, Type: LifecycleActivated, State: Activate},
+		{Height: 7, Type: LifecycleUpdated, State: Activate},
+	}, producer.Timeline())
+
+	// Cancel again on another height after rollback.
+	state.ProcessBlock(mockBlock(8), nil)
+	state.ProcessBlock(mockBlock(9,
+		mockCancelProducerTx(info.OwnerPublicKey)), nil)
+	assert.Equal(t, []LifecycleEvent{
+		{Height: 1, Type: LifecycleRegistered, State: Pending},
+		{Height: 6, Type: LifecycleActivated, State: Activate},
+		{Height: 7, Type: LifecycleUpdated, State: Activate},
+		{Height: 9, Type: LifecycleCanceled, State: Canceled},
+	}, producer.Timeline())
+}
+
+func TestState_UpdateProducerOwnerKey(t *testing.T) {
+	state := NewState(&config.DefaultParams, nil)
+
+	info := &payload.ProducerInfo{
+		OwnerPublicKey: make([]byte, 33),
+		NodePublicKey:  make([]byte, 33),
+		NickName:       "Producer",
+	}
+	rand.Read(info.OwnerPublicKey)
+	rand.Read(info.NodePublicKey)
+	state.ProcessBlock(mockBlock(1, mockRegisterProducerTx(info)), nil)
+	producer := state.GetProducer(info.OwnerPublicKey)
+	origin := producer.Info()
+
+	// Update with the node public key as owner public key should be ignored.
+	update := *info
+	update.OwnerPublicKey = info.NodePublicKey
+	update.NickName = "Producer-Updated"
+	state.ProcessBlock(mockBlock(2, mockUpdateProducerTx(&update)), nil)
+	assert.Equal(t, origin, producer.Info())
+	assert.True(t, state.NicknameExists(info.NickName))
+	assert.False(t, state.NicknameExists(update.NickName))
+
+	// Update with an unknown owner public key should be ignored.
+	update.OwnerPublicKey = make([]byte, 33)
+	rand.Read(update.OwnerPublicKey)
+	state.ProcessBlock(mockBlock(3, mockUpdateProducerTx(&update)), nil)
+	assert.Equal(t, origin, producer.Info())
+	assert.Nil(t, state.GetProducer(update.OwnerPublicKey))
+
+	// Update with the registered owner public key is applied.
+	update.OwnerPublicKey = info.OwnerPublicKey
+	state.ProcessBlock(mockBlock(4, mockUpdateProducerTx(&update)), nil)
+	assert.Equal(t, update, producer.Info())
+	assert.True(t, state.NicknameExists(update.NickName))
+}
+
+func TestState_GetPromotableProducers(t *testing.T) {
+	state := NewState(&config.DefaultParams, nil)
+
+	// Register each producer on one height.
+	producers := make([]*payload.ProducerInfo, 4)
+	for i := range producers {
+		producers[i] = &payload.ProducerInfo{
+			OwnerPublicKey: make([]byte, 33),
+			NodePublicKey:  make([]byte, 33),
+			NickName:       fmt.Sprintf("Producer-%d", i+1),
+		}
+		rand.Read(producers[i].OwnerPublicKey)
+		rand.Read(producers[i].NodePublicKey)
+		state.ProcessBlock(mockBlock(uint32(i+1),
+			mockRegisterProducerTx(producers[i])), nil)
+	}
+
+	promotableKeys := func(height uint32) [][]byte {
+		keys := make([][]byte, 0)
+		for _, p := range state.GetPromotableProducers(height) {
+			keys = append(keys, p.OwnerPublicKey())
+		}
+		return keys
+	}
+
+	assert.Equal(t, 0, len(promotableKeys(5)))
+	assert.Equal(t, [][]byte{producers[0].OwnerPublicKey}, promotableKeys(6))
+	assert.ElementsMatch(t, [][]byte{producers[0].OwnerPublicKey,
+		producers[1].OwnerPublicKey, producers[2].OwnerPublicKey},
+		promotableKeys(8))
+	assert.Equal(t, 4, len(promotableKeys(9)))
+
+	// Promotable producers are activated on the height.
+	state.ProcessBlock(mockBlock(5), nil)
+	assert.Equal(t, 4, len(state.GetPendingProducers()))
+	state.ProcessBlock(mockBlock(6), nil)
+	assert.Equal(t, 3, len(state.GetPendingProducers()))
+	assert.Equal(t, Activate,
+		state.GetProducer(producers[0].OwnerPublicKey).State())
+	assert.Equal(t, [][]byte{producers[1].OwnerPublicKey}, promotableKeys(7))
+}
+
+func TestState_ProcessBlockError(t *testing.T) {
+	state := NewState(&config.DefaultParams, nil)
+
+	info := &payload.ProducerInfo{
+		OwnerPublicKey: make([]byte, 33),
+		NodePublicKey:  make([]byte, 33),
+		NickName:       "Producer",
+	}
+	rand.Read(info.OwnerPublicKey)
+	rand.Read(info.NodePublicKey)
+
+	// Valid block returns no error.
+	assert.NoError(t, state.ProcessBlock(mockBlock(1,
+		mockRegisterProducerTx(info)), nil))
+
+	// A malformed transaction returns error while the rest of the block is
+	// still processed.
+	malformed := &types.Transaction{
+		TxType:  types.RegisterProducer,
+		Payload: &payload.ProcessProducer{},
+	}
+	unknown := make([]byte, 33)
+	rand.Read(unknown)
+	update := *info
+	update.NickName = "Producer-Updated"
+	err := state.ProcessBlock(mockBlock(2, malformed,
+		mockCancelProducerTx(unknown), mockUpdateProducerTx(&update)), nil)
+	if !assert.Error(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, err.Error(), malformed.Hash().String())
+	assert.Contains(t, err.Error(), "invalid payload type")
+	assert.Contains(t, err.Error(), "cancel unknown producer")
+	assert.Equal(t, update, state.GetProducer(info.OwnerPublicKey).Info())
+	assert.Equal(t, 1, len(state.GetProducers()))
+
+	// Following valid blocks return no error.
+	assert.NoError(t, state.ProcessBlock(mockBlock(3), nil))
+}
+
+func TestState_CheckInvariants(t *testing.T) {
+	params := config.DefaultParams
+	state := NewState(&params, nil)
+
+	producers := make([]*payload.ProducerInfo, 10)
+	for i := range producers {
+		producers[i] = &payload.ProducerInfo{
+			OwnerPublicKey: make([]byte, 33),
+			NodePublicKey:  make([]byte, 33),
+			NickName:       fmt.Sprintf("Producer-%d", i+1),
+		}
+		rand.Read(producers[i].OwnerPublicKey)
+		rand.Read(producers[i].NodePublicKey)
+		state.ProcessBlock(mockBlock(uint32(i+1),
+			mockRegisterProducerTx(producers[i])), nil)
+		assert.NoError(t, state.CheckInvariants())
+	}
+
+	// Update, vote, cancel and make producers illegal and inactive.
+	update := *producers[0]
+	update.NickName = "Producer-Updated"
+	update.NodePublicKey = make([]byte, 33)
+	rand.Read(update.NodePublicKey)
+	state.ProcessBlock(mockBlock(11, mockUpdateProducerTx(&update),
+		mockVoteTx([][]byte{producers[1].OwnerPublicKey})), nil)
+	assert.NoError(t, state.CheckInvariants())
+	state.ProcessBlock(mockBlock(12,
+		mockCancelProducerTx(producers[2].OwnerPublicKey),
+		mockCancelProducerTx(producers[9].OwnerPublicKey),
+		mockIllegalBlockTx(producers[3].OwnerPublicKey)), nil)
+	assert.NoError(t, state.CheckInvariants())
+	state.ProcessBlock(mockBlock(13, &types.Transaction{
+		TxType: types.InactiveArbitrators,
+		Payload: &payload.InactiveArbitrators{
+			Arbitrators: [][]byte{producers[4].OwnerPublicKey},
+		},
+	}), nil)
+	assert.NoError(t, state.CheckInvariants())
+	assert.Equal(t, 1, len(state.GetInactiveProducers()))
+	assert.Equal(t, 2, len(state.GetCanceledProducers()))
+	assert.Equal(t, 1, len(state.GetIllegalProducers()))
+
+	// Rollback keeps invariants.
+	assert.NoError(t, state.RollbackTo(10))
+	assert.NoError(t, state.CheckInvariants())
+	state.ProcessBlock(mockBlock(11, mockUpdateProducerTx(&update)), nil)
+	assert.NoError(t, state.CheckInvariants())
+
+	// Producer in two sets.
+	key := hex.EncodeToString(producers[1].OwnerPublicKey)
+	producer := state.activityProducers[key]
+	state.pendingProducers[key] = producer
+	assert.Error(t, state.CheckInvariants())
+	delete(state.pendingProducers, key)
+	assert.NoError(t, state.CheckInvariants())
+
+	// Producer state not match it's set.
+	producer.state = Canceled
+	assert.Error(t, state.CheckInvariants())
+	producer.state = Activate
+
+	// Nickname index not match.
+	delete(state.nicknames, producer.info.NickName)
+	assert.Error(t, state.CheckInvariants())
+	state.nicknames[producer.info.NickName] = struct{}{}
+	state.nicknames["unknown"] = struct{}{}
+	assert.Error(t, state.CheckInvariants())
+	delete(state.nicknames, "unknown")
+
+	// Node public key index not match.
+	nodeKey := hex.EncodeToString(producer.info.NodePublicKey)
+	state.nodeOwnerKeys[nodeKey] = "unknown"
+	assert.Error(t, state.CheckInvariants())
+	state.nodeOwnerKeys[nodeKey] = key
+	assert.NoError(t, state.CheckInvariants())
+}
+
+func TestState_GetProducersByState(t *testing.T) {
+	params := config.DefaultParams
+	state := NewState(&params, nil)
+
+	producers := make([]*payload.ProducerInfo, 10)
+	for i := range producers {
+		producers[i] = &payload.ProducerInfo{
+			OwnerPublicKey: make([]byte, 33),
+			NodePublicKey:  make([]byte, 33),
+			NickName:       fmt.Sprintf("Producer-%d", i+1),
+		}
+		rand.Read(producers[i].OwnerPublicKey)
+		rand.Read(producers[i].NodePublicKey)
+		state.ProcessBlock(mockBlock(uint32(i+1),
+			mockRegisterProducerTx(producers[i])), nil)
+	}
+
+	state.ProcessBlock(mockBlock(11,
+		mockCancelProducerTx(producers[2].OwnerPublicKey),
+		mockIllegalBlockTx(producers[3].OwnerPublicKey)), nil)
+	state.ProcessBlock(mockBlock(12, &types.Transaction{
+		TxType: types.InactiveArbitrators,
+		Payload: &payload.InactiveArbitrators{
+			Arbitrators: [][]byte{producers[4].OwnerPublicKey},
+		},
+	}), nil)
+
+	ownerKeys := func(producers []*Producer) []string {
+		keys := make([]string, 0, len(producers))
+		for _, p := range producers {
+			keys = append(keys, hex.EncodeToString(p.OwnerPublicKey()))
+		}
+		return keys
+	}
+
+	assert.ElementsMatch(t, ownerKeys(state.GetPendingProducers()),
+		ownerKeys(state.GetProducersByState(Pending)))
+	assert.ElementsMatch(t, ownerKeys(state.GetActiveProducers()),
+		ownerKeys(state.GetProducersByState(Activate)))
+
+	// Active plus inactive with duplicated states.
+	union := append(state.GetActiveProducers(),
+		state.GetInactiveProducers()...)
+	result := state.GetProducersByState(Activate, Inactivate, Activate)
+	assert.Equal(t, 1, len(state.GetInactiveProducers()))
+	assert.Equal(t, len(union), len(result))
+	assert.ElementsMatch(t, ownerKeys(union), ownerKeys(result))
+
+	// Pending, canceled and illegal.
+	union = append(state.GetPendingProducers(),
+		state.GetCanceledProducers()...)
+	union = append(union, state.GetIllegalProducers()...)
+	result = state.GetProducersByState(Pending, Canceled, FoundBad)
+	assert.ElementsMatch(t, ownerKeys(union), ownerKeys(result))
+
+	assert.Equal(t, 0, len(state.GetProducersByState()))
+	assert.Equal(t, 0, len(state.GetProducersByState(Jailed)))
+}
+
+func TestState_ProducerBlocklist(t *testing.T) {
+	params := config.DefaultParams
+	params.ProducerBlocklistHeight = 1
+	state := NewState(&params, nil)
+
+	producers := make([]*payload.ProducerInfo, 3)
+	for i := range producers {
+		producers[i] = &payload.ProducerInfo{
+			OwnerPublicKey: make([]byte, 33),
+			NodePublicKey:  make([]byte, 33),
+			NickName:       fmt.Sprintf("Producer-%d", i+1),
+		}
+		rand.Read(producers[i].OwnerPublicKey)
+		rand.Read(producers[i].NodePublicKey)
+	}
+	params.ProducerBlocklist = []string{
+		strings.ToUpper(hex.EncodeToString(producers[0].OwnerPublicKey)),
+	}
+
+	// Registration of blocklisted owner is dropped.
+	assert.NoError(t, state.ProcessBlock(mockBlock(1,
+		mockRegisterProducerTx(producers[0]),
+		mockRegisterProducerTx(producers[1])), nil))
+	for i := uint32(2); i <= 6; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+	assert.Nil(t, state.GetProducer(producers[0].OwnerPublicKey))
+	assert.Nil(t, state.GetProducer(producers[0].NodePublicKey))
+	assert.False(t, state.NicknameExists(producers[0].NickName))
+	assert.Equal(t, 0, len(state.GetPendingProducers()))
+	assert.Equal(t, 1, len(state.GetActiveProducers()))
+	assert.NoError(t, state.CheckInvariants())
+
+	// A registered producer blocklisted later can not update or activate.
+	assert.NoError(t, state.ProcessBlock(mockBlock(7,
+		mockRegisterProducerTx(producers[2])), nil))
+	params.ProducerBlocklist = append(params.ProducerBlocklist,
+		hex.EncodeToString(producers[2].OwnerPublicKey))
+	update := *producers[2]
+	update.NickName = "Producer-Updated"
+	assert.NoError(t, state.ProcessBlock(mockBlock(8,
+		mockUpdateProducerTx(&update),
+		mockActivateProducerTx(producers[2].OwnerPublicKey)), nil))
+	producer := state.GetProducer(producers[2].OwnerPublicKey)
+	if !assert.NotNil(t, producer) {
+		t.FailNow()
+	}
+	assert.Equal(t, producers[2].NickName, producer.Info().NickName)
+	assert.Equal(t, uint32(math.MaxUint32), producer.activateRequestHeight)
+	assert.False(t, state.NicknameExists(update.NickName))
+}
+
+func TestState_GetIllegalEvidence(t *testing.T) {
+	params := config.DefaultParams
+	state := NewState(&params, nil)
+
+	producers := make([]*payload.ProducerInfo, 10)
+	for i := range producers {
+		producers[i] = &payload.ProducerInfo{
+			OwnerPublicKey: make([]byte, 33),
+			NodePublicKey:  make([]byte, 33),
+			NickName:       fmt.Sprintf("Producer-%d", i+1),
+		}
+		rand.Read(producers[i].OwnerPublicKey)
+		rand.Read(producers[i].NodePublicKey)
+		state.ProcessBlock(mockBlock(uint32(i+1),
+			mockRegisterProducerTx(producers[i])), nil)
+	}
+
+	// Make producer 0 illegal by illegal blocks, and producer 1 illegal by
+	// illegal votes.
+	tx := mockIllegalBlockTx(producers[0].OwnerPublicKey)
+	evidence := tx.Payload.(*payload.DPOSIllegalBlocks)
+	evidence.BlockHeight = 10
+	evidence.Evidence.Header = []byte{1}
+	evidence.CompareEvidence.Header = []byte{2}
+	assert.NoError(t, state.ProcessBlock(mockBlock(11, tx, &types.Transaction{
+		TxType: types.IllegalVoteEvidence,
+		Payload: &payload.DPOSIllegalVotes{
+			Evidence: payload.VoteEvidence{
+				Vote: payload.DPOSProposalVote{
+					Signer: producers[1].OwnerPublicKey,
+				},
+			},
+		},
+	}), nil))
+	assert.Equal(t, 2, len(state.GetIllegalProducers()))
+
+	result, ok := state.GetIllegalEvidence(producers[0].NodePublicKey)
+	assert.True(t, ok)
+	assert.True(t, result == evidence)
+	assert.Equal(t, evidence.Hash(), result.Hash())
+	result, ok = state.GetIllegalEvidence(producers[0].OwnerPublicKey)
+	assert.True(t, ok)
+	assert.True(t, result == evidence)
+
+	// No illegal blocks evidence for other producers.
+	_, ok = state.GetIllegalEvidence(producers[1].NodePublicKey)
+	assert.False(t, ok)
+	_, ok = state.GetIllegalEvidence(producers[2].NodePublicKey)
+	assert.False(t, ok)
+
+	// Evidence is cleared on rollback.
+	assert.NoError(t, state.RollbackTo(10))
+	_, ok = state.GetIllegalEvidence(producers[0].NodePublicKey)
+	assert.False(t, ok)
+	assert.Equal(t, 0, len(state.GetIllegalProducers()))
+}
+
+func TestState_GetUndercollateralizedProducers(t *testing.T) {
+	params := config.DefaultParams
+	params.InactivePenalty = 100 * 100000000
+	params.MinProducerDeposit = 4800 * 100000000
+	state := NewState(&params, nil)
+
+	_, pk, _ := crypto.GenerateKeyPair()
+	ownerPublicKey, _ := pk.EncodePoint(true)
+	depositHash, _ := contract.PublicKeyToDepositProgramHash(ownerPublicKey)
+	info := &payload.ProducerInfo{
+		OwnerPublicKey: ownerPublicKey,
+		NodePublicKey:  ownerPublicKey,
+		NickName:       "Producer",
+	}
+
+	// Register the producer with 5000 ELA deposit.
+	tx := mockRegisterProducerTx(info)
+	tx.Outputs = []*types.Output{{
+		ProgramHash: *depositHash,
+		Value:       5000 * 100000000,
+	}}
+	state.ProcessBlock(mockBlock(1, tx), nil)
+	for i := uint32(2); i <= 6; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+	assert.Equal(t, 0, len(state.GetUndercollateralizedProducers()))
+
+	// Take inactive penalties until the producer is undercollateralized.
+	height := uint32(7)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, 0, len(state.GetUndercollateralizedProducers()))
+		state.ProcessBlock(mockBlock(height, &types.Transaction{
+			TxType: types.InactiveArbitrators,
+			Payload: &payload.InactiveArbitrators{
+				Arbitrators: [][]byte{ownerPublicKey},
+				BlockHeight: height,
+			},
+		}), nil)
+		height++
+		state.ProcessBlock(mockBlock(height,
+			mockActivateProducerTx(ownerPublicKey)), nil)
+		height++
+		for j := 0; j < 6; j++ {
+			state.ProcessBlock(mockBlock(height), nil)
+			height++
+		}
+	}
+	producer := state.GetProducer(ownerPublicKey)
+	if !assert.Equal(t, common.Fixed64(300*100000000), producer.Penalty()) {
+		t.FailNow()
+	}
+	producers := state.GetUndercollateralizedProducers()
+	if !assert.Equal(t, 1, len(producers)) {
+		t.FailNow()
+	}
+	assert.Equal(t, ownerPublicKey, producers[0].OwnerPublicKey())
+
+	// Canceled producers are not counted.
+	state.ProcessBlock(mockBlock(height,
+		mockCancelProducerTx(ownerPublicKey)), nil)
+	assert.Equal(t, 0, len(state.GetUndercollateralizedProducers()))
+}
+
+func TestState_DepositTopUp(t *testing.T) {
+	params := config.DefaultParams
+	params.InactivePenalty = 300 * 100000000
+	params.MinProducerDeposit = 4800 * 100000000
+	state := NewState(&params, nil)
+
+	_, pk, _ := crypto.GenerateKeyPair()
+	ownerPublicKey, _ := pk.EncodePoint(true)
+	depositHash, _ := contract.PublicKeyToDepositProgramHash(ownerPublicKey)
+	info := &payload.ProducerInfo{
+		OwnerPublicKey: ownerPublicKey,
+		NodePublicKey:  ownerPublicKey,
+		NickName:       "Producer",
+	}
+
+	// Register the producer with 5000 ELA deposit.
+	tx := mockRegisterProducerTx(info)
+	tx.Outputs = []*types.Output{{
+		ProgramHash: *depositHash,
+		Value:       5000 * 100000000,
+	}}
+	state.ProcessBlock(mockBlock(1, tx), nil)
+	for i := uint32(2); i <= 6; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+
+	// Take an inactive penalty below the minimum deposit.
+	state.ProcessBlock(mockBlock(7, &types.Transaction{
+		TxType: types.InactiveArbitrators,
+		Payload: &payload.InactiveArbitrators{
+			Arbitrators: [][]byte{ownerPublicKey},
+			BlockHeight: 7,
+		},
+	}), nil)
+	producer := state.GetProducer(ownerPublicKey)
+	assert.Equal(t, 1, len(state.GetUndercollateralizedProducers()))
+
+	// Top up the deposit.
+	state.ProcessBlock(mockBlock(8, &types.Transaction{
+		TxType:  types.TransferAsset,
+		Payload: &payload.TransferAsset{},
+		Outputs: []*types.Output{
+			{ProgramHash: *depositHash, Value: 200 * 100000000},
+			{ProgramHash: common.Uint168{1}, Value: 500 * 100000000},
+		},
+	}), nil)
+	assert.Equal(t, common.Fixed64(5200*100000000), producer.DepositAmount())
+	assert.Equal(t, 0, len(state.GetUndercollateralizedProducers()))
+
+	// Rollback the top-up.
+	assert.NoError(t, state.RollbackTo(7))
+	assert.Equal(t, common.Fixed64(5000*100000000), producer.DepositAmount())
+	assert.Equal(t, 1, len(state.GetUndercollateralizedProducers()))
+}
+
+func TestState_GetOwnerNodeKeyMap(t *testing.T) {
+	params := config.DefaultParams
+	state := NewState(&params, nil)
+
+	producers := make([]*payload.ProducerInfo, 3)
+	for i := range producers {
+		producers[i] = &payload.ProducerInfo{
+			OwnerPublicKey: make([]byte, 33),
+			NodePublicKey:  make([]byte, 33),
+			NickName:       fmt.Sprintf("Producer-%d", i+1),
+		}
+		rand.Read(producers[i].OwnerPublicKey)
+		rand.Read(producers[i].NodePublicKey)
+		state.ProcessBlock(mockBlock(uint32(i+1),
+			mockRegisterProducerTx(producers[i])), nil)
+	}
+	for i := uint32(4); i <= 8; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+
+	keys := state.GetOwnerNodeKeyMap()
+	assert.Equal(t, 3, len(keys))
+	for _, p := range producers {
+		assert.Equal(t, hex.EncodeToString(p.NodePublicKey),
+			keys[hex.EncodeToString(p.OwnerPublicKey)])
+	}
+
+	// Update node public key of the first producer.
+	update := *producers[0]
+	update.NodePublicKey = make([]byte, 33)
+	rand.Read(update.NodePublicKey)
+	state.ProcessBlock(mockBlock(9, mockUpdateProducerTx(&update)), nil)
+
+	keys = state.GetOwnerNodeKeyMap()
+	assert.Equal(t, 3, len(keys))
+	assert.Equal(t, hex.EncodeToString(update.NodePublicKey),
+		keys[hex.EncodeToString(update.OwnerPublicKey)])
+
+	// Canceled producers are not included.
+	state.ProcessBlock(mockBlock(10,
+		mockCancelProducerTx(producers[1].OwnerPublicKey)), nil)
+	keys = state.GetOwnerNodeKeyMap()
+	assert.Equal(t, 2, len(keys))
+	_, ok := keys[hex.EncodeToString(producers[1].OwnerPublicKey)]
+	assert.False(t, ok)
+
+	// Inactive and jailed producers are included.
+	inactiveTx := func(ownerPublicKey []byte, height uint32) *types.Transaction {
+		return &types.Transaction{
+			TxType: types.InactiveArbitrators,
+			Payload: &payload.InactiveArbitrators{
+				Arbitrators: [][]byte{ownerPublicKey},
+				BlockHeight: height,
+			},
+		}
+	}
+	state.ProcessBlock(mockBlock(11,
+		inactiveTx(producers[0].OwnerPublicKey, 11)), nil)
+	params.JailInactiveCount = 1
+	params.JailHeight = 0
+	state.ProcessBlock(mockBlock(12,
+		inactiveTx(producers[2].OwnerPublicKey, 12)), nil)
+	assert.Equal(t, Inactivate, state.GetProducer(
+		producers[0].OwnerPublicKey).State())
+	assert.Equal(t, Jailed, state.GetProducer(
+		producers[2].OwnerPublicKey).State())
+	keys = state.GetOwnerNodeKeyMap()
+	assert.Equal(t, 2, len(keys))
+	assert.Equal(t, hex.EncodeToString(update.NodePublicKey),
+		keys[hex.EncodeToString(update.OwnerPublicKey)])
+	assert.Equal(t, hex.EncodeToString(producers[2].NodePublicKey),
+		keys[hex.EncodeToString(producers[2].OwnerPublicKey)])
+}
+
+func TestState_DetectConflictingEvidence(t *testing.T) {
+	params := config.DefaultParams
+	state := NewState(&params, nil)
+
+	producers := make([]*payload.ProducerInfo, 2)
+	for i := range producers {
+		producers[i] = &payload.ProducerInfo{
+			OwnerPublicKey: make([]byte, 33),
+			NodePublicKey:  make([]byte, 33),
+			NickName:       fmt.Sprintf("Producer-%d", i+1),
+		}
+		rand.Read(producers[i].OwnerPublicKey)
+		rand.Read(producers[i].NodePublicKey)
+		state.ProcessBlock(mockBlock(uint32(i+1),
+			mockRegisterProducerTx(producers[i])), nil)
+	}
+	for i := uint32(3); i <= 8; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+
+	illegalBlocks := func(signer []byte, height uint32,
+		header byte) *payload.DPOSIllegalBlocks {
+		return &payload.DPOSIllegalBlocks{
+			BlockHeight: height,
+			Evidence: payload.BlockEvidence{
+				Header:  []byte{1},
+				Signers: [][]byte{signer},
+			},
+			CompareEvidence: payload.BlockEvidence{
+				Header:  []byte{header},
+				Signers: [][]byte{signer},
+			},
+		}
+	}
+	signer := producers[0].OwnerPublicKey
+	first := illegalBlocks(signer, 5, 2)
+	second := illegalBlocks(signer, 5, 3)
+	other := illegalBlocks(producers[1].OwnerPublicKey, 6, 2)
+
+	state.ProcessBlock(mockBlock(9, &types.Transaction{
+		TxType:  types.IllegalBlockEvidence,
+		Payload: first,
+	}, &types.Transaction{
+		TxType:  types.IllegalBlockEvidence,
+		Payload: other,
+	}), nil)
+	assert.Equal(t, 0, len(state.DetectConflictingEvidence()))
+
+	state.ProcessBlock(mockBlock(10, &types.Transaction{
+		TxType:  types.IllegalBlockEvidence,
+		Payload: second,
+	}), nil)
+	reports := state.DetectConflictingEvidence()
+	if !assert.Equal(t, 1, len(reports)) {
+		t.FailNow()
+	}
+	assert.Equal(t, payload.IllegalBlock, reports[0].Type)
+	assert.Equal(t, signer, reports[0].Signer)
+	assert.Equal(t, uint32(5), reports[0].Height)
+	assert.ElementsMatch(t, []common.Uint256{first.Hash(), second.Hash()},
+		reports[0].PayloadHashes)
+
+	// Rollback the conflicting payload.
+	assert.NoError(t, state.RollbackTo(9))
+	assert.Equal(t, 0, len(state.DetectConflictingEvidence()))
+}
+
+func TestState_WatchProducerVotes(t *testing.T) {
+	params := config.DefaultParams
+	state := NewState(&params, nil)
+
+	info := &payload.ProducerInfo{
+		OwnerPublicKey: make([]byte, 33),
+		NodePublicKey:  make([]byte, 33),
+		NickName:       "Producer",
+	}
+	rand.Read(info.OwnerPublicKey)
+	rand.Read(info.NodePublicKey)
+	state.ProcessBlock(mockBlock(1, mockRegisterProducerTx(info)), nil)
+	for i := uint32(2); i <= 6; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+
+	type votesChange struct {
+		height uint32
+		votes  common.Fixed64
+	}
+	var changes []votesChange
+	unwatch := state.WatchProducerVotes(info.NodePublicKey,
+		func(height uint32, votes common.Fixed64) {
+			changes = append(changes, votesChange{height, votes})
+		})
+
+	voteTx1 := mockVoteTx([][]byte{info.OwnerPublicKey})
+	voteTx2 := mockVoteTx([][]byte{info.OwnerPublicKey})
+	voteTx2.LockTime = 1
+	state.ProcessBlock(mockBlock(7, voteTx1), nil)
+	state.ProcessBlock(mockBlock(8, voteTx2), nil)
+	state.ProcessBlock(mockBlock(9, mockCancelVoteTx(voteTx1)), nil)
+	state.ProcessBlock(mockBlock(10), nil)
+	assert.Equal(t, []votesChange{{7, 100}, {8, 200}, {9, 100}}, changes)
+
+	// Rollback fires the reversal.
+	changes = nil
+	assert.NoError(t, state.RollbackTo(8))
+	assert.Equal(t, []votesChange{{8, 200}}, changes)
+
+	// No more callbacks after the watch removed.
+	changes = nil
+	unwatch()
+	state.ProcessBlock(mockBlock(9, mockCancelVoteTx(voteTx1)), nil)
+	assert.Equal(t, common.Fixed64(100),
+		state.GetProducer(info.NodePublicKey).Votes())
+	assert.Equal(t, 0, len(changes))
+
+	// Callbacks are invoked unlocked, so they can begin a snapshot or process
+	// the next block.
+	unwatch = state.WatchProducerVotes(info.NodePublicKey,
+		func(height uint32, votes common.Fixed64) {
+			changes = append(changes, votesChange{height, votes})
+			state.BeginSnapshot()()
+			if height == 10 {
+				state.ProcessBlock(mockBlock(11), nil)
+			}
+		})
+	defer unwatch()
+	voteTx3 := mockVoteTx([][]byte{info.OwnerPublicKey})
+	voteTx3.LockTime = 3
+	assert.NoError(t, state.ProcessBlock(mockBlock(10, voteTx3), nil))
+	assert.Equal(t, []votesChange{{10, 200}}, changes)
+	assert.Equal(t, uint32(11), state.history.height)
+}
+
+func TestState_ActivateProducerValidation(t *testing.T) {
+	params := config.DefaultParams
+	params.OwnerActivateHeight = 21
+	state := NewState(&params, nil)
+
+	info := &payload.ProducerInfo{
+		OwnerPublicKey: make([]byte, 33),
+		NodePublicKey:  make([]byte, 33),
+		NickName:       "Producer",
+	}
+	rand.Read(info.OwnerPublicKey)
+	rand.Read(info.NodePublicKey)
+	state.ProcessBlock(mockBlock(1, mockRegisterProducerTx(info)), nil)
+	for i := uint32(2); i <= 6; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+	producer := state.GetProducer(info.OwnerPublicKey)
+	if !assert.Equal(t, Activate, producer.State()) {
+		t.FailNow()
+	}
+
+	// Activating an active producer is a no-op.
+	assert.NoError(t, state.ProcessBlock(mockBlock(7,
+		mockActivateProducerTx(info.OwnerPublicKey)), nil))
+	assert.Equal(t, uint32(math.MaxUint32), producer.activateRequestHeight)
+
+	// Activating an unknown producer is rejected.
+	unknown := make([]byte, 33)
+	rand.Read(unknown)
+	assert.Error(t, state.ProcessBlock(mockBlock(8,
+		mockActivateProducerTx(unknown)), nil))
+
+	// The earlier activation does not recover the producer once inactive.
+	state.ProcessBlock(mockBlock(9, &types.Transaction{
+		TxType: types.InactiveArbitrators,
+		Payload: &payload.InactiveArbitrators{
+			Arbitrators: [][]byte{info.OwnerPublicKey},
+		},
+	}), nil)
+	for i := uint32(10); i <= 20; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+	assert.Equal(t, Inactivate, producer.State())
+
+	// Activating by node public key is rejected.
+	assert.Error(t, state.ProcessBlock(mockBlock(21,
+		mockActivateProducerTx(info.NodePublicKey)), nil))
+	assert.Equal(t, uint32(math.MaxUint32), producer.activateRequestHeight)
+
+	// Activating by owner public key recovers the producer.
+	assert.NoError(t, state.ProcessBlock(mockBlock(22,
+		mockActivateProducerTx(info.OwnerPublicKey)), nil))
+	for i := uint32(23); i <= 28; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+	assert.Equal(t, Activate, producer.State())
+}
+
+func TestProducer_LastVoteChangeHeight(t *testing.T) {
+	params := config.DefaultParams
+	state := NewState(&params, nil)
+
+	info := &payload.ProducerInfo{
+		OwnerPublicKey: make([]byte, 33),
+		NodePublicKey:  make([]byte, 33),
+		NickName:       "Producer",
+	}
+	rand.Read(info.OwnerPublicKey)
+	rand.Read(info.NodePublicKey)
+	state.ProcessBlock(mockBlock(1, mockRegisterProducerTx(info)), nil)
+	for i := uint32(2); i <= 6; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+	producer := state.GetProducer(info.OwnerPublicKey)
+	assert.Equal(t, uint32(0), producer.LastVoteChangeHeight())
+
+	// Vote on height 7 and keeps until a new vote arrives.
+	voteTx1 := mockVoteTx([][]byte{info.OwnerPublicKey})
+	state.ProcessBlock(mockBlock(7, voteTx1), nil)
+	for i := uint32(8); i <= 12; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+		assert.Equal(t, uint32(7), producer.LastVoteChangeHeight())
+	}
+
+	// Two votes on one height.
+	voteTx2 := mockVoteTx([][]byte{info.OwnerPublicKey})
+	voteTx2.LockTime = 1
+	voteTx3 := mockVoteTx([][]byte{info.OwnerPublicKey})
+	voteTx3.LockTime = 2
+	state.ProcessBlock(mockBlock(13, voteTx2, voteTx3), nil)
+	assert.Equal(t, uint32(13), producer.LastVoteChangeHeight())
+	state.ProcessBlock(mockBlock(14), nil)
+	assert.Equal(t, uint32(13), producer.LastVoteChangeHeight())
+
+	// Cancel vote changes votes too.
+	state.ProcessBlock(mockBlock(15, mockCancelVoteTx(voteTx1)), nil)
+	assert.Equal(t, uint32(15), producer.LastVoteChangeHeight())
+
+	// Rollback restores the previous heights.
+	assert.NoError(t, state.RollbackTo(14))
+	assert.Equal(t, uint32(13), producer.LastVoteChangeHeight())
+	assert.NoError(t, state.RollbackTo(12))
+	assert.Equal(t, uint32(7), producer.LastVoteChangeHeight())
+	assert.Equal(t, common.Fixed64(100), producer.Votes())
+}
+
+func TestState_NearInactiveWarning(t *testing.T) {
+	arbitrators := &ArbitratorsMock{}
+	params := config.DefaultParams
+	params.PublicDPOSHeight = 7
+	params.MaxInactiveRounds = 10
+	params.NearInactiveRatio = 0.8
+	state := NewState(&params, arbitrators.GetArbitrators)
+
+	producers := make([]*payload.ProducerInfo, 2)
+	for i := range producers {
+		producers[i] = &payload.ProducerInfo{
+			OwnerPublicKey: make([]byte, 33),
+			NodePublicKey:  make([]byte, 33),
+			NickName:       fmt.Sprintf("Producer-%d", i+1),
+		}
+		rand.Read(producers[i].OwnerPublicKey)
+		rand.Read(producers[i].NodePublicKey)
+	}
+	state.ProcessBlock(mockBlock(1, mockRegisterProducerTx(producers[0]),
+		mockRegisterProducerTx(producers[1])), nil)
+	for i := uint32(2); i <= 6; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+	arbitrators.CurrentArbitrators = [][]byte{
+		producers[0].NodePublicKey,
+		producers[1].NodePublicKey,
+	}
+
+	var warnings []*NearInactiveArbiter
+	events.Subscribe(func(e *events.Event) {
+		if e.Type == events.ETArbiterNearInactive {
+			warnings = append(warnings, e.Data.(*NearInactiveArbiter))
+		}
+	})
+
+	// producers[0] misses signing from height 7, the warning fires when the
+	// missed rounds reach 8 and only once.
+	confirm := &payload.Confirm{
+		Votes: []payload.DPOSProposalVote{
+			{Signer: producers[1].NodePublicKey},
+		},
+	}
+	for i := uint32(7); i <= 14; i++ {
+		state.ProcessBlock(mockBlock(i), confirm)
+	}
+	assert.Equal(t, 0, len(warnings))
+
+	state.ProcessBlock(mockBlock(15), confirm)
+	if !assert.Equal(t, 1, len(warnings)) {
+		t.FailNow()
+	}
+	assert.Equal(t, producers[0].NodePublicKey, warnings[0].NodePublicKey)
+	assert.Equal(t, uint32(8), warnings[0].MissCount)
+
+	for i := uint32(16); i <= 17; i++ {
+		state.ProcessBlock(mockBlock(i), confirm)
+	}
+	assert.Equal(t, 1, len(warnings))
+	assert.Equal(t, Activate, state.GetProducer(
+		producers[0].OwnerPublicKey).State())
+
+	// Rollback restores the warned flag, so the warning fires again.
+	assert.NoError(t, state.RollbackTo(14))
+	assert.False(t, state.GetProducer(
+		producers[0].OwnerPublicKey).inactiveWarned)
+	state.ProcessBlock(mockBlock(15), confirm)
+	assert.Equal(t, 2, len(warnings))
+}
+
+func TestState_ProducerInfoValidation(t *testing.T) {
+	params := config.DefaultParams
+	params.MaxProducerNickNameLength = 16
+	params.MaxProducerUrlLength = 32
+	params.ProducerInfoCheckHeight = 1
+	state := NewState(&params, nil)
+
+	newInfo := func(nickname, url string) *payload.ProducerInfo {
+		info := &payload.ProducerInfo{
+			OwnerPublicKey: make([]byte, 33),
+			NodePublicKey:  make([]byte, 33),
+			NickName:       nickname,
+			Url:            url,
+		}
+		rand.Read(info.OwnerPublicKey)
+		rand.Read(info.NodePublicKey)
+		return info
+	}
+
+	// Overly long nickname is rejected.
+	info := newInfo(strings.Repeat("a", 17), "https://elastos.org")
+	assert.Error(t, state.ProcessBlock(mockBlock(1,
+		mockRegisterProducerTx(info)), nil))
+	assert.Nil(t, state.GetProducer(info.OwnerPublicKey))
+
+	// Nickname with control characters is rejected.
+	info = newInfo("Producer\n", "https://elastos.org")
+	assert.Error(t, state.ProcessBlock(mockBlock(2,
+		mockRegisterProducerTx(info)), nil))
+	assert.Nil(t, state.GetProducer(info.OwnerPublicKey))
+
+	// Malformed urls are rejected.
+	for i, url := range []string{
+		"elastos.org",
+		"ftp://elastos.org",
+		"http://",
+		"https://" + strings.Repeat("a", 32) + ".org",
+	} {
+		info = newInfo(fmt.Sprintf("Producer-%d", i), url)
+		assert.Error(t, state.ProcessBlock(mockBlock(uint32(3+i),
+			mockRegisterProducerTx(info)), nil))
+		assert.Nil(t, state.GetProducer(info.OwnerPublicKey))
+	}
+
+	// Valid producer info is accepted.
+	info = newInfo("Producer", "https://elastos.org")
+	assert.NoError(t, state.ProcessBlock(mockBlock(7,
+		mockRegisterProducerTx(info)), nil))
+	if !assert.NotNil(t, state.GetProducer(info.OwnerPublicKey)) {
+		t.FailNow()
+	}
+
+	// Update with malformed url is rejected.
+	update := *info
+	update.Url = "elastos"
+	assert.Error(t, state.ProcessBlock(mockBlock(8, &types.Transaction{
+		TxType:  types.UpdateProducer,
+		Payload: &update,
+	}), nil))
+	assert.Equal(t, "https://elastos.org",
+		state.GetProducer(info.OwnerPublicKey).Info().Url)
+}
+
+func TestState_GetProducerVoteSources(t *testing.T) {
+	params := config.DefaultParams
+	state := NewState(&params, nil)
+
+	info := &payload.ProducerInfo{
+		OwnerPublicKey: make([]byte, 33),
+		NodePublicKey:  make([]byte, 33),
+		NickName:       "Producer",
+	}
+	rand.Read(info.OwnerPublicKey)
+	rand.Read(info.NodePublicKey)
+	state.ProcessBlock(mockBlock(1, mockRegisterProducerTx(info)), nil)
+	for i := uint32(2); i <= 6; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+	assert.Empty(t, state.GetProducerVoteSources(info.NodePublicKey))
+
+	// Vote from two transactions, payloads are set to get distinct hashes.
+	voteTx1 := mockVoteTx([][]byte{info.OwnerPublicKey})
+	voteTx1.Payload = &payload.TransferAsset{}
+	state.ProcessBlock(mockBlock(7, voteTx1), nil)
+	voteTx2 := mockVoteTx([][]byte{info.OwnerPublicKey})
+	voteTx2.Payload = &payload.TransferAsset{}
+	voteTx2.Outputs[0].Value = 200
+	state.ProcessBlock(mockBlock(8, voteTx2), nil)
+	source1 := VoteSource{
+		OutPoint: *types.NewOutPoint(voteTx1.Hash(), 0),
+		Amount:   100,
+		Height:   7,
+	}
+	source2 := VoteSource{
+		OutPoint: *types.NewOutPoint(voteTx2.Hash(), 0),
+		Amount:   200,
+		Height:   8,
+	}
+	assert.Equal(t, []VoteSource{source1, source2},
+		state.GetProducerVoteSources(info.NodePublicKey))
+
+	// Cancel one vote, the other one remains.
+	state.ProcessBlock(mockBlock(9, mockCancelVoteTx(voteTx1)), nil)
+	assert.Equal(t, []VoteSource{source2},
+		state.GetProducerVoteSources(info.OwnerPublicKey))
+
+	// Rollback restores the vote sources.
+	assert.NoError(t, state.RollbackTo(8))
+	assert.Equal(t, []VoteSource{source1, source2},
+		state.GetProducerVoteSources(info.NodePublicKey))
+	assert.NoError(t, state.RollbackTo(7))
+	assert.Equal(t, []VoteSource{source1},
+		state.GetProducerVoteSources(info.NodePublicKey))
+}
+
+func TestState_ProcessBlockTwice(t *testing.T) {
+	params := config.DefaultParams
+	state := NewState(&params, nil)
+
+	info := &payload.ProducerInfo{
+		OwnerPublicKey: make([]byte, 33),
+		NodePublicKey:  make([]byte, 33),
+		NickName:       "Producer",
+	}
+	rand.Read(info.OwnerPublicKey)
+	rand.Read(info.NodePublicKey)
+	state.ProcessBlock(mockBlock(1, mockRegisterProducerTx(info)), nil)
+	for i := uint32(2); i <= 6; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+
+	block := mockBlock(7, mockVoteTx([][]byte{info.OwnerPublicKey}))
+	assert.NoError(t, state.ProcessBlock(block, nil))
+	producer := state.GetProducer(info.OwnerPublicKey)
+	assert.Equal(t, common.Fixed64(100), producer.Votes())
+
+	// Processing the same block again is rejected without applying changes.
+	assert.EqualError(t, state.ProcessBlock(block, nil),
+		"block height 7 already processed, current height 7")
+	assert.EqualError(t, state.ProcessBlock(mockBlock(6), nil),
+		"block height 6 already processed, current height 7")
+	assert.Equal(t, common.Fixed64(100), producer.Votes())
+
+	// The block can be processed again after rollback.
+	assert.NoError(t, state.RollbackTo(6))
+	assert.Equal(t, common.Fixed64(0), producer.Votes())
+	assert.NoError(t, state.ProcessBlock(block, nil))
+	assert.Equal(t, common.Fixed64(100), producer.Votes())
+}
+
+func TestState_GetActiveVotingPower(t *testing.T) {
+	params := config.DefaultParams
+	state := NewState(&params, nil)
+
+	producers := make([]*payload.ProducerInfo, 3)
+	txs := make([]*types.Transaction, len(producers))
+	for i := range producers {
+		producers[i] = &payload.ProducerInfo{
+			OwnerPublicKey: make([]byte, 33),
+			NodePublicKey:  make([]byte, 33),
+			NickName:       fmt.Sprintf("Producer-%d", i+1),
+		}
+		rand.Read(producers[i].OwnerPublicKey)
+		rand.Read(producers[i].NodePublicKey)
+		txs[i] = mockRegisterProducerTx(producers[i])
+	}
+	state.ProcessBlock(mockBlock(1, txs...), nil)
+	for i := uint32(2); i <= 6; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+	assert.Equal(t, common.Fixed64(0), state.GetActiveVotingPower())
+
+	// Each producer gets votes of 100, 200 and 300.
+	state.ProcessBlock(mockBlock(7, mockMultiVoteTx([][]byte{
+		producers[0].OwnerPublicKey,
+		producers[1].OwnerPublicKey,
+		producers[2].OwnerPublicKey,
+	})), nil)
+	assert.Equal(t, common.Fixed64(600), state.GetActiveVotingPower())
+
+	// Votes of inactive and illegal producers are excluded.
+	state.ProcessBlock(mockBlock(8, &types.Transaction{
+		TxType: types.InactiveArbitrators,
+		Payload: &payload.InactiveArbitrators{
+			Arbitrators: [][]byte{producers[0].OwnerPublicKey},
+		},
+	}), nil)
+	assert.Equal(t, Inactivate, state.GetProducer(
+		producers[0].OwnerPublicKey).State())
+	assert.Equal(t, common.Fixed64(500), state.GetActiveVotingPower())
+
+	state.ProcessBlock(mockBlock(9,
+		mockIllegalBlockTx(producers[1].OwnerPublicKey)), nil)
+	assert.Equal(t, FoundBad, state.GetProducer(
+		producers[1].OwnerPublicKey).State())
+	assert.Equal(t, common.Fixed64(300), state.GetActiveVotingPower())
+}
+
+func TestProducer_RegisterTxHash(t *testing.T) {
+	params := config.DefaultParams
+	state := NewState(&params, nil)
+
+	info := &payload.ProducerInfo{
+		OwnerPublicKey: make([]byte, 33),
+		NodePublicKey:  make([]byte, 33),
+		NickName:       "Producer",
+	}
+	rand.Read(info.OwnerPublicKey)
+	rand.Read(info.NodePublicKey)
+	tx := mockRegisterProducerTx(info)
+	state.ProcessBlock(mockBlock(1, tx), nil)
+	producer := state.GetProducer(info.OwnerPublicKey)
+	if !assert.NotNil(t, producer) {
+		t.FailNow()
+	}
+	assert.Equal(t, tx.Hash(), producer.RegisterTxHash())
+
+	// Update does not change the register transaction hash.
+	update := *info
+	update.NickName = "Updated"
+	updateTx := &types.Transaction{
+		TxType:  types.UpdateProducer,
+		Payload: &update,
+	}
+	state.ProcessBlock(mockBlock(2, updateTx), nil)
+	assert.Equal(t, "Updated", producer.Info().NickName)
+	assert.Equal(t, tx.Hash(), producer.RegisterTxHash())
+
+	// The producer is removed with it's register transaction hash on rollback.
+	assert.NoError(t, state.RollbackTo(0))
+	assert.Nil(t, state.GetProducer(info.OwnerPublicKey))
+}
+
+// mockTopProducersState creates a state with count active producers, votes of
+// producers are taken from their random node public keys so there are ties.
+func mockTopProducersState(count int) *State {
+	state := NewState(&config.DefaultParams, nil)
+	txs := make([]*types.Transaction, count)
+	for i := range txs {
+		info := &payload.ProducerInfo{
+			OwnerPublicKey: make([]byte, 33),
+			NodePublicKey:  make([]byte, 33),
+			NickName:       fmt.Sprintf("Producer-%d", i+1),
+		}
+		rand.Read(info.OwnerPublicKey)
+		rand.Read(info.NodePublicKey)
+		txs[i] = mockRegisterProducerTx(info)
+	}
+	state.ProcessBlock(mockBlock(1, txs...), nil)
+	for i := uint32(2); i <= 6; i++ {
+		state.ProcessBlock(mockBlock(i), nil)
+	}
+	for _, p := range state.activityProducers {
+		p.votes = common.Fixed64(p.info.NodePublicKey[1] % 64)
+	}
+	return state
+}
+
+func TestState_GetTopProducers(t *testing.T) {
+	state := mockTopProducersState(100)
+	if !assert.Equal(t, 100, len(state.GetActiveProducers())) {
+		t.FailNow()
+	}
+	reference := state.GetActiveProducers()
+	sortProducers(reference, compareProducersByVotes)
+
+	for _, n := range []int{1, 20, 99, 100, 150} {
+		top := state.GetTopProducers(n)
+		expected := reference
+		if n < len(reference) {
+			expected = reference[:n]
+		}
+		if !assert.Equal(t, len(expected), len(top)) {
+			continue
+		}
+		for i, p := range top {
+			assert.Equal(t, expected[i].NodePublicKey(), p.NodePublicKey())
+			assert.Equal(t, expected[i].Votes(), p.Votes())
+		}
+	}
+	assert.Nil(t, state.GetTopProducers(0))
+
+	// The results are copies of producers.
+	top := state.GetTopProducers(1)
+	top[0].votes++
+	assert.Equal(t, reference[0].Votes(), state.GetTopProducers(1)[0].Votes())
+}
+
+func BenchmarkState_GetTopProducers(b *testing.B) {
+	state := mockTopProducersState(2000)
+
+	b.Run("heap", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			state.GetTopProducers(20)
+		}
+	})
+
+	b.Run("sort", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			producers := state.GetActiveProducers()
+			sortProducers(producers, compareProducersByVotes)
+			_ = producers[:20]
+		}
+	})
+}
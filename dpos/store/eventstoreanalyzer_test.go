@@ -0,0 +1,54 @@
+package store
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/dpos/state"
+)
+
+func TestEventStoreAnalyzer_InactiveEliminateCount(t *testing.T) {
+	arbiters := make([][]byte, 36)
+	for i := range arbiters {
+		arbiters[i] = make([]byte, 33)
+		rand.Read(arbiters[i])
+	}
+	arbitrators := state.NewArbitratorsMock(arbiters, 0, 24)
+	arbitrators.CRCArbitrators = arbiters[:12]
+	crc := make(map[string]struct{})
+	for _, v := range arbitrators.CRCArbitrators {
+		crc[common.BytesToHexString(v)] = struct{}{}
+	}
+
+	// A larger tolerance allows more arbiters eliminated in one change.
+	for _, count := range []uint32{3, 12, 20} {
+		analyzer := NewEventStoreAnalyzer(EventStoreAnalyzerConfig{
+			InactiveEliminateCount: count,
+			Arbitrators:            arbitrators,
+		})
+		result := analyzer.ParseInactiveArbitrators()
+		if len(result) > int(count) {
+			t.Errorf("eliminated %d arbiters more than %d", len(result),
+				count)
+		}
+		for _, v := range result {
+			if _, ok := crc[v]; ok {
+				t.Errorf("CRC arbiter %s eliminated", v)
+			}
+		}
+	}
+
+	arbitrators.CRCArbitrators = nil
+	for _, count := range []uint32{3, 12, 20} {
+		analyzer := NewEventStoreAnalyzer(EventStoreAnalyzerConfig{
+			InactiveEliminateCount: count,
+			Arbitrators:            arbitrators,
+		})
+		if result := analyzer.ParseInactiveArbitrators(); len(result) !=
+			int(count) {
+			t.Errorf("eliminated %d arbiters, expect %d", len(result),
+				count)
+		}
+	}
+}
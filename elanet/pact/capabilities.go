@@ -0,0 +1,56 @@
+package pact
+
+import (
+	"io"
+
+	"github.com/elastos/Elastos.ELA/common"
+)
+
+// Capabilities bundles the capabilities a peer advertises during handshake.
+type Capabilities struct {
+	// Services is the services supported by the peer.
+	Services ServiceFlag
+
+	// ProtocolVersion is the protocol version the peer uses.
+	ProtocolVersion uint32
+
+	// MaxBlockSize is the maximum block size in bytes the peer accepts.
+	MaxBlockSize uint32
+}
+
+// Encode writes the capabilities into the given writer.
+func (c *Capabilities) Encode(w io.Writer) error {
+	return common.WriteElements(w, uint64(c.Services), c.ProtocolVersion,
+		c.MaxBlockSize)
+}
+
+// Decode reads the capabilities from the given reader.
+func (c *Capabilities) Decode(r io.Reader) error {
+	var services uint64
+	if err := common.ReadElements(r, &services, &c.ProtocolVersion,
+		&c.MaxBlockSize); err != nil {
+		return err
+	}
+	c.Services = ServiceFlag(services)
+	return nil
+}
+
+// NewCapabilities returns the capabilities of this package with the given
+// services.
+func NewCapabilities(services ServiceFlag) *Capabilities {
+	return &Capabilities{
+		Services:        services,
+		ProtocolVersion: ProtocolVersion,
+		MaxBlockSize:    MaxBlockSize,
+	}
+}
+
+// ShouldRelayBlockTo returns whether a block of blockSize bytes can be relayed
+// to a peer advertising peerMaxBlockSize.  A peer not advertising the limit
+// is assumed to accept blocks up to MaxBlockSize.
+func ShouldRelayBlockTo(peerMaxBlockSize int, blockSize int) bool {
+	if peerMaxBlockSize <= 0 {
+		peerMaxBlockSize = MaxBlockSize
+	}
+	return blockSize <= peerMaxBlockSize
+}
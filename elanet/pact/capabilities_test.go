@@ -0,0 +1,45 @@
+package pact
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapabilities_EncodeDecode(t *testing.T) {
+	// Include an unknown service flag bit.
+	services := SFNodeNetwork | SFNodeBloom | ServiceFlag(1<<40)
+	c1 := NewCapabilities(services)
+
+	buf := new(bytes.Buffer)
+	if !assert.NoError(t, c1.Encode(buf)) {
+		t.FailNow()
+	}
+
+	var c2 Capabilities
+	if !assert.NoError(t, c2.Decode(buf)) {
+		t.FailNow()
+	}
+	assert.Equal(t, *c1, c2)
+	assert.Equal(t, ServiceFlag(1<<40), c2.Services&^(SFNodeNetwork|SFNodeBloom))
+	assert.Equal(t, ProtocolVersion, c2.ProtocolVersion)
+	assert.Equal(t, uint32(MaxBlockSize), c2.MaxBlockSize)
+
+	// Decode from truncated data should fail.
+	buf = new(bytes.Buffer)
+	c1.Encode(buf)
+	var c3 Capabilities
+	assert.Error(t, c3.Decode(bytes.NewReader(buf.Bytes()[:10])))
+}
+
+func TestShouldRelayBlockTo(t *testing.T) {
+	// Blocks up to the peer limit can be relayed.
+	assert.True(t, ShouldRelayBlockTo(1000, 999))
+	assert.True(t, ShouldRelayBlockTo(1000, 1000))
+	assert.False(t, ShouldRelayBlockTo(1000, 1001))
+
+	// Peers not advertising the limit accept blocks up to MaxBlockSize.
+	assert.True(t, ShouldRelayBlockTo(0, MaxBlockSize))
+	assert.False(t, ShouldRelayBlockTo(0, MaxBlockSize+1))
+}
@@ -1,6 +1,7 @@
 package pact
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 )
@@ -19,13 +20,31 @@ const (
 	// MaxBlockSize is the maximum number of bytes allowed per block.
 	MaxBlockSize = 8000000
 
+	// MaxBlockHeaderSize is the maximum number of bytes allowed per block
+	// header.  A header including the aux pow is usually less than 1KB, the
+	// extra room is kept for large parent coinbase transactions.
+	MaxBlockHeaderSize = 4096
+
 	// MaxTxPerBlock is the maximux number of transactions allowed per block.
 	MaxTxPerBlock = 10000
 
+	// MaxExemptTxPerBlock is the maximum number of DPOS special transactions
+	// per block not counted against MaxTxPerBlock.
+	MaxExemptTxPerBlock = 100
+
 	// MaxBlocksPerMsg is the maximum number of blocks allowed per message.
 	MaxBlocksPerMsg = 500
 )
 
+// MaxTxPerBlockForVersion returns the maximum number of transactions allowed
+// per block on the given protocol version.  It's for negotiating with peers
+// only, block validation uses MaxTxPerBlock so the validity of a block does
+// not depend on the local protocol version.
+func MaxTxPerBlockForVersion(version uint32) int {
+	// All protocol versions share the same limit until a new version raises it.
+	return MaxTxPerBlock
+}
+
 // ServiceFlag identifies services supported by a peer.
 type ServiceFlag uint64
 
@@ -80,3 +99,42 @@ func (f ServiceFlag) String() string {
 	s = strings.TrimLeft(s, "|")
 	return s
 }
+
+// AllServiceFlags returns all known service flags.
+func AllServiceFlags() []ServiceFlag {
+	flags := make([]ServiceFlag, len(orderedSFStrings))
+	copy(flags, orderedSFStrings)
+	return flags
+}
+
+// ParseServiceFlags parses a pipe-delimited service flags string, in the same
+// format as ServiceFlag.String() returns, back into a ServiceFlag value.
+func ParseServiceFlags(s string) (ServiceFlag, error) {
+	var f ServiceFlag
+	for _, token := range strings.Split(s, "|") {
+		token = strings.TrimSpace(token)
+
+		// Flags which aren't accounted for are presented as hex.
+		if strings.HasPrefix(token, "0x") {
+			v, err := strconv.ParseUint(token[2:], 16, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid service flag %s", token)
+			}
+			f |= ServiceFlag(v)
+			continue
+		}
+
+		var found bool
+		for _, flag := range orderedSFStrings {
+			if sfStrings[flag] == token {
+				f |= flag
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown service flag %s", token)
+		}
+	}
+	return f, nil
+}
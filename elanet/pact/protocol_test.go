@@ -0,0 +1,59 @@
+package pact
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxTxPerBlockForVersion(t *testing.T) {
+	tests := []struct {
+		version uint32
+		max     int
+	}{
+		{0, 10000},
+		{EBIP001Version, 10000},
+		{DPOSStartVersion, 10000},
+		{ProtocolVersion, 10000},
+		{ProtocolVersion + 1, 10000},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.max, MaxTxPerBlockForVersion(test.version),
+			"version %d", test.version)
+	}
+}
+
+func TestAllServiceFlags(t *testing.T) {
+	flags := AllServiceFlags()
+	if !assert.Equal(t, len(sfStrings), len(flags)) {
+		t.FailNow()
+	}
+	for _, flag := range flags {
+		_, ok := sfStrings[flag]
+		assert.True(t, ok)
+	}
+}
+
+func TestParseServiceFlags(t *testing.T) {
+	tests := []ServiceFlag{
+		0,
+		SFNodeNetwork,
+		SFNodeNetwork | SFTxFiltering,
+		SFNodeNetwork | SFTxFiltering | SFNodeBloom,
+		SFNodeBloom | ServiceFlag(1<<10),
+		ServiceFlag(1 << 20),
+	}
+	for _, flag := range tests {
+		f, err := ParseServiceFlags(flag.String())
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		assert.Equal(t, flag, f, "flag %s", flag)
+	}
+
+	_, err := ParseServiceFlags("SFNodeNetwork|SFUnknown")
+	assert.EqualError(t, err, "unknown service flag SFUnknown")
+
+	_, err = ParseServiceFlags("SFNodeNetwork|0xzz")
+	assert.EqualError(t, err, "invalid service flag 0xzz")
+}
@@ -47,6 +47,16 @@ const (
 
 	// ETIllegalEvidence indicates a illegal block received.
 	ETIllegalBlockEvidence
+
+	// ETNetworkDegraded indicates the DPOS network entered understaffed mode.
+	ETNetworkDegraded
+
+	// ETNetworkRecovered indicates the DPOS network left understaffed mode.
+	ETNetworkRecovered
+
+	// ETArbiterNearInactive indicates an arbiter's consecutive missed
+	// signings are approaching the inactive threshold.
+	ETArbiterNearInactive
 )
 
 // notificationTypeStrings is a map of notification types back to their constant
@@ -59,6 +69,9 @@ var notificationTypeStrings = map[EventType]string{
 	ETNewBlockReceived:    "ETNewBlockReceived",
 	ETConfirmAccepted:     "ETConfirmAccepted",
 	ETDirectPeersChanged:  "ETDirectPeersChanged",
+	ETNetworkDegraded:     "ETNetworkDegraded",
+	ETNetworkRecovered:    "ETNetworkRecovered",
+	ETArbiterNearInactive: "ETArbiterNearInactive",
 }
 
 // String returns the EventType in human-readable form.
@@ -76,6 +89,9 @@ func (n EventType) String() string {
 // 	- ETBlockConnected:    *types.Block
 // 	- ETBlockDisconnected: *types.Block
 // 	- ETTransactionAccepted: *types.Transaction
+// 	- ETNetworkDegraded:   *state.NetworkModeChange
+// 	- ETNetworkRecovered:  *state.NetworkModeChange
+// 	- ETArbiterNearInactive: *state.NearInactiveArbiter
 type Event struct {
 	Type EventType
 	Data interface{}
@@ -83,7 +83,8 @@ func main() {
 
 	blockchain.DefaultLedger = &ledger // fixme
 
-	arbiters, err := state.NewArbitrators(activeNetParams, chainStore.GetHeight)
+	arbiters, err := state.NewArbitrators(activeNetParams, chainStore.GetHeight,
+		state.WithTxReference(chainStore.GetTxReference))
 	if err != nil {
 		printErrorAndExit(err)
 	}
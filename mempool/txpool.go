@@ -222,7 +222,8 @@ func (mp *TxPool) cleanVoteAndUpdateProducer(ownerPublicKey []byte) error {
 						return errors.New("invalid vote output payload")
 					}
 					for _, content := range opPayload.Contents {
-						if content.VoteType == outputpayload.Delegate {
+						if content.VoteType == outputpayload.Delegate ||
+							content.VoteType == outputpayload.Candidate {
 							for _, pubKey := range content.Candidates {
 								if bytes.Equal(ownerPublicKey, pubKey) {
 									mp.removeTransaction(txn)
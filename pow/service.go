@@ -163,7 +163,8 @@ func (pow *Service) AssignCoinbaseTxRewards(block *types.Block, totalReward comm
 
 		var dposChange common.Fixed64
 		var err error
-		if dposChange, err = pow.distributeDposReward(block.Transactions[0], rewardDposArbiter); err != nil {
+		if dposChange, err = pow.distributeDposReward(block.Transactions[0],
+			rewardDposArbiter, block.Height); err != nil {
 			return err
 		}
 		rewardMergeMiner := common.Fixed64(totalReward) - rewardCyberRepublic - rewardDposArbiter + dposChange
@@ -187,51 +188,95 @@ func (pow *Service) AssignCoinbaseTxRewards(block *types.Block, totalReward comm
 	return nil
 }
 
-func (pow *Service) distributeDposReward(coinBaseTx *types.Transaction, reward common.Fixed64) (common.Fixed64, error) {
+func (pow *Service) distributeDposReward(coinBaseTx *types.Transaction,
+	reward common.Fixed64, height uint32) (common.Fixed64, error) {
 	ownerHashes := pow.arbiters.GetCurrentOwnerProgramHashes()
 	if len(ownerHashes) == 0 {
 		return 0, errors.New("not found arbiters when distributeDposReward")
 	}
 	candidateOwnerHashes := pow.arbiters.GetCandidateOwnerProgramHashes()
 
-	totalBlockConfirmReward := float64(reward) * 0.25
-	totalTopProducersReward := float64(reward) - totalBlockConfirmReward
-	individualBlockConfirmReward := common.Fixed64(math.Floor(totalBlockConfirmReward / float64(len(ownerHashes))))
+	var minBlockConfirmReward common.Fixed64
+	if height >= pow.chainParams.MinConfirmRewardHeight {
+		minBlockConfirmReward = pow.chainParams.MinBlockConfirmReward
+	}
+	individualBlockConfirmReward, totalTopProducersReward :=
+		blockchain.SplitDPOSReward(float64(reward), len(ownerHashes),
+			minBlockConfirmReward)
 	totalVotesInRound := pow.arbiters.GetTotalVotesInRound()
 	if totalVotesInRound == common.Fixed64(0) {
 		panic("total votes in round equal 0")
 	}
 	rewardPerVote := totalTopProducersReward / float64(totalVotesInRound)
 
+	var crcRecipients []config.CRCRewardRecipient
+	if height >= pow.chainParams.CRCRewardHeight {
+		crcRecipients = pow.chainParams.CRCRewardRecipients
+	}
+	if err := blockchain.CheckCRCRewardRecipients(crcRecipients, ownerHashes,
+		candidateOwnerHashes); err != nil {
+		return 0, err
+	}
 	realDposReward := common.Fixed64(0)
+	crcReward := common.Fixed64(0)
+	var votedOutputs []*types.Output
+	var votedExactRewards []float64
 	for _, ownerHash := range ownerHashes {
 		votes := pow.arbiters.GetOwnerVotes(ownerHash)
-		individualProducerReward := common.Fixed64(float64(votes) * rewardPerVote)
+		exactReward := float64(votes) * rewardPerVote
+		individualProducerReward := common.Fixed64(exactReward)
 		reward := individualBlockConfirmReward + individualProducerReward
-		if pow.arbiters.IsCRCArbitratorProgramHash(ownerHash) {
+		isCRC := pow.arbiters.IsCRCArbitratorProgramHash(ownerHash)
+		if isCRC {
 			reward = individualBlockConfirmReward
+			// CRC arbiters rewards are split among the recipients if set.
+			if len(crcRecipients) > 0 {
+				crcReward += reward
+				continue
+			}
 		}
-		coinBaseTx.Outputs = append(coinBaseTx.Outputs, &types.Output{
+		output := &types.Output{
 			AssetID:     config.ELAAssetID,
 			Value:       reward,
 			ProgramHash: *ownerHash,
 			Type:        types.OTNone,
 			Payload:     &outputpayload.DefaultOutput{},
-		})
+		}
+		coinBaseTx.Outputs = append(coinBaseTx.Outputs, output)
+		if !isCRC {
+			votedOutputs = append(votedOutputs, output)
+			votedExactRewards = append(votedExactRewards, exactReward)
+		}
 
 		realDposReward += reward
 	}
 
+	for i, amount := range blockchain.SplitCRCReward(crcReward, crcRecipients) {
+		coinBaseTx.Outputs = append(coinBaseTx.Outputs, &types.Output{
+			AssetID:     config.ELAAssetID,
+			Value:       amount,
+			ProgramHash: crcRecipients[i].Address,
+			Type:        types.OTNone,
+			Payload:     &outputpayload.DefaultOutput{},
+		})
+
+		realDposReward += amount
+	}
+
 	for _, ownerHash := range candidateOwnerHashes {
 		votes := pow.arbiters.GetOwnerVotes(ownerHash)
-		individualProducerReward := common.Fixed64(float64(votes) * rewardPerVote)
-		coinBaseTx.Outputs = append(coinBaseTx.Outputs, &types.Output{
+		exactReward := float64(votes) * rewardPerVote
+		individualProducerReward := common.Fixed64(exactReward)
+		output := &types.Output{
 			AssetID:     config.ELAAssetID,
 			Value:       individualProducerReward,
 			ProgramHash: *ownerHash,
 			Type:        types.OTNone,
 			Payload:     &outputpayload.DefaultOutput{},
-		})
+		}
+		coinBaseTx.Outputs = append(coinBaseTx.Outputs, output)
+		votedOutputs = append(votedOutputs, output)
+		votedExactRewards = append(votedExactRewards, exactReward)
 
 		realDposReward += individualProducerReward
 	}
@@ -240,6 +285,20 @@ func (pow *Service) distributeDposReward(coinBaseTx *types.Transaction, reward c
 	if change < 0 {
 		return 0, errors.New("real dpos reward more than reward limit")
 	}
+
+	roundingMode := config.RoundFloorWithChange
+	if height >= pow.chainParams.RewardRoundingHeight {
+		roundingMode = pow.chainParams.RewardRoundingMode
+	}
+	extras, err := blockchain.SplitRewardChange(change, votedExactRewards,
+		roundingMode)
+	if err != nil {
+		return 0, err
+	}
+	for i, extra := range extras {
+		votedOutputs[i].Value += extra
+		change -= extra
+	}
 	return change, nil
 }
 
@@ -269,6 +328,10 @@ func (pow *Service) GenerateBlock(minerAddr string) (*types.Block, error) {
 	msgBlock.Transactions = append(msgBlock.Transactions, coinBaseTx)
 	totalTxsSize := coinBaseTx.GetSize()
 	txCount := 1
+	exemptTxCount := 0
+	var inactiveTxCount uint32
+	inactiveTxLimit := blockchain.InactiveArbitratorsLimit(pow.chainParams,
+		nextBlockHeight)
 	totalTxFee := common.Fixed64(0)
 	txs := pow.txMemPool.GetTxsInPool()
 	sort.Slice(txs, func(i, j int) bool {
@@ -282,12 +345,19 @@ func (pow *Service) GenerateBlock(minerAddr string) (*types.Block, error) {
 	})
 
 	for _, tx := range txs {
+		if tx.IsInactiveArbitrators() && inactiveTxLimit > 0 &&
+			inactiveTxCount >= inactiveTxLimit {
+			continue
+		}
 		size := totalTxsSize + tx.GetSize()
 		if size > pact.MaxBlockSize {
 			continue
 		}
 		totalTxsSize = size
-		if txCount >= pact.MaxTxPerBlock {
+		exempt := blockchain.ExemptFromTxLimit(pow.chainParams,
+			nextBlockHeight, tx, exemptTxCount)
+		if !exempt &&
+			txCount >= pact.MaxTxPerBlock {
 			log.Warn("txCount reached max MaxTxPerBlock")
 			break
 		}
@@ -305,7 +375,14 @@ func (pow *Service) GenerateBlock(minerAddr string) (*types.Block, error) {
 		}
 		msgBlock.Transactions = append(msgBlock.Transactions, tx)
 		totalTxFee += fee
-		txCount++
+		if tx.IsInactiveArbitrators() {
+			inactiveTxCount++
+		}
+		if exempt {
+			exemptTxCount++
+		} else {
+			txCount++
+		}
 	}
 
 	totalReward := totalTxFee + pow.chainParams.RewardPerBlock
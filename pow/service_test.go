@@ -324,3 +324,191 @@ func TestService_AssignCoinbaseTxRewards(t *testing.T) {
 
 	blockchain.DefaultLedger = originLedger
 }
+
+func TestService_AssignCoinbaseTxRewards_MinBlockConfirmReward(t *testing.T) {
+	originParams := *pow.chainParams
+	defer func() { *pow.chainParams = originParams }()
+
+	arbitratorHashes := make([]*common.Uint168, 0)
+	candidateHashes := make([]*common.Uint168, 0)
+	ownerVotes := make(map[common.Uint168]common.Fixed64)
+	totalVotesInRound := common.Fixed64(0)
+	for i := 0; i < 10; i++ {
+		hash := common.Uint168{byte(i + 1)}
+		if i < 5 {
+			arbitratorHashes = append(arbitratorHashes, &hash)
+		} else {
+			candidateHashes = append(candidateHashes, &hash)
+		}
+		ownerVotes[hash] = common.Fixed64(i + 1)
+		totalVotesInRound += common.Fixed64(i + 1)
+	}
+	arbitratorsMock.CurrentOwnerProgramHashes = arbitratorHashes
+	arbitratorsMock.CandidateOwnerProgramHashes = candidateHashes
+	arbitratorsMock.OwnerVotesInRound = ownerVotes
+	arbitratorsMock.TotalVotesInRound = totalVotesInRound
+
+	assignRewards := func(rewardInCoinbase common.Fixed64) (
+		arbiterRewards, candidateRewards []common.Fixed64) {
+		tx := &types.Transaction{
+			Version: types.TxVersion09,
+			TxType:  types.CoinBase,
+		}
+		tx.Outputs = []*types.Output{
+			{ProgramHash: blockchain.FoundationAddress, Value: 0},
+			{ProgramHash: common.Uint168{}, Value: 0},
+		}
+		block := &types.Block{
+			Header: types.Header{
+				Height: pow.chainParams.PublicDPOSHeight,
+			},
+			Transactions: []*types.Transaction{tx},
+		}
+		assert.NoError(t, pow.AssignCoinbaseTxRewards(block, rewardInCoinbase))
+
+		var total common.Fixed64
+		for _, output := range tx.Outputs {
+			total += output.Value
+		}
+		assert.Equal(t, rewardInCoinbase, total)
+
+		dposReward := common.Fixed64(float64(rewardInCoinbase) * 0.35)
+		var realDPOSReward common.Fixed64
+		for i, output := range tx.Outputs[2:] {
+			if i < len(arbitratorHashes) {
+				arbiterRewards = append(arbiterRewards, output.Value)
+			} else {
+				candidateRewards = append(candidateRewards, output.Value)
+			}
+			realDPOSReward += output.Value
+		}
+		assert.True(t, realDPOSReward <= dposReward)
+		return arbiterRewards, candidateRewards
+	}
+
+	// With a tiny reward, the block confirm reward floors to 1 without the
+	// minimum block confirm reward.
+	rewardInCoinbase := common.Fixed64(100)
+	pow.chainParams.MinBlockConfirmReward = 0
+	arbiterRewards, _ := assignRewards(rewardInCoinbase)
+	assert.Equal(t, common.Fixed64(1), arbiterRewards[0])
+
+	// The minimum block confirm reward takes no effect before the height.
+	pow.chainParams.MinBlockConfirmReward = 3
+	pow.chainParams.MinConfirmRewardHeight = pow.chainParams.PublicDPOSHeight + 1
+	arbiterRewards, _ = assignRewards(rewardInCoinbase)
+	assert.Equal(t, common.Fixed64(1), arbiterRewards[0])
+
+	// Each arbiter gets at least the minimum block confirm reward.
+	pow.chainParams.MinConfirmRewardHeight = pow.chainParams.PublicDPOSHeight
+	arbiterRewards, _ = assignRewards(rewardInCoinbase)
+	for _, reward := range arbiterRewards {
+		assert.True(t, reward >= 3)
+	}
+
+	// The DPOS reward is not enough for the minimum, so arbiters share the
+	// DPOS reward equally.
+	pow.chainParams.MinBlockConfirmReward = 10
+	arbiterRewards, candidateRewards := assignRewards(rewardInCoinbase)
+	for _, reward := range arbiterRewards {
+		assert.Equal(t, common.Fixed64(7), reward)
+	}
+	for _, reward := range candidateRewards {
+		assert.Equal(t, common.Fixed64(0), reward)
+	}
+}
+
+func TestService_AssignCoinbaseTxRewards_RewardRoundingMode(t *testing.T) {
+	originParams := *pow.chainParams
+	defer func() { *pow.chainParams = originParams }()
+
+	arbitratorHashes := make([]*common.Uint168, 0)
+	candidateHashes := make([]*common.Uint168, 0)
+	ownerVotes := make(map[common.Uint168]common.Fixed64)
+	totalVotesInRound := common.Fixed64(0)
+	for i := 0; i < 10; i++ {
+		hash := common.Uint168{byte(i + 1)}
+		if i < 5 {
+			arbitratorHashes = append(arbitratorHashes, &hash)
+		} else {
+			candidateHashes = append(candidateHashes, &hash)
+		}
+		ownerVotes[hash] = common.Fixed64(i + 1)
+		totalVotesInRound += common.Fixed64(i + 1)
+	}
+	arbitratorsMock.CurrentOwnerProgramHashes = arbitratorHashes
+	arbitratorsMock.CandidateOwnerProgramHashes = candidateHashes
+	arbitratorsMock.OwnerVotesInRound = ownerVotes
+	arbitratorsMock.TotalVotesInRound = totalVotesInRound
+	pow.chainParams.MinBlockConfirmReward = 0
+	pow.chainParams.RewardRoundingHeight = 0
+
+	rewardInCoinbase := common.Fixed64(999)
+	dposReward := common.Fixed64(float64(rewardInCoinbase) * 0.35)
+	assignRewards := func(mode config.RewardRoundingMode) (
+		rewards []common.Fixed64, change common.Fixed64) {
+		pow.chainParams.RewardRoundingMode = mode
+		tx := &types.Transaction{
+			Version: types.TxVersion09,
+			TxType:  types.CoinBase,
+		}
+		tx.Outputs = []*types.Output{
+			{ProgramHash: blockchain.FoundationAddress, Value: 0},
+			{ProgramHash: common.Uint168{}, Value: 0},
+		}
+		block := &types.Block{
+			Header: types.Header{
+				Height: pow.chainParams.PublicDPOSHeight,
+			},
+			Transactions: []*types.Transaction{tx},
+		}
+		assert.NoError(t, pow.AssignCoinbaseTxRewards(block, rewardInCoinbase))
+
+		var realDPOSReward common.Fixed64
+		for _, output := range tx.Outputs[2:] {
+			rewards = append(rewards, output.Value)
+			realDPOSReward += output.Value
+		}
+		assert.True(t, realDPOSReward <= dposReward)
+		return rewards, dposReward - realDPOSReward
+	}
+
+	// The change of floor rounding goes to the merge miner.
+	floorRewards, floorChange := assignRewards(config.RoundFloorWithChange)
+	assert.True(t, floorChange > 0)
+
+	// The change is assigned to the producers with the most votes first, so
+	// no change is left.
+	rewards, change := assignRewards(config.RoundLargestRemainder)
+	assert.Equal(t, common.Fixed64(0), change)
+	share := floorChange / common.Fixed64(len(rewards))
+	var extras common.Fixed64
+	for i := range rewards {
+		extra := rewards[i] - floorRewards[i]
+		assert.True(t, extra == share || extra == share+1)
+		extras += extra
+	}
+	assert.Equal(t, floorChange, extras)
+
+	// The change goes to the merge miner before RewardRoundingHeight.
+	pow.chainParams.RewardRoundingHeight = pow.chainParams.PublicDPOSHeight + 1
+	rewards, change = assignRewards(config.RoundLargestRemainder)
+	assert.Equal(t, floorChange, change)
+	assert.Equal(t, floorRewards, rewards)
+
+	// Unknown rounding mode is rejected.
+	pow.chainParams.RewardRoundingHeight = 0
+	pow.chainParams.RewardRoundingMode = config.RewardRoundingMode(2)
+	block := &types.Block{
+		Header: types.Header{Height: pow.chainParams.PublicDPOSHeight},
+		Transactions: []*types.Transaction{{
+			Version: types.TxVersion09,
+			TxType:  types.CoinBase,
+			Outputs: []*types.Output{
+				{ProgramHash: blockchain.FoundationAddress, Value: 0},
+				{ProgramHash: common.Uint168{}, Value: 0},
+			},
+		}},
+	}
+	assert.Error(t, pow.AssignCoinbaseTxRewards(block, rewardInCoinbase))
+}